@@ -10,18 +10,29 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/keystore"
 	"github.com/MixinNetwork/mixin/storage"
+	"github.com/MixinNetwork/mixin/wallet"
+	"github.com/VictoriaMetrics/fastcache"
 	"github.com/urfave/cli"
+	"golang.org/x/crypto/ssh/terminal"
 )
 
 func createAdressCmd(c *cli.Context) error {
+	err := setAddressNetwork(c)
+	if err != nil {
+		return err
+	}
 	seed := make([]byte, 64)
-	_, err := rand.Read(seed)
+	_, err = rand.Read(seed)
 	if err != nil {
 		return err
 	}
@@ -53,6 +64,10 @@ func createAdressCmd(c *cli.Context) error {
 }
 
 func decodeAddressCmd(c *cli.Context) error {
+	err := setAddressNetwork(c)
+	if err != nil {
+		return err
+	}
 	addr, err := common.NewAddressFromString(c.String("address"))
 	if err != nil {
 		return err
@@ -64,6 +79,13 @@ func decodeAddressCmd(c *cli.Context) error {
 	return nil
 }
 
+func setAddressNetwork(c *cli.Context) error {
+	if network := c.String("network"); network != "" {
+		return common.SetAddressNetworkId(network)
+	}
+	return nil
+}
+
 func updateHeadReference(c *cli.Context) error {
 	store, err := storage.NewBadgerStore(c.String("dir"))
 	if err != nil {
@@ -121,6 +143,90 @@ func validateGraphEntries(c *cli.Context) error {
 	return nil
 }
 
+func exportCheckpoint(c *cli.Context) error {
+	store, err := storage.NewBadgerStore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	var state struct{ Id crypto.Hash }
+	_, err = store.StateGet("network", &state)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(c.String("file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return store.ExportCheckpoint(state.Id, c.Uint64("number"), f)
+}
+
+func importCheckpoint(c *cli.Context) error {
+	trusted, err := crypto.HashFromString(c.String("hash"))
+	if err != nil {
+		return err
+	}
+	store, err := storage.NewBadgerStore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	checkpoint, err := kernel.ImportCheckpoint(store, c.String("dir"), c.String("file"), trusted)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("checkpoint: %d hash: %s\n", checkpoint.Number, checkpoint.PayloadHash())
+	return nil
+}
+
+func exportGraph(c *cli.Context) error {
+	store, err := storage.NewBadgerStore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	var state struct{ Id crypto.Hash }
+	_, err = store.StateGet("network", &state)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(c.String("file"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	total, err := kernel.ExportGraph(store, state.Id, f)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("snapshots: %d\n", total)
+	return nil
+}
+
+func importGraph(c *cli.Context) error {
+	err := config.Initialize(c.String("dir") + "/config.json")
+	if err != nil {
+		return err
+	}
+	store, err := storage.NewBadgerStore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	cache := fastcache.New(config.Custom.MaxCacheSize * 1024 * 1024)
+	node, err := kernel.SetupNode(store, cache, "", c.String("dir"))
+	if err != nil {
+		return err
+	}
+	total, err := node.ImportGraph(c.String("file"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("snapshots: %d topology: %d\n", total, node.TopologicalOrder())
+	return nil
+}
+
 func decodeTransactionCmd(c *cli.Context) error {
 	raw, err := hex.DecodeString(c.String("raw"))
 	if err != nil {
@@ -148,61 +254,279 @@ func signTransactionCmd(c *cli.Context) error {
 	}
 	raw.Node = c.String("node")
 
-	seed, err := hex.DecodeString(c.String("seed"))
+	tx, err := raw.buildTransaction(c.String("seed"))
 	if err != nil {
 		return err
 	}
-	if len(seed) != 64 {
-		seed = make([]byte, 64)
-		_, err := rand.Read(seed)
+
+	account, err := parseSignerAccount(c)
+	if err != nil {
+		return err
+	}
+
+	signed := tx.AsLatestVersion()
+	for i, _ := range signed.Inputs {
+		err := signed.SignInput(raw, i, []common.Address{account})
 		if err != nil {
 			return err
 		}
 	}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
 
-	tx := common.NewTransaction(raw.Asset)
+func createPartialTransactionCmd(c *cli.Context) error {
+	var raw signerInput
+	err := json.Unmarshal([]byte(c.String("raw")), &raw)
+	if err != nil {
+		return err
+	}
+	raw.Node = c.String("node")
 	for _, in := range raw.Inputs {
-		if in.Deposit != nil {
-			tx.AddDepositInput(in.Deposit)
-		} else {
-			tx.AddInput(in.Hash, in.Index)
+		if len(in.Keys) == 0 {
+			continue
+		}
+		// the partial transaction keeps the utxo for the offline signers
+		if err := in.Script.VerifyFormat(); err != nil {
+			return fmt.Errorf("invalid input script %s:%d %v", in.Hash.String(), in.Index, err)
+		}
+		if in.Amount.Sign() <= 0 {
+			return fmt.Errorf("invalid input amount %s:%d %s", in.Hash.String(), in.Index, in.Amount.String())
 		}
 	}
 
-	for _, out := range raw.Outputs {
-		hash := crypto.NewHash(seed)
-		seed = append(hash[:], hash[:]...)
-		tx.AddOutputWithType(out.Type, out.Accounts, out.Script, out.Amount, seed)
+	tx, err := raw.buildTransaction(c.String("seed"))
+	if err != nil {
+		return err
+	}
+	pst, err := common.NewPartiallySignedTransaction(tx, raw)
+	if err != nil {
+		return err
 	}
+	fmt.Println(hex.EncodeToString(pst.Marshal()))
+	return nil
+}
 
-	extra, err := hex.DecodeString(raw.Extra)
+func signPartialTransactionCmd(c *cli.Context) error {
+	pst, err := decodePartialTransaction(c.String("raw"))
 	if err != nil {
 		return err
 	}
-	tx.Extra = extra
+	account, err := parseSignerAccount(c)
+	if err != nil {
+		return err
+	}
+	for i, _ := range pst.UTXOs {
+		err := pst.SignInput(i, []common.Address{account})
+		if err != nil {
+			return err
+		}
+	}
+	fmt.Println(hex.EncodeToString(pst.Marshal()))
+	return nil
+}
 
-	key, err := hex.DecodeString(c.String("key"))
+func mergePartialTransactionsCmd(c *cli.Context) error {
+	raws := c.StringSlice("raw")
+	if len(raws) < 1 {
+		return fmt.Errorf("invalid partial transactions count %d", len(raws))
+	}
+	pst, err := decodePartialTransaction(raws[0])
 	if err != nil {
 		return err
 	}
-	if len(key) != 64 {
-		return fmt.Errorf("invalid key length %d", len(key))
+	for _, raw := range raws[1:] {
+		other, err := decodePartialTransaction(raw)
+		if err != nil {
+			return err
+		}
+		err = pst.Merge(other)
+		if err != nil {
+			return err
+		}
 	}
-	var account common.Address
-	copy(account.PrivateViewKey[:], key[:32])
-	copy(account.PrivateSpendKey[:], key[32:])
+	fmt.Println(hex.EncodeToString(pst.Marshal()))
+	return nil
+}
 
-	signed := tx.AsLatestVersion()
-	for i, _ := range signed.Inputs {
-		err := signed.SignInput(raw, i, []common.Address{account})
+func finalizePartialTransactionCmd(c *cli.Context) error {
+	pst, err := decodePartialTransaction(c.String("raw"))
+	if err != nil {
+		return err
+	}
+	ver, err := pst.Finalize()
+	if err != nil {
+		return err
+	}
+	fmt.Println(hex.EncodeToString(ver.Marshal()))
+	return nil
+}
+
+func decodePartialTransaction(raw string) (*common.PartiallySignedTransaction, error) {
+	data, err := hex.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	return common.UnmarshalPartiallySignedTransaction(data)
+}
+
+func parseSignerAccount(c *cli.Context) (common.Address, error) {
+	if ref := c.String("keystore"); ref != "" {
+		return unlockKeystoreAccount(c.String("dir"), ref, c.String("passphrase-file"))
+	}
+	return parseAccountKey(c.String("key"))
+}
+
+func unlockKeystoreAccount(dir, ref, passphraseFile string) (common.Address, error) {
+	ks, err := keystore.NewKeystore(dir)
+	if err != nil {
+		return common.Address{}, err
+	}
+	key, err := ks.Read(ref)
+	if err != nil {
+		return common.Address{}, err
+	}
+	passphrase, err := readPassphrase(passphraseFile, fmt.Sprintf("Passphrase for %s: ", key.Address))
+	if err != nil {
+		return common.Address{}, err
+	}
+	return key.Decrypt(passphrase)
+}
+
+func readPassphrase(file, prompt string) ([]byte, error) {
+	if file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.TrimRight(data, "\r\n"), nil
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	return passphrase, err
+}
+
+func importKeyCmd(c *cli.Context) error {
+	ks, err := keystore.NewKeystore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	k := c.String("key")
+	if k == "" {
+		raw, err := readPassphrase("", "Private view and spend key: ")
 		if err != nil {
 			return err
 		}
+		k = string(raw)
 	}
-	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	account, err := parseAccountKey(k)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase(c.String("passphrase-file"), "Passphrase: ")
+	if err != nil {
+		return err
+	}
+	if c.String("passphrase-file") == "" {
+		confirm, err := readPassphrase("", "Repeat passphrase: ")
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(passphrase, confirm) {
+			return errors.New("passphrases not match")
+		}
+	}
+	if len(passphrase) == 0 {
+		return errors.New("empty passphrase")
+	}
+	key, err := ks.Import(account, passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("address:\t%s\n", key.Address)
+	return nil
+}
+
+func exportKeyCmd(c *cli.Context) error {
+	ks, err := keystore.NewKeystore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	key, err := ks.Read(c.String("keystore"))
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase(c.String("passphrase-file"), fmt.Sprintf("Passphrase for %s: ", key.Address))
+	if err != nil {
+		return err
+	}
+	account, err := key.Decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("address:\t%s\n", account.String())
+	fmt.Printf("view key:\t%s\n", account.PrivateViewKey.String())
+	fmt.Printf("spend key:\t%s\n", account.PrivateSpendKey.String())
 	return nil
 }
 
+func listKeysCmd(c *cli.Context) error {
+	ks, err := keystore.NewKeystore(c.String("dir"))
+	if err != nil {
+		return err
+	}
+	addresses, err := ks.List()
+	if err != nil {
+		return err
+	}
+	for _, a := range addresses {
+		fmt.Println(a)
+	}
+	return nil
+}
+
+func unlockKernelSigner(dir string) error {
+	if config.Custom.Signer.HasValue() || config.Custom.SignerKeystore == "" {
+		return nil
+	}
+	path, passphraseFile := config.Custom.SignerKeystore, config.Custom.SignerPassphraseFile
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	if passphraseFile != "" && !filepath.IsAbs(passphraseFile) {
+		passphraseFile = filepath.Join(dir, passphraseFile)
+	}
+	key, err := keystore.ReadEncryptedKey(path)
+	if err != nil {
+		return err
+	}
+	passphrase, err := readPassphrase(passphraseFile, fmt.Sprintf("Passphrase for kernel signer %s: ", key.Address))
+	if err != nil {
+		return err
+	}
+	account, err := key.Decrypt(passphrase)
+	if err != nil {
+		return err
+	}
+	config.Custom.Signer = account.PrivateSpendKey
+	return nil
+}
+
+func parseAccountKey(k string) (common.Address, error) {
+	var account common.Address
+	key, err := hex.DecodeString(k)
+	if err != nil {
+		return account, err
+	}
+	if len(key) != 64 {
+		return account, fmt.Errorf("invalid key length %d", len(key))
+	}
+	copy(account.PrivateViewKey[:], key[:32])
+	copy(account.PrivateSpendKey[:], key[32:])
+	return account, nil
+}
+
 func sendTransactionCmd(c *cli.Context) error {
 	data, err := callRPC(c.String("node"), "sendrawtransaction", []interface{}{
 		c.String("raw"),
@@ -213,30 +537,48 @@ func sendTransactionCmd(c *cli.Context) error {
 	return err
 }
 
+func validateTransactionCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "validaterawtransaction", []interface{}{
+		c.String("raw"),
+	})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
 func cancelNodeCmd(c *cli.Context) error {
 	seed := make([]byte, 64)
 	_, err := rand.Read(seed)
 	if err != nil {
 		return err
 	}
-	viewKey, err := crypto.KeyFromString(c.String("view"))
-	if err != nil {
-		return err
-	}
-	spendKey, err := crypto.KeyFromString(c.String("spend"))
-	if err != nil {
-		return err
+	var account common.Address
+	if ref := c.String("keystore"); ref != "" {
+		account, err = unlockKeystoreAccount(c.String("dir"), ref, c.String("passphrase-file"))
+		if err != nil {
+			return err
+		}
+	} else {
+		viewKey, err := crypto.KeyFromString(c.String("view"))
+		if err != nil {
+			return err
+		}
+		spendKey, err := crypto.KeyFromString(c.String("spend"))
+		if err != nil {
+			return err
+		}
+		account = common.Address{
+			PrivateViewKey:  viewKey,
+			PrivateSpendKey: spendKey,
+			PublicViewKey:   viewKey.Public(),
+			PublicSpendKey:  spendKey.Public(),
+		}
 	}
 	receiver, err := common.NewAddressFromString(c.String("receiver"))
 	if err != nil {
 		return err
 	}
-	account := common.Address{
-		PrivateViewKey:  viewKey,
-		PrivateSpendKey: spendKey,
-		PublicViewKey:   viewKey.Public(),
-		PublicSpendKey:  spendKey.Public(),
-	}
 	if account.String() != receiver.String() {
 		return fmt.Errorf("invalid key and receiver %s %s", account, receiver)
 	}
@@ -271,7 +613,7 @@ func cancelNodeCmd(c *cli.Context) error {
 	if len(source.Outputs) != 1 || len(source.Outputs[0].Keys) != 1 {
 		return fmt.Errorf("invalid source transaction outputs %d %d", len(source.Outputs), len(source.Outputs[0].Keys))
 	}
-	pig := crypto.ViewGhostOutputKey(&source.Outputs[0].Keys[0], &viewKey, &source.Outputs[0].Mask, 0)
+	pig := crypto.ViewGhostOutputKey(&source.Outputs[0].Keys[0], &account.PrivateViewKey, &source.Outputs[0].Mask, 0)
 	if pig.String() != receiver.PublicSpendKey.String() {
 		return fmt.Errorf("invalid source and receiver %s %s", pig.String(), receiver.PublicSpendKey)
 	}
@@ -280,7 +622,7 @@ func cancelNodeCmd(c *cli.Context) error {
 	tx.AddInput(pledge.PayloadHash(), 0)
 	tx.AddOutputWithType(common.OutputTypeNodeCancel, nil, common.Script{}, pledge.Outputs[0].Amount.Div(100), seed)
 	tx.AddScriptOutput([]common.Address{receiver}, common.NewThresholdScript(1), pledge.Outputs[0].Amount.Sub(tx.Outputs[0].Amount), seed)
-	tx.Extra = append(pledge.Extra, viewKey[:]...)
+	tx.Extra = append(pledge.Extra, account.PrivateViewKey[:]...)
 	utxo := &common.UTXO{
 		Input: common.Input{
 			Hash:  pledge.PayloadHash(),
@@ -301,6 +643,52 @@ func cancelNodeCmd(c *cli.Context) error {
 	return nil
 }
 
+func departNodeCmd(c *cli.Context) error {
+	var signer crypto.Key
+	if ref := c.String("keystore"); ref != "" {
+		account, err := unlockKeystoreAccount(c.String("dir"), ref, c.String("passphrase-file"))
+		if err != nil {
+			return err
+		}
+		signer = account.PrivateSpendKey
+	} else {
+		key, err := crypto.KeyFromString(c.String("signer"))
+		if err != nil {
+			return err
+		}
+		signer = key
+	}
+
+	b, err := hex.DecodeString(c.String("accept"))
+	if err != nil {
+		return err
+	}
+	accept, err := common.UnmarshalVersionedTransaction(b)
+	if err != nil {
+		return err
+	}
+	if len(accept.Outputs) != 1 || accept.Outputs[0].Type != common.OutputTypeNodeAccept {
+		return fmt.Errorf("invalid accept transaction outputs %d", len(accept.Outputs))
+	}
+	publicSpend := signer.Public()
+	if len(accept.Extra) != len(publicSpend)*2 {
+		return fmt.Errorf("invalid accept transaction extra %s", hex.EncodeToString(accept.Extra))
+	}
+	if bytes.Compare(publicSpend[:], accept.Extra[:len(publicSpend)]) != 0 {
+		return fmt.Errorf("invalid accept transaction signer %s %s", hex.EncodeToString(accept.Extra[:len(publicSpend)]), publicSpend)
+	}
+
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.AddInput(accept.PayloadHash(), 0)
+	tx.AddOutputWithType(common.OutputTypeNodeDepart, nil, common.Script{}, accept.Outputs[0].Amount, []byte{})
+	tx.Extra = accept.Extra
+	signed := tx.AsLatestVersion()
+	sig := signer.Sign(signed.PayloadMarshal())
+	signed.Signatures = [][]crypto.Signature{{sig}}
+	fmt.Println(hex.EncodeToString(signed.Marshal()))
+	return nil
+}
+
 func decodePledgeNodeCmd(c *cli.Context) error {
 	b, err := hex.DecodeString(c.String("raw"))
 	if err != nil {
@@ -410,6 +798,35 @@ func getUTXOCmd(c *cli.Context) error {
 	return err
 }
 
+func getUTXOProofCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getutxoproof", []interface{}{
+		c.String("hash"),
+		c.Uint64("index"),
+	})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getTransactionProofCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "gettransactionproof", []interface{}{
+		c.String("hash"),
+	})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listNodeProofsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listnodeproofs", []interface{}{})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
 func listMintDistributionsCmd(c *cli.Context) error {
 	data, err := callRPC(c.String("node"), "listmintdistributions", []interface{}{
 		c.Uint64("since"),
@@ -422,6 +839,67 @@ func listMintDistributionsCmd(c *cli.Context) error {
 	return err
 }
 
+func listCheckpointsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listcheckpoints", []interface{}{
+		c.Uint64("since"),
+		c.Uint64("count"),
+	})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getRoundGraphCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getroundgraph", []interface{}{
+		c.Uint64("from"),
+		c.Uint64("to"),
+		c.Bool("time"),
+		c.String("format"),
+	})
+	if err != nil {
+		return err
+	}
+	if c.String("format") != "dot" {
+		fmt.Println(string(data))
+		return nil
+	}
+	var graph struct {
+		DOT string `json:"dot"`
+	}
+	err = json.Unmarshal(data, &graph)
+	if err == nil {
+		fmt.Print(graph.DOT)
+	}
+	return err
+}
+
+func listPendingTransactionsCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listpendingtransactions", []interface{}{})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func getMempoolInfoCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "getmempoolinfo", []interface{}{})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
+func listEvidencesCmd(c *cli.Context) error {
+	data, err := callRPC(c.String("node"), "listevidences", []interface{}{
+		c.String("id"),
+	})
+	if err == nil {
+		fmt.Println(string(data))
+	}
+	return err
+}
+
 func getInfoCmd(c *cli.Context) error {
 	data, err := callRPC(c.String("node"), "getinfo", []interface{}{})
 	if err == nil {
@@ -431,6 +909,10 @@ func getInfoCmd(c *cli.Context) error {
 }
 
 func setupTestNetCmd(c *cli.Context) error {
+	err := setAddressNetwork(c)
+	if err != nil {
+		return err
+	}
 	var signers, payees []common.Address
 
 	randomPubAccount := func() common.Address {
@@ -458,8 +940,9 @@ func setupTestNetCmd(c *cli.Context) error {
 		})
 	}
 	genesis := map[string]interface{}{
-		"epoch": time.Now().Unix(),
-		"nodes": inputs,
+		"epoch":          time.Now().Unix(),
+		"address-prefix": common.AddressNetworkId(),
+		"nodes":          inputs,
 		"domains": []map[string]string{
 			{
 				"signer":  signers[0].String(),
@@ -532,6 +1015,156 @@ func setupTestNetCmd(c *cli.Context) error {
 
 var httpClient *http.Client
 
+func walletScanCmd(c *cli.Context) error {
+	w, err := wallet.NewWallet(c.String("dir"), rpcSnapshotReader{node: c.String("node")})
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if a := c.String("address"); len(a) > 0 {
+		account, err := parseWalletAccount(a, c.String("view"))
+		if err != nil {
+			return err
+		}
+		err = w.AddAccount(account)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		n, err := w.Scan(c.Uint64("batch"))
+		if err != nil {
+			return err
+		}
+		cursor, err := w.Cursor()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("scanned %d snapshots to topology %d\n", n, cursor)
+		if uint64(n) < c.Uint64("batch") {
+			return nil
+		}
+	}
+}
+
+func walletBalanceCmd(c *cli.Context) error {
+	addr, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	w, err := wallet.NewWallet(c.String("dir"), nil)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	balance, err := w.Balance(addr.PublicSpendKey)
+	if err != nil {
+		return err
+	}
+	assets := make(map[string]common.Integer)
+	for a, b := range balance {
+		assets[a.String()] = b
+	}
+	data, err := json.MarshalIndent(assets, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func walletHistoryCmd(c *cli.Context) error {
+	addr, err := common.NewAddressFromString(c.String("address"))
+	if err != nil {
+		return err
+	}
+	w, err := wallet.NewWallet(c.String("dir"), nil)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	history, err := w.ListHistory(addr.PublicSpendKey, c.Uint64("offset"), c.Uint64("count"))
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+func parseWalletAccount(address, view string) (common.Address, error) {
+	account, err := common.NewAddressFromString(address)
+	if err != nil {
+		return account, err
+	}
+	key, err := crypto.KeyFromString(view)
+	if err != nil {
+		return account, err
+	}
+	if key.Public() != account.PublicViewKey {
+		return account, fmt.Errorf("invalid view key for address %s", address)
+	}
+	account.PrivateViewKey = key
+	return account, nil
+}
+
+type rpcSnapshotReader struct {
+	node string
+}
+
+func (r rpcSnapshotReader) ReadSnapshotWithTransactionsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error) {
+	data, err := callRPC(r.node, "listsnapshots", []interface{}{offset, count, false, true})
+	if err != nil {
+		return nil, nil, err
+	}
+	var items []struct {
+		Hash        crypto.Hash `json:"hash"`
+		Node        crypto.Hash `json:"node"`
+		Round       uint64      `json:"round"`
+		Timestamp   uint64      `json:"timestamp"`
+		Topology    uint64      `json:"topology"`
+		Transaction struct {
+			Hex string `json:"hex"`
+		} `json:"transaction"`
+	}
+	err = json.Unmarshal(data, &items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	snapshots := make([]*common.SnapshotWithTopologicalOrder, len(items))
+	transactions := make([]*common.VersionedTransaction, len(items))
+	for i, item := range items {
+		raw, err := hex.DecodeString(item.Transaction.Hex)
+		if err != nil {
+			return nil, nil, err
+		}
+		ver, err := common.UnmarshalVersionedTransaction(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		snapshots[i] = &common.SnapshotWithTopologicalOrder{
+			Snapshot: common.Snapshot{
+				NodeId:      item.Node,
+				Transaction: ver.PayloadHash(),
+				RoundNumber: item.Round,
+				Timestamp:   item.Timestamp,
+				Hash:        item.Hash,
+			},
+			TopologicalOrder: item.Topology,
+		}
+		transactions[i] = ver
+	}
+	return snapshots, transactions, nil
+}
+
 func callRPC(node, method string, params []interface{}) ([]byte, error) {
 	if httpClient == nil {
 		httpClient = &http.Client{Timeout: 60 * time.Second}
@@ -565,6 +1198,7 @@ func callRPC(node, method string, params []interface{}) ([]byte, error) {
 	var result struct {
 		Data  interface{} `json:"data"`
 		Error interface{} `json:"error"`
+		Code  interface{} `json:"code"`
 	}
 	dec := json.NewDecoder(resp.Body)
 	dec.UseNumber()
@@ -572,7 +1206,9 @@ func callRPC(node, method string, params []interface{}) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	if result.Error != nil {
+	if result.Error != nil && result.Code != nil {
+		return nil, fmt.Errorf("ERROR %s %s", result.Code, result.Error)
+	} else if result.Error != nil {
 		return nil, fmt.Errorf("ERROR %s", result.Error)
 	}
 
@@ -581,30 +1217,97 @@ func callRPC(node, method string, params []interface{}) ([]byte, error) {
 
 type signerInput struct {
 	Inputs []struct {
-		Hash    crypto.Hash         `json:"hash"`
-		Index   int                 `json:"index"`
-		Deposit *common.DepositData `json:"deposit,omitempty"`
-		Keys    []crypto.Key        `json:"keys"`
-		Mask    crypto.Key          `json:"mask"`
+		Hash     crypto.Hash         `json:"hash"`
+		Index    int                 `json:"index"`
+		Deposit  *common.DepositData `json:"deposit,omitempty"`
+		Keys     []crypto.Key        `json:"keys"`
+		Mask     crypto.Key          `json:"mask"`
+		Script   common.Script       `json:"script,omitempty"`
+		Amount   common.Integer      `json:"amount,omitempty"`
+		Preimage string              `json:"preimage,omitempty"`
 	} `json:"inputs"`
 	Outputs []struct {
 		Type     uint8            `json:"type"`
 		Script   common.Script    `json:"script"`
 		Accounts []common.Address `json:"accounts"`
 		Amount   common.Integer   `json:"amount"`
+		Locktime uint64           `json:"locktime,omitempty"`
+		Hashlock crypto.Hash      `json:"hashlock,omitempty"`
+		Refunds  []common.Address `json:"refunds,omitempty"`
 	}
 	Asset crypto.Hash `json:"asset"`
 	Extra string      `json:"extra"`
 	Node  string      `json:"-"`
 }
 
+func (raw signerInput) buildTransaction(seedHex string) (*common.Transaction, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil {
+		return nil, err
+	}
+	if len(seed) != 64 {
+		seed = make([]byte, 64)
+		_, err := rand.Read(seed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx := common.NewTransaction(raw.Asset)
+	for _, in := range raw.Inputs {
+		if in.Deposit != nil {
+			tx.AddDepositInput(in.Deposit)
+		} else if len(in.Preimage) > 0 {
+			preimage, err := hex.DecodeString(in.Preimage)
+			if err != nil {
+				return nil, err
+			}
+			tx.AddHashLockInput(in.Hash, in.Index, preimage)
+		} else {
+			tx.AddInput(in.Hash, in.Index)
+		}
+	}
+
+	for _, out := range raw.Outputs {
+		if out.Locktime > 0 || out.Hashlock.HasValue() {
+			err := out.Script.VerifyFormat()
+			if err != nil {
+				return nil, err
+			}
+		}
+		hash := crypto.NewHash(seed)
+		seed = append(hash[:], hash[:]...)
+		if out.Hashlock.HasValue() {
+			tx.AddHashLockOutput(out.Accounts, out.Refunds, out.Script[2], out.Hashlock, out.Locktime, out.Amount, seed)
+			continue
+		}
+		if out.Locktime > 0 {
+			out.Script = common.NewTimelockScript(out.Script[2], out.Locktime)
+		}
+		tx.AddOutputWithType(out.Type, out.Accounts, out.Script, out.Amount, seed)
+	}
+
+	extra, err := hex.DecodeString(raw.Extra)
+	if err != nil {
+		return nil, err
+	}
+	tx.Extra = extra
+	return tx, nil
+}
+
 func (raw signerInput) ReadUTXO(hash crypto.Hash, index int) (*common.UTXOWithLock, error) {
 	utxo := &common.UTXOWithLock{}
 
 	for _, in := range raw.Inputs {
 		if in.Hash == hash && in.Index == index && len(in.Keys) > 0 {
+			utxo.Hash = in.Hash
+			utxo.Index = in.Index
+			utxo.Type = common.OutputTypeScript
+			utxo.Amount = in.Amount
 			utxo.Keys = in.Keys
+			utxo.Script = in.Script
 			utxo.Mask = in.Mask
+			utxo.Asset = raw.Asset
 			return utxo, nil
 		}
 	}
@@ -621,9 +1324,7 @@ func (raw signerInput) ReadUTXO(hash crypto.Hash, index int) (*common.UTXOWithLo
 	if out.Amount.Sign() == 0 {
 		return nil, fmt.Errorf("invalid input %s#%d", hash.String(), index)
 	}
-	utxo.Keys = out.Keys
-	utxo.Mask = out.Mask
-	return utxo, nil
+	return &out, nil
 }
 
 func (raw signerInput) CheckDepositInput(deposit *common.DepositData, tx crypto.Hash) error {
@@ -638,10 +1339,14 @@ func transactionToMap(tx *common.VersionedTransaction) map[string]interface{} {
 	var inputs []map[string]interface{}
 	for _, in := range tx.Inputs {
 		if in.Hash.HasValue() {
-			inputs = append(inputs, map[string]interface{}{
+			input := map[string]interface{}{
 				"hash":  in.Hash,
 				"index": in.Index,
-			})
+			}
+			if len(in.Preimage) > 0 {
+				input["preimage"] = hex.EncodeToString(in.Preimage)
+			}
+			inputs = append(inputs, input)
 		} else if len(in.Genesis) > 0 {
 			inputs = append(inputs, map[string]interface{}{
 				"genesis": hex.EncodeToString(in.Genesis),
@@ -669,6 +1374,15 @@ func transactionToMap(tx *common.VersionedTransaction) map[string]interface{} {
 		if len(out.Script) > 0 {
 			output["script"] = out.Script
 		}
+		if lock := out.Script.Locktime(); lock > 0 {
+			output["locktime"] = lock
+		}
+		if out.Script.IsHashLock() {
+			lock, refund, recipients := out.Script.HashLock()
+			output["hashlock"] = lock
+			output["locktime"] = refund
+			output["recipients"] = recipients
+		}
 		if out.Mask.HasValue() {
 			output["mask"] = out.Mask
 		}
@@ -3,14 +3,41 @@ package common
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/btcsuite/btcutil/base58"
 )
 
-const MainNetworkId = "XIN"
+const (
+	MainNetworkId = "XIN"
+	TestNetworkId = "XTN"
+)
+
+var addressNetworkId = MainNetworkId
+
+func SetAddressNetworkId(network string) error {
+	if config.NetworkSealed() {
+		return fmt.Errorf("address network sealed %s", addressNetworkId)
+	}
+	if len(network) != 3 {
+		return fmt.Errorf("invalid address network %s", network)
+	}
+	for _, c := range network {
+		if c < 'A' || c > 'Z' {
+			return fmt.Errorf("invalid address network %s", network)
+		}
+	}
+	addressNetworkId = network
+	return nil
+}
+
+func AddressNetworkId() string {
+	return addressNetworkId
+}
 
 type Address struct {
 	PrivateSpendKey crypto.Key
@@ -35,15 +62,19 @@ func NewAddressFromSeed(seed []byte) Address {
 }
 
 func NewAddressFromString(s string) (Address, error) {
+	return NewAddressFromStringForNetwork(s, addressNetworkId)
+}
+
+func NewAddressFromStringForNetwork(s, network string) (Address, error) {
 	var a Address
-	if !strings.HasPrefix(s, MainNetworkId) {
-		return a, errors.New("invalid address network")
+	if !strings.HasPrefix(s, network) {
+		return a, fmt.Errorf("invalid address network %s", network)
 	}
-	data := base58.Decode(s[len(MainNetworkId):])
+	data := base58.Decode(s[len(network):])
 	if len(data) != 68 {
 		return a, errors.New("invalid address format")
 	}
-	checksum := crypto.NewHash(append([]byte(MainNetworkId), data[:64]...))
+	checksum := crypto.NewHash(append([]byte(network), data[:64]...))
 	if !bytes.Equal(checksum[:4], data[64:]) {
 		return a, errors.New("invalid address checksum")
 	}
@@ -53,12 +84,16 @@ func NewAddressFromString(s string) (Address, error) {
 }
 
 func (a Address) String() string {
-	data := append([]byte(MainNetworkId), a.PublicSpendKey[:]...)
+	return a.StringForNetwork(addressNetworkId)
+}
+
+func (a Address) StringForNetwork(network string) string {
+	data := append([]byte(network), a.PublicSpendKey[:]...)
 	data = append(data, a.PublicViewKey[:]...)
 	checksum := crypto.NewHash(data)
 	data = append(a.PublicSpendKey[:], a.PublicViewKey[:]...)
 	data = append(data, checksum[:4]...)
-	return MainNetworkId + base58.Encode(data)
+	return network + base58.Encode(data)
 }
 
 func (a Address) Hash() crypto.Hash {
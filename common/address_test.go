@@ -49,3 +49,39 @@ func TestAddress(t *testing.T) {
 	z := NewAddressFromSeed(make([]byte, 64))
 	assert.Equal("XIN8b7CsqwqaBP7576hvWzo7uDgbU9TB5KGU4jdgYpQTi2qrQGpBtrW49ENQiLGNrYU45e2wwKRD7dEUPtuaJYps2jbR4dH", z.String())
 }
+
+func TestAddressNetwork(t *testing.T) {
+	assert := assert.New(t)
+	defer SetAddressNetworkId(MainNetworkId)
+
+	seed := make([]byte, 64)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i + 1)
+	}
+	a := NewAddressFromSeed(seed)
+	main := a.String()
+	test := a.StringForNetwork(TestNetworkId)
+	assert.Equal("XTN", test[:3])
+	assert.Equal(main[3:], a.StringForNetwork(MainNetworkId)[3:])
+	assert.NotEqual(main[3:], test[3:])
+
+	_, err := NewAddressFromString(test)
+	assert.NotNil(err)
+	_, err = NewAddressFromStringForNetwork("XTN"+main[3:], TestNetworkId)
+	assert.NotNil(err)
+	b, err := NewAddressFromStringForNetwork(test, TestNetworkId)
+	assert.Nil(err)
+	assert.Equal(a.PublicSpendKey, b.PublicSpendKey)
+	assert.Equal(a.PublicViewKey, b.PublicViewKey)
+
+	assert.NotNil(SetAddressNetworkId("xtn"))
+	assert.NotNil(SetAddressNetworkId("XTNX"))
+	assert.Equal(MainNetworkId, AddressNetworkId())
+	assert.Nil(SetAddressNetworkId(TestNetworkId))
+	assert.Equal(test, a.String())
+	_, err = NewAddressFromString(main)
+	assert.NotNil(err)
+	b, err = NewAddressFromString(test)
+	assert.Nil(err)
+	assert.Equal(a.PublicSpendKey, b.PublicSpendKey)
+}
@@ -0,0 +1,203 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	SelectionLargestFirst = iota
+	SelectionMinimizeInputs
+	SelectionConsolidateDust
+)
+
+type Recipient struct {
+	Accounts  []Address
+	Threshold uint8
+	Amount    Integer
+}
+
+type TransactionBuilder struct {
+	Asset      crypto.Hash
+	UTXOs      []*UTXO
+	Recipients []*Recipient
+	Change     Address
+	Extra      []byte
+	Strategy   int
+	Dust       Integer
+	Timestamp  uint64
+}
+
+func NewTransactionBuilder(asset crypto.Hash, utxos []*UTXO, change Address) *TransactionBuilder {
+	return &TransactionBuilder{
+		Asset:    asset,
+		UTXOs:    utxos,
+		Change:   change,
+		Strategy: SelectionLargestFirst,
+		Dust:     NewInteger(0),
+	}
+}
+
+func (b *TransactionBuilder) AddRecipient(accounts []Address, threshold uint8, amount Integer) {
+	b.Recipients = append(b.Recipients, &Recipient{
+		Accounts:  accounts,
+		Threshold: threshold,
+		Amount:    amount,
+	})
+}
+
+func (b *TransactionBuilder) Build() (*Transaction, error) {
+	if len(b.Recipients) == 0 {
+		return nil, fmt.Errorf("invalid recipients count %d", len(b.Recipients))
+	}
+	if len(b.Extra) > ExtraSizeLimit {
+		return nil, fmt.Errorf("invalid extra size %d", len(b.Extra))
+	}
+
+	target := NewInteger(0)
+	for _, r := range b.Recipients {
+		if r.Amount.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid recipient amount %s", r.Amount.String())
+		}
+		if r.Threshold == 0 || int(r.Threshold) > len(r.Accounts) {
+			return nil, fmt.Errorf("invalid recipient threshold %d/%d", r.Threshold, len(r.Accounts))
+		}
+		target = target.Add(r.Amount)
+	}
+
+	candidates := b.spendableUTXOs()
+	inputs, err := b.selectInputs(candidates, target)
+	if err != nil {
+		return nil, err
+	}
+	if b.Strategy == SelectionConsolidateDust {
+		inputs = b.consolidateDust(candidates, inputs, target)
+	}
+
+	tx, err := b.buildTransaction(inputs, target)
+	if err != nil {
+		return nil, err
+	}
+	if size := estimateSignedSize(tx, inputs); size > config.TransactionMaximumSize {
+		return nil, fmt.Errorf("invalid transaction size %d", size)
+	}
+	return tx, nil
+}
+
+func (b *TransactionBuilder) spendableUTXOs() []*UTXO {
+	filter := make(map[string]bool)
+	utxos := make([]*UTXO, 0)
+	for _, u := range b.UTXOs {
+		if u.Asset != b.Asset || (u.Type != OutputTypeScript && u.Type != OutputTypeNodeRemove) || u.Amount.Sign() <= 0 {
+			continue
+		}
+		if u.Script.VerifyFormat() != nil || u.Script.IsHashLock() || u.Script.Locktime() > b.Timestamp {
+			continue
+		}
+		fk := fmt.Sprintf("%s:%d", u.Hash.String(), u.Index)
+		if filter[fk] {
+			continue
+		}
+		filter[fk] = true
+		utxos = append(utxos, u)
+	}
+	return utxos
+}
+
+func (b *TransactionBuilder) selectInputs(utxos []*UTXO, target Integer) ([]*UTXO, error) {
+	sorted := make([]*UTXO, len(utxos))
+	copy(sorted, utxos)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Amount.Cmp(sorted[j].Amount) > 0
+	})
+
+	switch b.Strategy {
+	case SelectionLargestFirst:
+	case SelectionMinimizeInputs:
+		for i := len(sorted) - 1; i >= 0; i-- {
+			if sorted[i].Amount.Cmp(target) >= 0 {
+				return []*UTXO{sorted[i]}, nil
+			}
+		}
+	case SelectionConsolidateDust:
+		sort.SliceStable(sorted, func(i, j int) bool {
+			return sorted[i].Amount.Cmp(sorted[j].Amount) < 0
+		})
+	default:
+		return nil, fmt.Errorf("invalid selection strategy %d", b.Strategy)
+	}
+
+	total := NewInteger(0)
+	inputs := make([]*UTXO, 0)
+	for _, u := range sorted {
+		if total.Cmp(target) >= 0 {
+			break
+		}
+		inputs = append(inputs, u)
+		total = total.Add(u.Amount)
+	}
+	if total.Cmp(target) < 0 {
+		return nil, fmt.Errorf("insufficient balance %s %s", total.String(), target.String())
+	}
+	return inputs, nil
+}
+
+func (b *TransactionBuilder) consolidateDust(utxos, inputs []*UTXO, target Integer) []*UTXO {
+	selected := make(map[*UTXO]bool)
+	for _, u := range inputs {
+		selected[u] = true
+	}
+	tx, err := b.buildTransaction(inputs, target)
+	if err != nil {
+		return inputs
+	}
+	// reserve some bytes for the msgpack array headers and change output growth
+	size := estimateSignedSize(tx, inputs) + 64
+	for _, u := range utxos {
+		if selected[u] || u.Amount.Cmp(b.Dust) >= 0 {
+			continue
+		}
+		in := MsgpackMarshalPanic(&Input{Hash: u.Hash, Index: u.Index})
+		sigs := MsgpackMarshalPanic(make([]crypto.Signature, u.Script[2]))
+		size = size + len(in) + len(sigs)
+		if size > config.TransactionMaximumSize {
+			break
+		}
+		inputs = append(inputs, u)
+	}
+	return inputs
+}
+
+func (b *TransactionBuilder) buildTransaction(inputs []*UTXO, target Integer) (*Transaction, error) {
+	tx := NewTransaction(b.Asset)
+	total := NewInteger(0)
+	for _, u := range inputs {
+		tx.AddInput(u.Hash, u.Index)
+		total = total.Add(u.Amount)
+	}
+	for _, r := range b.Recipients {
+		err := tx.AddRandomScriptOutput(r.Accounts, NewThresholdScript(r.Threshold), r.Amount)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if change := total.Sub(target); change.Sign() > 0 {
+		err := tx.AddRandomScriptOutput([]Address{b.Change}, NewThresholdScript(1), change)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tx.Extra = b.Extra
+	return tx, nil
+}
+
+func estimateSignedSize(tx *Transaction, inputs []*UTXO) int {
+	signed := &SignedTransaction{Transaction: *tx}
+	for _, u := range inputs {
+		signed.Signatures = append(signed.Signatures, make([]crypto.Signature, u.Script[2]))
+	}
+	return len(signed.AsLatestVersion().Marshal())
+}
@@ -0,0 +1,176 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionBuilder(t *testing.T) {
+	assert := assert.New(t)
+
+	receiver, change := randomAccount(), randomAccount()
+	utxos := []*UTXO{
+		builderUTXO(0, "10", NewThresholdScript(1)),
+		builderUTXO(1, "3", NewThresholdScript(1)),
+		builderUTXO(2, "0.5", NewThresholdScript(1)),
+		builderUTXO(3, "0.2", NewThresholdScript(1)),
+		builderUTXO(4, "6", NewThresholdScript(2)),
+	}
+
+	b := NewTransactionBuilder(XINAssetId, utxos, change)
+	_, err := b.Build()
+	assert.NotNil(err)
+
+	b.AddRecipient([]Address{receiver}, 1, NewIntegerFromString("4"))
+	tx, err := b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Inputs, 1)
+	assert.Equal(0, tx.Inputs[0].Index)
+	assert.Len(tx.Outputs, 2)
+	assert.Equal("4.00000000", tx.Outputs[0].Amount.String())
+	assert.Equal("6.00000000", tx.Outputs[1].Amount.String())
+	assert.Equal(NewThresholdScript(1), tx.Outputs[0].Script)
+	assertBuilderOutputs(assert, tx, receiver, change)
+
+	b.Strategy = SelectionMinimizeInputs
+	tx, err = b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Inputs, 1)
+	assert.Equal(4, tx.Inputs[0].Index)
+	assert.Equal("2.00000000", tx.Outputs[1].Amount.String())
+
+	b.Strategy = SelectionConsolidateDust
+	tx, err = b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Inputs, 4)
+	assert.Equal(3, tx.Inputs[0].Index)
+	assert.Equal(2, tx.Inputs[1].Index)
+	assert.Equal(1, tx.Inputs[2].Index)
+	assert.Equal(4, tx.Inputs[3].Index)
+	assert.Equal("5.70000000", tx.Outputs[1].Amount.String())
+
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver}, 1, NewIntegerFromString("0.1"))
+	b.Dust = NewIntegerFromString("1")
+	tx, err = b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Inputs, 2)
+	assert.Equal(3, tx.Inputs[0].Index)
+	assert.Equal(2, tx.Inputs[1].Index)
+	assert.Equal("0.60000000", tx.Outputs[1].Amount.String())
+
+	b.Strategy = SelectionMinimizeInputs
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver}, 1, NewIntegerFromString("19.7"))
+	tx, err = b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Inputs, 5)
+	assert.Len(tx.Outputs, 1)
+
+	b.Strategy = 10
+	_, err = b.Build()
+	assert.NotNil(err)
+}
+
+func TestTransactionBuilderValidation(t *testing.T) {
+	assert := assert.New(t)
+
+	receiver, change := randomAccount(), randomAccount()
+	utxos := []*UTXO{
+		builderUTXO(0, "10", NewThresholdScript(1)),
+		builderUTXO(0, "10", NewThresholdScript(1)),
+		builderUTXO(1, "3", NewTimelockScript(1, uint64(time.Now().Add(time.Hour).UnixNano()))),
+		builderUTXO(2, "3", NewHashLockScript(1, crypto.NewHash([]byte("lock")), 0, 1)),
+		builderUTXO(3, "3", Script{OperatorCmp}),
+	}
+	other := builderUTXO(4, "100", NewThresholdScript(1))
+	other.Asset = crypto.NewHash([]byte("other"))
+	utxos = append(utxos, other)
+
+	b := NewTransactionBuilder(XINAssetId, utxos, change)
+	b.AddRecipient([]Address{receiver}, 1, NewIntegerFromString("10.1"))
+	_, err := b.Build()
+	assert.NotNil(err)
+	b.Timestamp = uint64(time.Now().Add(2 * time.Hour).UnixNano())
+	tx, err := b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Inputs, 2)
+
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver}, 1, NewIntegerFromString("10"))
+	tx, err = b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Inputs, 1)
+	assert.Len(tx.Outputs, 1)
+
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver}, 0, NewIntegerFromString("1"))
+	_, err = b.Build()
+	assert.NotNil(err)
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver}, 2, NewIntegerFromString("1"))
+	_, err = b.Build()
+	assert.NotNil(err)
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver}, 1, NewInteger(0))
+	_, err = b.Build()
+	assert.NotNil(err)
+
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver, change}, 2, NewIntegerFromString("1"))
+	b.Extra = make([]byte, ExtraSizeLimit+1)
+	_, err = b.Build()
+	assert.NotNil(err)
+	b.Extra = make([]byte, ExtraSizeLimit)
+	tx, err = b.Build()
+	assert.Nil(err)
+	assert.Len(tx.Outputs[0].Keys, 2)
+	assert.Equal(NewThresholdScript(2), tx.Outputs[0].Script)
+
+	var dust []*UTXO
+	for i := 0; i < config.TransactionMaximumSize/64; i++ {
+		dust = append(dust, builderUTXO(i, "0.00000001", NewThresholdScript(1)))
+	}
+	b = NewTransactionBuilder(XINAssetId, dust, change)
+	b.AddRecipient([]Address{receiver}, 1, NewIntegerFromString("0.00016384"))
+	_, err = b.Build()
+	assert.NotNil(err)
+	b.Recipients = nil
+	b.AddRecipient([]Address{receiver}, 1, NewIntegerFromString("0.00000002"))
+	b.Strategy = SelectionConsolidateDust
+	b.Dust = NewIntegerFromString("0.00000002")
+	tx, err = b.Build()
+	assert.Nil(err)
+	assert.True(len(tx.Inputs) > 2)
+	assert.True(len(tx.Inputs) < len(dust))
+	assert.True(estimateSignedSize(tx, dust[:len(tx.Inputs)]) <= config.TransactionMaximumSize)
+}
+
+func builderUTXO(index int, amount string, script Script) *UTXO {
+	return &UTXO{
+		Input: Input{
+			Hash:  crypto.NewHash([]byte("builder")),
+			Index: index,
+		},
+		Output: Output{
+			Type:   OutputTypeScript,
+			Amount: NewIntegerFromString(amount),
+			Script: script,
+			Mask:   crypto.NewKeyFromSeed(bytes.Repeat([]byte{1}, 64)).Public(),
+		},
+		Asset: XINAssetId,
+	}
+}
+
+func assertBuilderOutputs(assert *assert.Assertions, tx *Transaction, receiver, change Address) {
+	outputs := tx.ViewGhostKey(&receiver.PrivateViewKey)
+	assert.Equal(receiver.PublicSpendKey, outputs[0].Keys[0])
+	assert.NotEqual(receiver.PublicSpendKey, outputs[1].Keys[0])
+	outputs = tx.ViewGhostKey(&change.PrivateViewKey)
+	assert.Equal(change.PublicSpendKey, outputs[1].Keys[0])
+}
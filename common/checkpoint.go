@@ -0,0 +1,96 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	CheckpointVersion = 1
+)
+
+type CheckpointRound struct {
+	NodeId crypto.Hash `json:"node"`
+	Number uint64      `json:"number"`
+	Hash   crypto.Hash `json:"hash"`
+}
+
+// the state digests of all the finalized transactions in the checkpoint rounds,
+// utxo includes all the outputs with their spent transactions and the deposits,
+// and root is the sparse merkle root of the unspent outputs for the proofs
+type CheckpointState struct {
+	UTXO    crypto.Hash `json:"utxo"`
+	Nodes   crypto.Hash `json:"nodes"`
+	Domains crypto.Hash `json:"domains"`
+	Mint    crypto.Hash `json:"mint"`
+	Root    crypto.Hash `json:"root"`
+}
+
+// Checkpoint commits to the state of all the snapshots in the final rounds of
+// each node up to Rounds, and it's signed by the consensus nodes with cosi
+type Checkpoint struct {
+	Version   uint8                 `json:"version"`
+	Number    uint64                `json:"number"`
+	Rounds    []*CheckpointRound    `json:"rounds"`
+	State     CheckpointState       `json:"state"`
+	Timestamp uint64                `json:"timestamp"`
+	Signature *crypto.CosiSignature `json:"signature,omitempty" msgpack:",omitempty"`
+}
+
+func (c *Checkpoint) PayloadHash() crypto.Hash {
+	p := Checkpoint{
+		Version:   c.Version,
+		Number:    c.Number,
+		Rounds:    c.Rounds,
+		State:     c.State,
+		Timestamp: c.Timestamp,
+	}
+	return crypto.NewHash(MsgpackMarshalPanic(p))
+}
+
+func (c *Checkpoint) RoundsMap() map[crypto.Hash]*CheckpointRound {
+	rounds := make(map[crypto.Hash]*CheckpointRound)
+	for _, r := range c.Rounds {
+		rounds[r.NodeId] = r
+	}
+	return rounds
+}
+
+func (c *Checkpoint) Validate() error {
+	if c.Version != CheckpointVersion {
+		return fmt.Errorf("invalid checkpoint version %d", c.Version)
+	}
+	if len(c.Rounds) == 0 {
+		return fmt.Errorf("invalid checkpoint without rounds")
+	}
+	for i, r := range c.Rounds {
+		if r.Number == 0 {
+			return fmt.Errorf("invalid checkpoint round %s %d", r.NodeId, r.Number)
+		}
+		if i == 0 {
+			continue
+		}
+		a, b := c.Rounds[i-1].NodeId, r.NodeId
+		if bytes.Compare(a[:], b[:]) >= 0 {
+			return fmt.Errorf("invalid checkpoint rounds order %s %s", a, b)
+		}
+	}
+	return nil
+}
+
+func (c *Checkpoint) Verify(publics []*crypto.Key, threshold int) error {
+	err := c.Validate()
+	if err != nil {
+		return err
+	}
+	if c.Signature == nil {
+		return fmt.Errorf("invalid checkpoint without signature")
+	}
+	hash := c.PayloadHash()
+	if !c.Signature.FullVerify(publics, threshold, hash[:]) {
+		return fmt.Errorf("invalid checkpoint signature %s", hash)
+	}
+	return nil
+}
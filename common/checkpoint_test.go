@@ -0,0 +1,71 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	privates := make([]*crypto.Key, 4)
+	publics := make([]*crypto.Key, len(privates))
+	for i := range privates {
+		seed := crypto.NewHash([]byte(fmt.Sprintf("checkpoint%d", i)))
+		priv := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+		pub := priv.Public()
+		privates[i] = &priv
+		publics[i] = &pub
+	}
+
+	c := &Checkpoint{
+		Version:   CheckpointVersion,
+		Number:    3,
+		Timestamp: 1600000000000000000,
+		State: CheckpointState{
+			UTXO:    crypto.NewHash([]byte("utxo")),
+			Nodes:   crypto.NewHash([]byte("nodes")),
+			Domains: crypto.NewHash([]byte("domains")),
+			Mint:    crypto.NewHash([]byte("mint")),
+		},
+	}
+	assert.NotNil(c.Validate())
+	for i := 0; i < 3; i++ {
+		id := crypto.NewHash([]byte(fmt.Sprintf("node%d", i)))
+		c.Rounds = append(c.Rounds, &CheckpointRound{NodeId: id, Number: uint64(i + 1), Hash: crypto.NewHash(id[:])})
+	}
+	sort.Slice(c.Rounds, func(i, j int) bool {
+		a, b := c.Rounds[i].NodeId, c.Rounds[j].NodeId
+		return bytes.Compare(a[:], b[:]) < 0
+	})
+	assert.Nil(c.Validate())
+	assert.Len(c.RoundsMap(), 3)
+	c.Rounds[0], c.Rounds[1] = c.Rounds[1], c.Rounds[0]
+	assert.NotNil(c.Validate())
+	c.Rounds[0], c.Rounds[1] = c.Rounds[1], c.Rounds[0]
+	c.Rounds[2].Number = 0
+	assert.NotNil(c.Validate())
+	c.Rounds[2].Number = 3
+
+	hash := c.PayloadHash()
+	assert.NotNil(c.Verify(publics, 3))
+	c.Signature = evidenceTestSign(privates, publics, []int{0, 1, 2}, hash)
+	assert.Equal(hash, c.PayloadHash())
+	assert.Nil(c.Verify(publics, 3))
+	assert.NotNil(c.Verify(publics, 4))
+
+	var decoded Checkpoint
+	err := MsgpackUnmarshal(MsgpackMarshalPanic(c), &decoded)
+	assert.Nil(err)
+	assert.Equal(hash, decoded.PayloadHash())
+	assert.Nil(decoded.Verify(publics, 3))
+
+	decoded.State.Mint = crypto.NewHash([]byte("fork"))
+	assert.NotEqual(hash, decoded.PayloadHash())
+	assert.NotNil(decoded.Verify(publics, 3))
+}
@@ -37,18 +37,18 @@ func (tx *SignedTransaction) DepositData() *DepositData {
 func (tx *SignedTransaction) verifyDepositFormat() error {
 	deposit := tx.Inputs[0].Deposit
 	if err := deposit.Asset().Verify(); err != nil {
-		return fmt.Errorf("invalid asset data %s", err.Error())
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid asset data %s", err.Error())
 	}
 	if id := deposit.Asset().AssetId(); id != tx.Asset {
-		return fmt.Errorf("invalid asset %s %s", tx.Asset, id)
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid asset %s %s", tx.Asset, id)
 	}
 
 	if deposit.Amount.Sign() <= 0 {
-		return fmt.Errorf("invalid amount %s", deposit.Amount.String())
+		return NewValidationError(ErrorCodeInvalidAmount, "invalid amount %s", deposit.Amount.String())
 	}
 
 	if deposit.OutputIndex > 1024 {
-		return fmt.Errorf("invalid output index %d", deposit.OutputIndex)
+		return NewValidationError(ErrorCodeInvalidOutput, "invalid output index %d", deposit.OutputIndex)
 	}
 
 	switch deposit.Asset().ChainId {
@@ -60,16 +60,16 @@ func (tx *SignedTransaction) verifyDepositFormat() error {
 
 func (tx *SignedTransaction) validateDeposit(store DataStore, msg []byte, payloadHash crypto.Hash) error {
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for deposit", len(tx.Inputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid inputs count %d for deposit", len(tx.Inputs))
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid outputs count %d for deposit", len(tx.Outputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid outputs count %d for deposit", len(tx.Outputs))
 	}
 	if tx.Outputs[0].Type != OutputTypeScript {
-		return fmt.Errorf("invalid deposit output type %d", tx.Outputs[0].Type)
+		return NewValidationError(ErrorCodeInvalidOutput, "invalid deposit output type %d", tx.Outputs[0].Type)
 	}
 	if len(tx.Signatures) != 1 || len(tx.Signatures[0]) != 1 {
-		return fmt.Errorf("invalid signatures count %d for deposit", len(tx.Signatures))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid signatures count %d for deposit", len(tx.Signatures))
 	}
 	err := tx.verifyDepositFormat()
 	if err != nil {
@@ -83,7 +83,7 @@ func (tx *SignedTransaction) validateDeposit(store DataStore, msg []byte, payloa
 		}
 	}
 	if !valid {
-		return fmt.Errorf("invalid domain signature for deposit")
+		return NewValidationError(ErrorCodeUnknownDomain, "invalid domain signature for deposit")
 	}
 
 	return store.CheckDepositInput(tx.Inputs[0].Deposit, payloadHash)
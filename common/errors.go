@@ -0,0 +1,89 @@
+package common
+
+import (
+	"fmt"
+)
+
+const (
+	ErrorCodeInvalidVersion         = 10001
+	ErrorCodeInvalidTransactionType = 10002
+	ErrorCodeInvalidCount           = 10003
+	ErrorCodeInvalidExtra           = 10004
+	ErrorCodeInvalidSize            = 10005
+
+	ErrorCodeInputNotFound    = 10101
+	ErrorCodeInputDuplicated  = 10102
+	ErrorCodeInputLocked      = 10103
+	ErrorCodeInvalidInputType = 10104
+	ErrorCodeInvalidAsset     = 10105
+	ErrorCodeInvalidSignature = 10106
+	ErrorCodeInputConflict    = 10107
+
+	ErrorCodeInvalidOutput   = 10201
+	ErrorCodeGhostKeyReused  = 10202
+	ErrorCodeInvalidAmount   = 10203
+	ErrorCodeAmountMismatch  = 10204
+	ErrorCodeInvalidScript   = 10301
+	ErrorCodeScriptLocked    = 10302
+	ErrorCodeInvalidPreimage = 10303
+
+	ErrorCodeUnknownDomain     = 10401
+	ErrorCodeInvalidWithdrawal = 10402
+	ErrorCodeInvalidMint       = 10501
+	ErrorCodeInvalidNodeState  = 10601
+	ErrorCodeInvalidPledge     = 10602
+)
+
+var errorCodeRules = map[int]string{
+	ErrorCodeInvalidVersion:         "invalid version",
+	ErrorCodeInvalidTransactionType: "invalid transaction type",
+	ErrorCodeInvalidCount:           "invalid count",
+	ErrorCodeInvalidExtra:           "invalid extra",
+	ErrorCodeInvalidSize:            "invalid size",
+	ErrorCodeInputNotFound:          "input not found",
+	ErrorCodeInputDuplicated:        "input duplicated",
+	ErrorCodeInputLocked:            "input locked",
+	ErrorCodeInvalidInputType:       "invalid input type",
+	ErrorCodeInvalidAsset:           "invalid asset",
+	ErrorCodeInvalidSignature:       "invalid signature",
+	ErrorCodeInputConflict:          "input conflict",
+	ErrorCodeInvalidOutput:          "invalid output",
+	ErrorCodeGhostKeyReused:         "ghost key reused",
+	ErrorCodeInvalidAmount:          "invalid amount",
+	ErrorCodeAmountMismatch:         "amount mismatch",
+	ErrorCodeInvalidScript:          "invalid script",
+	ErrorCodeScriptLocked:           "script locked",
+	ErrorCodeInvalidPreimage:        "invalid preimage",
+	ErrorCodeUnknownDomain:          "unknown domain",
+	ErrorCodeInvalidWithdrawal:      "invalid withdrawal",
+	ErrorCodeInvalidMint:            "invalid mint",
+	ErrorCodeInvalidNodeState:       "invalid node state",
+	ErrorCodeInvalidPledge:          "invalid pledge",
+}
+
+type ValidationError struct {
+	Code    int                    `json:"code"`
+	Rule    string                 `json:"rule"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func NewValidationError(code int, format string, a ...interface{}) *ValidationError {
+	return &ValidationError{
+		Code:    code,
+		Rule:    errorCodeRules[code],
+		Message: fmt.Sprintf(format, a...),
+	}
+}
+
+func (e *ValidationError) With(key string, val interface{}) *ValidationError {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = val
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,77 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidationError(t *testing.T) {
+	assert := assert.New(t)
+
+	accounts := make([]Address, 0)
+	for i := 0; i < 3; i++ {
+		seed := make([]byte, 64)
+		seed[i] = byte(i)
+		accounts = append(accounts, NewAddressFromSeed(seed))
+	}
+	store := storeImpl{seed: bytes.Repeat([]byte{1}, 64), accounts: accounts}
+	genesisHash := crypto.Hash{}
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddInput(genesisHash, 0)
+	tx.AddScriptOutput(accounts[:1], NewThresholdScript(1), NewInteger(10000), bytes.Repeat([]byte{2}, 64))
+	ver := tx.AsLatestVersion()
+	ver.Signatures = [][]crypto.Signature{{}}
+	err := ver.Validate(store, 0)
+	assert.NotNil(err)
+	ve, ok := err.(*ValidationError)
+	assert.True(ok)
+	assert.Equal(ErrorCodeInvalidSignature, ve.Code)
+	assert.Equal("invalid signature", ve.Rule)
+	assert.Equal("invalid signature keys 0 1", ve.Error())
+	assert.Equal("0000000000000000000000000000000000000000000000000000000000000000:0", ve.Details["input"])
+
+	tx = NewTransaction(XINAssetId)
+	tx.AddInput(genesisHash, 0)
+	tx.AddScriptOutput(accounts[:1], NewThresholdScript(1), NewInteger(20000), bytes.Repeat([]byte{2}, 64))
+	ver = tx.AsLatestVersion()
+	err = ver.SignInput(store, 0, accounts)
+	assert.Nil(err)
+	err = ver.Validate(store, 0)
+	assert.Equal(ErrorCodeAmountMismatch, err.(*ValidationError).Code)
+
+	tx.Outputs[0].Amount = NewInteger(10000)
+	tx.AddInput(genesisHash, 0)
+	ver = tx.AsLatestVersion()
+	for i := range ver.Inputs {
+		err = ver.SignInput(store, i, accounts)
+		assert.Nil(err)
+	}
+	err = ver.Validate(store, 0)
+	assert.Equal(ErrorCodeInputDuplicated, err.(*ValidationError).Code)
+
+	tx = NewTransaction(XINAssetId)
+	tx.AddInput(genesisHash, 1)
+	tx.AddInput(genesisHash, 2)
+	tx.AddScriptOutput(accounts[:1], NewThresholdScript(1), NewInteger(20000), bytes.Repeat([]byte{2}, 64))
+	ver = tx.AsLatestVersion()
+	err = ver.SignInput(store, 0, accounts)
+	assert.Nil(err)
+	err = ver.SignInput(store, 1, accounts[:2])
+	assert.Nil(err)
+	err = ver.Validate(store, 0)
+	assert.Equal(ErrorCodeInvalidSignature, err.(*ValidationError).Code)
+	assert.Equal("invalid signature keys 2 3", err.Error())
+	assert.Equal("0000000000000000000000000000000000000000000000000000000000000000:2", err.(*ValidationError).Details["input"])
+	ver.Signatures[1] = append(ver.Signatures[1], ver.Signatures[1][0])
+	err = ver.Validate(store, 0)
+	assert.Equal("invalid signature keys 2 3", err.Error())
+
+	data, err := json.Marshal(NewValidationError(ErrorCodeInputLocked, "input locked for transaction %s", genesisHash).With("lock", genesisHash))
+	assert.Nil(err)
+	assert.Equal(`{"code":10103,"rule":"input locked","message":"input locked for transaction 0000000000000000000000000000000000000000000000000000000000000000","details":{"lock":"0000000000000000000000000000000000000000000000000000000000000000"}}`, string(data))
+}
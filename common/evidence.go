@@ -0,0 +1,148 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	EvidenceTypeAnnouncement = 1
+	EvidenceTypeFinalization = 2
+)
+
+// the finalized snapshot has the cosi signature of the consensus nodes, the
+// announced snapshot has the challenge signature and the commitment of the node
+type EvidenceSnapshot struct {
+	Snapshot   *Snapshot   `json:"snapshot"`
+	Commitment *crypto.Key `json:"commitment,omitempty" msgpack:",omitempty"`
+}
+
+// Evidence proves a consensus node signed two conflicting snapshots, the first
+// one is always finalized, and the second one is either finalized or announced
+type Evidence struct {
+	Type      uint8               `json:"type"`
+	NodeId    crypto.Hash         `json:"node"`
+	Round     uint64              `json:"round"`
+	Snapshots []*EvidenceSnapshot `json:"snapshots"`
+	Timestamp uint64              `json:"timestamp"`
+}
+
+// two snapshots of the same node conflict if they are in two different rounds
+// with the same number, or they sign the same transaction with the same round link
+func (s *Snapshot) ConflictsWith(o *Snapshot) bool {
+	if s.Version != SnapshotVersion || o.Version != SnapshotVersion {
+		return false
+	}
+	if s.NodeId != o.NodeId || s.PayloadHash() == o.PayloadHash() {
+		return false
+	}
+	if s.References == nil || o.References == nil {
+		return false
+	}
+	if s.RoundNumber == o.RoundNumber && !s.References.Equal(o.References) {
+		return true
+	}
+	return s.References.Equal(o.References) && s.Transaction == o.Transaction
+}
+
+func NewEvidence(typ uint8, final *Snapshot, conflict *EvidenceSnapshot, timestamp uint64) *Evidence {
+	return &Evidence{
+		Type:      typ,
+		NodeId:    final.NodeId,
+		Round:     conflict.Snapshot.RoundNumber,
+		Snapshots: []*EvidenceSnapshot{{Snapshot: final}, conflict},
+		Timestamp: timestamp,
+	}
+}
+
+// the hash is the same no matter which one of the two snapshots comes first
+func (e *Evidence) Hash() crypto.Hash {
+	if len(e.Snapshots) != 2 {
+		return crypto.Hash{}
+	}
+	a := e.Snapshots[0].Snapshot.PayloadHash()
+	b := e.Snapshots[1].Snapshot.PayloadHash()
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return crypto.NewHash(append(a[:], b[:]...))
+}
+
+func (e *Evidence) Validate() error {
+	if len(e.Snapshots) != 2 {
+		return fmt.Errorf("invalid evidence snapshots count %d", len(e.Snapshots))
+	}
+	final, conflict := e.Snapshots[0], e.Snapshots[1]
+	if final.Snapshot == nil || conflict.Snapshot == nil {
+		return fmt.Errorf("invalid evidence snapshots")
+	}
+	if final.Snapshot.Signature == nil || conflict.Snapshot.Signature == nil {
+		return fmt.Errorf("invalid evidence snapshots without signature")
+	}
+	if final.Snapshot.NodeId != e.NodeId || conflict.Snapshot.RoundNumber != e.Round {
+		return fmt.Errorf("invalid evidence node %s %d", e.NodeId, e.Round)
+	}
+	if !final.Snapshot.ConflictsWith(conflict.Snapshot) {
+		return fmt.Errorf("invalid evidence without conflict %s %s", final.Snapshot.PayloadHash(), conflict.Snapshot.PayloadHash())
+	}
+
+	switch e.Type {
+	case EvidenceTypeFinalization:
+		if conflict.Commitment != nil {
+			return fmt.Errorf("invalid finalization evidence with commitment")
+		}
+	case EvidenceTypeAnnouncement:
+		if conflict.Commitment == nil {
+			return fmt.Errorf("invalid announcement evidence without commitment")
+		}
+		// a failed announcement could be retried with the same round link, so
+		// only the conflicting references of the same round prove the equivocation
+		fs, cs := final.Snapshot, conflict.Snapshot
+		if fs.RoundNumber != cs.RoundNumber || fs.References.Equal(cs.References) {
+			return fmt.Errorf("invalid announcement evidence references %d %d", fs.RoundNumber, cs.RoundNumber)
+		}
+	default:
+		return fmt.Errorf("invalid evidence type %d", e.Type)
+	}
+	return nil
+}
+
+// Verify checks the signatures with the consensus keys and threshold at the
+// snapshot timestamp, signer is the public spend key of the evidence node
+func (e *Evidence) Verify(keys func(timestamp uint64) ([]*crypto.Key, int), signer crypto.Key) error {
+	err := e.Validate()
+	if err != nil {
+		return err
+	}
+
+	final, conflict := e.Snapshots[0].Snapshot, e.Snapshots[1]
+	publics, threshold := keys(final.Timestamp)
+	hash := final.PayloadHash()
+	if !final.Signature.FullVerify(publics, threshold, hash[:]) {
+		return fmt.Errorf("invalid evidence finalization signature %s", hash)
+	}
+
+	s := conflict.Snapshot
+	publics, threshold = keys(s.Timestamp)
+	hash = s.PayloadHash()
+	if e.Type == EvidenceTypeFinalization {
+		if !s.Signature.FullVerify(publics, threshold, hash[:]) {
+			return fmt.Errorf("invalid evidence finalization signature %s", hash)
+		}
+		return nil
+	}
+
+	challenge, err := s.Signature.Challenge(publics, hash[:])
+	if err != nil {
+		return err
+	}
+	var sig crypto.Signature
+	copy(sig[:], conflict.Commitment[:])
+	copy(sig[32:], s.Signature.Signature[32:])
+	if !signer.VerifyWithChallenge(hash[:], sig, challenge) {
+		return fmt.Errorf("invalid evidence announcement signature %s", hash)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvidence(t *testing.T) {
+	assert := assert.New(t)
+
+	privates := make([]*crypto.Key, 4)
+	publics := make([]*crypto.Key, len(privates))
+	for i := range privates {
+		seed := crypto.NewHash([]byte(fmt.Sprintf("evidence%d", i)))
+		priv := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+		pub := priv.Public()
+		privates[i] = &priv
+		publics[i] = &pub
+	}
+	keys := func(timestamp uint64) ([]*crypto.Key, int) {
+		return publics, 3
+	}
+
+	nodeId := crypto.NewHash([]byte("node"))
+	a := &Snapshot{
+		Version:     SnapshotVersion,
+		NodeId:      nodeId,
+		Transaction: crypto.NewHash([]byte("a")),
+		References:  &RoundLink{Self: crypto.NewHash([]byte("self")), External: crypto.NewHash([]byte("external"))},
+		RoundNumber: 7,
+		Timestamp:   1500000000000000000,
+	}
+	b := *a
+	b.Transaction = crypto.NewHash([]byte("b"))
+	assert.False(a.ConflictsWith(&b))
+	b.Timestamp = a.Timestamp + 1
+	b.Transaction = a.Transaction
+	assert.True(a.ConflictsWith(&b))
+	b.Transaction = crypto.NewHash([]byte("b"))
+	b.References = &RoundLink{Self: a.References.Self, External: crypto.NewHash([]byte("fork"))}
+	assert.True(a.ConflictsWith(&b))
+	b.RoundNumber = a.RoundNumber + 1
+	assert.False(a.ConflictsWith(&b))
+	b.RoundNumber = a.RoundNumber
+	b.NodeId = crypto.NewHash([]byte("other"))
+	assert.False(a.ConflictsWith(&b))
+	b.NodeId = a.NodeId
+
+	a.Signature = evidenceTestSign(privates, publics, []int{0, 1, 2}, a.PayloadHash())
+	b.Signature = evidenceTestSign(privates, publics, []int{0, 1, 3}, b.PayloadHash())
+	e := NewEvidence(EvidenceTypeFinalization, a, &EvidenceSnapshot{Snapshot: &b}, 1600000000000000000)
+	assert.Nil(e.Validate())
+	assert.Nil(e.Verify(keys, *publics[0]))
+	reversed := NewEvidence(EvidenceTypeFinalization, &b, &EvidenceSnapshot{Snapshot: a}, 1600000000000000000)
+	assert.Equal(e.Hash(), reversed.Hash())
+
+	var decoded Evidence
+	err := MsgpackUnmarshal(MsgpackMarshalPanic(e), &decoded)
+	assert.Nil(err)
+	assert.Equal(e.Hash(), decoded.Hash())
+	assert.Nil(decoded.Verify(keys, *publics[0]))
+
+	b.Signature = evidenceTestSign(privates, publics, []int{0, 1}, b.PayloadHash())
+	assert.NotNil(e.Verify(keys, *publics[0]))
+
+	random := crypto.CosiCommit(rand.Reader)
+	commitment := random.Public()
+	randoms := map[int]*crypto.Key{0: &commitment}
+	others := make([]*crypto.Key, len(privates))
+	for _, i := range []int{1, 2} {
+		others[i] = crypto.CosiCommit(rand.Reader)
+		R := others[i].Public()
+		randoms[i] = &R
+	}
+	cosi, err := crypto.CosiAggregateCommitment(randoms)
+	assert.Nil(err)
+	hash := b.PayloadHash()
+	response, err := cosi.Response(privates[0], random, publics, hash[:])
+	assert.Nil(err)
+	copy(cosi.Signature[32:], response[:])
+	b.Signature = cosi
+	e = NewEvidence(EvidenceTypeAnnouncement, a, &EvidenceSnapshot{Snapshot: &b}, 1600000000000000000)
+	assert.NotNil(e.Validate())
+	e.Snapshots[1].Commitment = &commitment
+	assert.Nil(e.Verify(keys, *publics[0]))
+	assert.NotNil(e.Verify(keys, *publics[1]))
+
+	b.Timestamp = a.Timestamp - 1
+	assert.Nil(e.Validate())
+	b.References = a.References
+	b.Transaction = a.Transaction
+	assert.True(a.ConflictsWith(&b))
+	assert.NotNil(e.Validate())
+}
+
+func evidenceTestSign(privates, publics []*crypto.Key, signers []int, hash crypto.Hash) *crypto.CosiSignature {
+	randoms := make(map[int]*crypto.Key)
+	secrets := make(map[int]*crypto.Key)
+	for _, i := range signers {
+		secrets[i] = crypto.CosiCommit(rand.Reader)
+		R := secrets[i].Public()
+		randoms[i] = &R
+	}
+	cosi, err := crypto.CosiAggregateCommitment(randoms)
+	if err != nil {
+		panic(err)
+	}
+	responses := make(map[int]*[32]byte)
+	for _, i := range signers {
+		s, err := cosi.Response(privates[i], secrets[i], publics, hash[:])
+		if err != nil {
+			panic(err)
+		}
+		responses[i] = &s
+	}
+	err = cosi.AggregateResponse(publics, responses, hash[:], true)
+	if err != nil {
+		panic(err)
+	}
+	return cosi
+}
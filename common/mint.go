@@ -1,8 +1,6 @@
 package common
 
 import (
-	"fmt"
-
 	"github.com/MixinNetwork/mixin/crypto"
 )
 
@@ -32,22 +30,54 @@ func (m *MintData) Distribute(tx crypto.Hash) *MintDistribution {
 	}
 }
 
+// DistributeMintByWork splits the amount weighted by the works, the share of
+// a work is rounded down and the amount is split equally if there is no work
+// at all, the remainder of all the divisions is returned as the diff
+func DistributeMintByWork(amount Integer, works []uint64) ([]Integer, Integer) {
+	var total uint64
+	for _, w := range works {
+		total += w
+	}
+	even := total == 0
+	if even {
+		total = uint64(len(works))
+	}
+	shares := make([]Integer, len(works))
+	distributed := Zero
+	for i, w := range works {
+		if even {
+			w = 1
+		}
+		shares[i] = Zero
+		if w > 0 {
+			shares[i] = amount.Mul(int(w)).Div(int(total))
+		}
+		if shares[i].Sign() > 0 {
+			distributed = distributed.Add(shares[i])
+		}
+	}
+	if distributed.Sign() == 0 {
+		return shares, amount
+	}
+	return shares, amount.Sub(distributed)
+}
+
 func (tx *VersionedTransaction) validateMint(store DataStore) error {
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for mint", len(tx.Inputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid inputs count %d for mint", len(tx.Inputs))
 	}
 	for _, out := range tx.Outputs {
 		if out.Type != OutputTypeScript {
-			return fmt.Errorf("invalid mint output type %d", out.Type)
+			return NewValidationError(ErrorCodeInvalidOutput, "invalid mint output type %d", out.Type)
 		}
 	}
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid mint asset %s", tx.Asset.String())
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid mint asset %s", tx.Asset.String())
 	}
 
 	mint := tx.Inputs[0].Mint
 	if mint.Group != MintGroupKernelNode {
-		return fmt.Errorf("invalid mint group %s", mint.Group)
+		return NewValidationError(ErrorCodeInvalidMint, "invalid mint group %s", mint.Group)
 	}
 
 	dist, err := store.ReadLastMintDistribution(mint.Group)
@@ -58,10 +88,10 @@ func (tx *VersionedTransaction) validateMint(store DataStore) error {
 		return nil
 	}
 	if mint.Batch < dist.Batch {
-		return fmt.Errorf("backward mint batch %d %d", dist.Batch, mint.Batch)
+		return NewValidationError(ErrorCodeInvalidMint, "backward mint batch %d %d", dist.Batch, mint.Batch)
 	}
 	if dist.Transaction != tx.PayloadHash() || dist.Amount.Cmp(mint.Amount) != 0 {
-		return fmt.Errorf("invalid mint lock %s %s", dist.Transaction.String(), tx.PayloadHash().String())
+		return NewValidationError(ErrorCodeInvalidMint, "invalid mint lock %s %s", dist.Transaction.String(), tx.PayloadHash().String())
 	}
 	return nil
 }
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDistributeMintByWork(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		amount string
+		works  []uint64
+		shares []string
+		diff   string
+	}{
+		{"100", []uint64{}, []string{}, "100"},
+		{"100", []uint64{0, 0, 0, 0}, []string{"25", "25", "25", "25"}, "0"},
+		{"100", []uint64{0, 0, 0}, []string{"33.33333333", "33.33333333", "33.33333333"}, "0.00000001"},
+		{"100", []uint64{1, 1, 2}, []string{"25", "25", "50"}, "0"},
+		{"100", []uint64{3, 0, 1}, []string{"75", "0", "25"}, "0"},
+		{"100", []uint64{1, 0, 0}, []string{"100", "0", "0"}, "0"},
+		{"100", []uint64{2, 3, 2}, []string{"28.57142857", "42.85714285", "28.57142857"}, "0.00000001"},
+		{"0.00000002", []uint64{1, 1, 1}, []string{"0", "0", "0"}, "0.00000002"},
+	}
+	for _, c := range cases {
+		shares, diff := DistributeMintByWork(NewIntegerFromString(c.amount), c.works)
+		assert.Len(shares, len(c.shares))
+		sum := NewIntegerFromString(c.amount)
+		for i, s := range shares {
+			assert.Equal(mintTestInteger(c.shares[i]).String(), s.String())
+			if s.Sign() > 0 {
+				sum = sum.Sub(s)
+			}
+		}
+		assert.Equal(mintTestInteger(c.diff).String(), diff.String())
+		assert.Equal(sum.String(), diff.String())
+	}
+}
+
+func mintTestInteger(s string) Integer {
+	if s == "0" {
+		return Zero
+	}
+	return NewIntegerFromString(s)
+}
@@ -3,8 +3,8 @@ package common
 import (
 	"bytes"
 	"encoding/hex"
-	"fmt"
 
+	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 )
 
@@ -34,27 +34,27 @@ func (n *Node) IdForNetwork(networkId crypto.Hash) crypto.Hash {
 
 func (tx *Transaction) validateNodePledge(store DataStore, inputs map[string]*UTXO) error {
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid node asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid outputs count %d for pledge transaction", len(tx.Outputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid outputs count %d for pledge transaction", len(tx.Outputs))
 	}
 	if len(tx.Extra) != 2*len(crypto.Key{}) {
-		return fmt.Errorf("invalid extra length %d for pledge transaction", len(tx.Extra))
+		return NewValidationError(ErrorCodeInvalidExtra, "invalid extra length %d for pledge transaction", len(tx.Extra))
 	}
 	for _, in := range inputs {
 		if in.Type != OutputTypeScript {
-			return fmt.Errorf("invalid utxo type %d", in.Type)
+			return NewValidationError(ErrorCodeInvalidInputType, "invalid utxo type %d", in.Type)
 		}
 	}
 
 	o := tx.Outputs[0]
-	if o.Amount.Cmp(NewInteger(10000)) != 0 {
-		return fmt.Errorf("invalid pledge amount %s", o.Amount.String())
+	if o.Amount.Cmp(NewInteger(config.KernelNodePledgeAmount)) != 0 {
+		return NewValidationError(ErrorCodeInvalidAmount, "invalid pledge amount %s", o.Amount.String())
 	}
 	for _, n := range store.ReadConsensusNodes() {
 		if n.State != NodeStateAccepted && n.State != NodeStateCancelled && n.State != NodeStateRemoved {
-			return fmt.Errorf("invalid node pending state %s %s", n.Signer.String(), n.State)
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid node pending state %s %s", n.Signer.String(), n.State)
 		}
 	}
 
@@ -63,32 +63,32 @@ func (tx *Transaction) validateNodePledge(store DataStore, inputs map[string]*UT
 
 func (tx *Transaction) validateNodeCancel(store DataStore, msg []byte, sigs [][]crypto.Signature) error {
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid node asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 2 {
-		return fmt.Errorf("invalid outputs count %d for cancel transaction", len(tx.Outputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid outputs count %d for cancel transaction", len(tx.Outputs))
 	}
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for cancel transaction", len(tx.Inputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid inputs count %d for cancel transaction", len(tx.Inputs))
 	}
 	if len(sigs) != 1 {
-		return fmt.Errorf("invalid signatures count %d for cancel transaction", len(sigs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid signatures count %d for cancel transaction", len(sigs))
 	}
 	if len(sigs[0]) != 1 {
-		return fmt.Errorf("invalid signatures count %d for cancel transaction", len(sigs[0]))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid signatures count %d for cancel transaction", len(sigs[0]))
 	}
 	if len(tx.Extra) != len(crypto.Key{})*3 {
-		return fmt.Errorf("invalid extra %s for cancel transaction", hex.EncodeToString(tx.Extra))
+		return NewValidationError(ErrorCodeInvalidExtra, "invalid extra %s for cancel transaction", hex.EncodeToString(tx.Extra))
 	}
 	cancel, script := tx.Outputs[0], tx.Outputs[1]
 	if cancel.Type != OutputTypeNodeCancel || script.Type != OutputTypeScript {
-		return fmt.Errorf("invalid outputs type %d %d for cancel transaction", cancel.Type, script.Type)
+		return NewValidationError(ErrorCodeInvalidOutput, "invalid outputs type %d %d for cancel transaction", cancel.Type, script.Type)
 	}
 	if len(script.Keys) != 1 {
-		return fmt.Errorf("invalid script output keys %d for cancel transaction", len(script.Keys))
+		return NewValidationError(ErrorCodeInvalidOutput, "invalid script output keys %d for cancel transaction", len(script.Keys))
 	}
 	if script.Script.String() != NewThresholdScript(1).String() {
-		return fmt.Errorf("invalid script output script %s for cancel transaction", script.Script)
+		return NewValidationError(ErrorCodeInvalidOutput, "invalid script output script %s for cancel transaction", script.Script)
 	}
 
 	var pledging *Node
@@ -97,7 +97,7 @@ func (tx *Transaction) validateNodeCancel(store DataStore, msg []byte, sigs [][]
 	for _, n := range nodes {
 		filter[n.Signer.String()] = n.State
 		if n.State == NodeStateDeparting {
-			return fmt.Errorf("invalid node pending state %s %s", n.Signer.String(), n.State)
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid node pending state %s %s", n.Signer.String(), n.State)
 		}
 		if n.State == NodeStateAccepted || n.State == NodeStateCancelled || n.State == NodeStateRemoved {
 			continue
@@ -105,14 +105,14 @@ func (tx *Transaction) validateNodeCancel(store DataStore, msg []byte, sigs [][]
 		if n.State == NodeStatePledging && pledging == nil {
 			pledging = n
 		} else {
-			return fmt.Errorf("invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
 		}
 	}
 	if pledging == nil {
-		return fmt.Errorf("no pledging node needs to get cancelled")
+		return NewValidationError(ErrorCodeInvalidNodeState, "no pledging node needs to get cancelled")
 	}
 	if pledging.Transaction != tx.Inputs[0].Hash {
-		return fmt.Errorf("invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
 	}
 
 	lastPledge, _, err := store.ReadTransaction(tx.Inputs[0].Hash)
@@ -120,14 +120,14 @@ func (tx *Transaction) validateNodeCancel(store DataStore, msg []byte, sigs [][]
 		return err
 	}
 	if len(lastPledge.Outputs) != 1 {
-		return fmt.Errorf("invalid pledge utxo count %d", len(lastPledge.Outputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid pledge utxo count %d", len(lastPledge.Outputs))
 	}
 	po := lastPledge.Outputs[0]
 	if po.Type != OutputTypeNodePledge {
-		return fmt.Errorf("invalid pledge utxo type %d", po.Type)
+		return NewValidationError(ErrorCodeInvalidInputType, "invalid pledge utxo type %d", po.Type)
 	}
 	if cancel.Amount.Cmp(po.Amount.Div(100)) != 0 {
-		return fmt.Errorf("invalid script output amount %s for cancel transaction", cancel.Amount)
+		return NewValidationError(ErrorCodeInvalidAmount, "invalid script output amount %s for cancel transaction", cancel.Amount)
 	}
 	var publicSpend crypto.Key
 	copy(publicSpend[:], lastPledge.Extra)
@@ -137,7 +137,7 @@ func (tx *Transaction) validateNodeCancel(store DataStore, msg []byte, sigs [][]
 		PublicSpendKey: publicSpend,
 	}
 	if filter[acc.String()] != NodeStatePledging {
-		return fmt.Errorf("invalid pledge utxo source %s", filter[acc.String()])
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid pledge utxo source %s", filter[acc.String()])
 	}
 
 	pit, _, err := store.ReadTransaction(lastPledge.Inputs[0].Hash)
@@ -145,37 +145,37 @@ func (tx *Transaction) validateNodeCancel(store DataStore, msg []byte, sigs [][]
 		return err
 	}
 	if pit == nil {
-		return fmt.Errorf("invalid pledge input source %s:%d", lastPledge.Inputs[0].Hash, lastPledge.Inputs[0].Index)
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid pledge input source %s:%d", lastPledge.Inputs[0].Hash, lastPledge.Inputs[0].Index)
 	}
 	pi := pit.Outputs[lastPledge.Inputs[0].Index]
 	if len(pi.Keys) != 1 {
-		return fmt.Errorf("invalid pledge input source keys %d", len(pi.Keys))
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid pledge input source keys %d", len(pi.Keys))
 	}
 	var a crypto.Key
 	copy(a[:], tx.Extra[len(crypto.Key{})*2:])
 	pledgeSpend := crypto.ViewGhostOutputKey(&pi.Keys[0], &a, &pi.Mask, uint64(lastPledge.Inputs[0].Index))
 	targetSpend := crypto.ViewGhostOutputKey(&script.Keys[0], &a, &script.Mask, 1)
 	if bytes.Compare(lastPledge.Extra, tx.Extra[:len(crypto.Key{})*2]) != 0 {
-		return fmt.Errorf("invalid pledge and cancel key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid pledge and cancel key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
 	}
 	if bytes.Compare(pledgeSpend[:], targetSpend[:]) != 0 {
-		return fmt.Errorf("invalid pledge and cancel target %s %s", pledgeSpend, targetSpend)
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid pledge and cancel target %s %s", pledgeSpend, targetSpend)
 	}
 	if !pi.Keys[0].Verify(msg, sigs[0][0]) {
-		return fmt.Errorf("invalid cancel signature %s", sigs[0][0])
+		return NewValidationError(ErrorCodeInvalidSignature, "invalid cancel signature %s", sigs[0][0])
 	}
 	return nil
 }
 
 func (tx *Transaction) validateNodeAccept(store DataStore) error {
 	if tx.Asset != XINAssetId {
-		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid node asset %s", tx.Asset.String())
 	}
 	if len(tx.Outputs) != 1 {
-		return fmt.Errorf("invalid outputs count %d for accept transaction", len(tx.Outputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid outputs count %d for accept transaction", len(tx.Outputs))
 	}
 	if len(tx.Inputs) != 1 {
-		return fmt.Errorf("invalid inputs count %d for accept transaction", len(tx.Inputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid inputs count %d for accept transaction", len(tx.Inputs))
 	}
 	var pledging *Node
 	filter := make(map[string]string)
@@ -183,7 +183,7 @@ func (tx *Transaction) validateNodeAccept(store DataStore) error {
 	for _, n := range nodes {
 		filter[n.Signer.String()] = n.State
 		if n.State == NodeStateDeparting {
-			return fmt.Errorf("invalid node pending state %s %s", n.Signer.String(), n.State)
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid node pending state %s %s", n.Signer.String(), n.State)
 		}
 		if n.State == NodeStateAccepted || n.State == NodeStateCancelled || n.State == NodeStateRemoved {
 			continue
@@ -191,14 +191,14 @@ func (tx *Transaction) validateNodeAccept(store DataStore) error {
 		if n.State == NodeStatePledging && pledging == nil {
 			pledging = n
 		} else {
-			return fmt.Errorf("invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid pledging nodes %s %s", pledging.Signer.String(), n.Signer.String())
 		}
 	}
 	if pledging == nil {
-		return fmt.Errorf("no pledging node needs to get accepted")
+		return NewValidationError(ErrorCodeInvalidNodeState, "no pledging node needs to get accepted")
 	}
 	if pledging.Transaction != tx.Inputs[0].Hash {
-		return fmt.Errorf("invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid plede utxo source %s %s", pledging.Transaction, tx.Inputs[0].Hash)
 	}
 
 	lastPledge, _, err := store.ReadTransaction(tx.Inputs[0].Hash)
@@ -206,11 +206,11 @@ func (tx *Transaction) validateNodeAccept(store DataStore) error {
 		return err
 	}
 	if len(lastPledge.Outputs) != 1 {
-		return fmt.Errorf("invalid pledge utxo count %d", len(lastPledge.Outputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid pledge utxo count %d", len(lastPledge.Outputs))
 	}
 	po := lastPledge.Outputs[0]
 	if po.Type != OutputTypeNodePledge {
-		return fmt.Errorf("invalid pledge utxo type %d", po.Type)
+		return NewValidationError(ErrorCodeInvalidInputType, "invalid pledge utxo type %d", po.Type)
 	}
 	var publicSpend crypto.Key
 	copy(publicSpend[:], lastPledge.Extra)
@@ -220,10 +220,122 @@ func (tx *Transaction) validateNodeAccept(store DataStore) error {
 		PublicSpendKey: publicSpend,
 	}
 	if filter[acc.String()] != NodeStatePledging {
-		return fmt.Errorf("invalid pledge utxo source %s", filter[acc.String()])
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid pledge utxo source %s", filter[acc.String()])
 	}
 	if bytes.Compare(lastPledge.Extra, tx.Extra) != 0 {
-		return fmt.Errorf("invalid pledge and accpet key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid pledge and accpet key %s %s", hex.EncodeToString(lastPledge.Extra), hex.EncodeToString(tx.Extra))
 	}
 	return nil
 }
+
+func (tx *Transaction) validateNodeDepart(store DataStore, inputs map[string]*UTXO, msg []byte, sigs [][]crypto.Signature) error {
+	if tx.Asset != XINAssetId {
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid node asset %s", tx.Asset.String())
+	}
+	if len(tx.Outputs) != 1 {
+		return NewValidationError(ErrorCodeInvalidCount, "invalid outputs count %d for depart transaction", len(tx.Outputs))
+	}
+	if len(tx.Inputs) != 1 {
+		return NewValidationError(ErrorCodeInvalidCount, "invalid inputs count %d for depart transaction", len(tx.Inputs))
+	}
+	if len(sigs) != 1 || len(sigs[0]) != 1 {
+		return NewValidationError(ErrorCodeInvalidCount, "invalid signatures count %d for depart transaction", len(sigs))
+	}
+	if len(tx.Extra) != len(crypto.Key{})*2 {
+		return NewValidationError(ErrorCodeInvalidExtra, "invalid extra %s for depart transaction", hex.EncodeToString(tx.Extra))
+	}
+	for _, in := range inputs {
+		if in.Type != OutputTypeNodeAccept {
+			return NewValidationError(ErrorCodeInvalidInputType, "invalid utxo type %d", in.Type)
+		}
+	}
+
+	var accepted *Node
+	for _, n := range store.ReadConsensusNodes() {
+		if n.State == NodeStatePledging || n.State == NodeStateDeparting {
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid node pending state %s %s", n.Signer.String(), n.State)
+		}
+		if n.State == NodeStateAccepted && bytes.Compare(n.Signer.PublicSpendKey[:], tx.Extra[:len(crypto.Key{})]) == 0 {
+			accepted = n
+		}
+	}
+	if accepted == nil {
+		return NewValidationError(ErrorCodeInvalidNodeState, "no accepted node for depart signer %s", hex.EncodeToString(tx.Extra[:len(crypto.Key{})]))
+	}
+	if accepted.Transaction != tx.Inputs[0].Hash || tx.Inputs[0].Index != 0 {
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid accept utxo source %s %s:%d", accepted.Transaction, tx.Inputs[0].Hash, tx.Inputs[0].Index)
+	}
+	if bytes.Compare(accepted.Payee.PublicSpendKey[:], tx.Extra[len(crypto.Key{}):]) != 0 {
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid accept and depart payee %s %s", accepted.Payee.PublicSpendKey, hex.EncodeToString(tx.Extra))
+	}
+	if !accepted.Signer.PublicSpendKey.Verify(msg, sigs[0][0]) {
+		return NewValidationError(ErrorCodeInvalidSignature, "invalid depart signature %s", sigs[0][0])
+	}
+	return nil
+}
+
+func (tx *Transaction) validateNodeRemove(store DataStore, msg []byte) error {
+	if tx.Asset != XINAssetId {
+		return NewValidationError(ErrorCodeInvalidAsset, "invalid node asset %s", tx.Asset.String())
+	}
+	if len(tx.Outputs) != 1 {
+		return NewValidationError(ErrorCodeInvalidCount, "invalid outputs count %d for remove transaction", len(tx.Outputs))
+	}
+	if len(tx.Inputs) != 1 {
+		return NewValidationError(ErrorCodeInvalidCount, "invalid inputs count %d for remove transaction", len(tx.Inputs))
+	}
+
+	var departing *Node
+	for _, n := range store.ReadConsensusNodes() {
+		if n.State == NodeStatePledging {
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid node pending state %s %s", n.Signer.String(), n.State)
+		}
+		if n.State != NodeStateDeparting {
+			continue
+		}
+		if departing != nil {
+			return NewValidationError(ErrorCodeInvalidNodeState, "invalid departing nodes %s %s", departing.Signer.String(), n.Signer.String())
+		}
+		departing = n
+	}
+	if departing == nil {
+		return NewValidationError(ErrorCodeInvalidNodeState, "no departing node needs to get removed")
+	}
+	if departing.Transaction != tx.Inputs[0].Hash {
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid depart utxo source %s %s", departing.Transaction, tx.Inputs[0].Hash)
+	}
+
+	depart, _, err := store.ReadTransaction(tx.Inputs[0].Hash)
+	if err != nil {
+		return err
+	}
+	if depart == nil || depart.TransactionType() != TransactionTypeNodeDepart {
+		return NewValidationError(ErrorCodeInvalidPledge, "invalid depart transaction %s", tx.Inputs[0].Hash)
+	}
+	remove := NewNodeRemoveTransaction(depart).AsLatestVersion()
+	if bytes.Compare(remove.PayloadMarshal(), msg) != 0 {
+		return NewValidationError(ErrorCodeInvalidOutput, "invalid remove transaction %s %s", remove.PayloadHash(), crypto.NewHash(msg))
+	}
+	return nil
+}
+
+// every consensus node builds the same remove transaction for the departing
+// node, the pledge returns to the payee with a ghost key derived from the
+// depart transaction hash, so there is never a conflicting removal
+func NewNodeRemoveTransaction(depart *VersionedTransaction) *Transaction {
+	hash := depart.PayloadHash()
+	var publicSpend crypto.Key
+	copy(publicSpend[:], depart.Extra[len(publicSpend):])
+	privateView := publicSpend.DeterministicHashDerive()
+	payee := Address{
+		PublicViewKey:  privateView.Public(),
+		PublicSpendKey: publicSpend,
+	}
+	seed := crypto.NewHash(append(hash[:], depart.Extra...))
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddInput(hash, 0)
+	tx.AddOutputWithType(OutputTypeNodeRemove, []Address{payee}, NewThresholdScript(1), depart.Outputs[0].Amount, append(seed[:], seed[:]...))
+	tx.Extra = append([]byte{}, depart.Extra...)
+	return tx
+}
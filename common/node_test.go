@@ -0,0 +1,111 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeDepartAndRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	signer := randomAccount()
+	payee := randomAccount()
+	payee.PrivateViewKey = payee.PublicSpendKey.DeterministicHashDerive()
+	payee.PublicViewKey = payee.PrivateViewKey.Public()
+
+	accept := NewTransaction(XINAssetId)
+	accept.AddInput(crypto.NewHash([]byte("pledge")), 0)
+	accept.AddOutputWithType(OutputTypeNodeAccept, nil, Script{}, NewInteger(10000), []byte{})
+	accept.Extra = append(signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
+	store := &nodeStoreImpl{
+		accept: accept.AsLatestVersion(),
+		nodes: []*Node{{
+			Signer:      signer,
+			Payee:       payee,
+			State:       NodeStateAccepted,
+			Transaction: accept.AsLatestVersion().PayloadHash(),
+		}},
+	}
+	timestamp := uint64(time.Now().UnixNano())
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddInput(store.accept.PayloadHash(), 0)
+	tx.AddOutputWithType(OutputTypeNodeDepart, nil, Script{}, NewInteger(10000), []byte{})
+	tx.Extra = store.accept.Extra
+	depart := tx.AsLatestVersion()
+	depart.Signatures = [][]crypto.Signature{{payee.PrivateSpendKey.Sign(depart.PayloadMarshal())}}
+	err := depart.Validate(store, timestamp)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid depart signature")
+	depart.Signatures = [][]crypto.Signature{{signer.PrivateSpendKey.Sign(depart.PayloadMarshal())}}
+	assert.Nil(depart.Validate(store, timestamp))
+	assert.Equal(uint8(TransactionTypeNodeDepart), depart.TransactionType())
+	store.nodes[0].State = NodeStateDeparting
+	assert.NotNil(depart.Validate(store, timestamp))
+
+	store.depart = depart
+	store.nodes[0].Transaction = depart.PayloadHash()
+	remove := NewNodeRemoveTransaction(depart).AsLatestVersion()
+	assert.Equal(uint8(TransactionTypeNodeRemove), remove.TransactionType())
+	assert.Equal(remove.PayloadHash(), NewNodeRemoveTransaction(depart).AsLatestVersion().PayloadHash())
+	assert.Nil(remove.Validate(store, timestamp))
+	assert.Len(remove.UnspentOutputs(), 1)
+
+	tx = NewNodeRemoveTransaction(depart)
+	tx.Outputs[0].Keys[0] = signer.PublicSpendKey
+	err = tx.AsLatestVersion().Validate(store, timestamp)
+	assert.NotNil(err)
+	assert.Contains(err.Error(), "invalid remove transaction")
+
+	store.nodes[0].State = NodeStateAccepted
+	assert.NotNil(remove.Validate(store, timestamp))
+	store.nodes[0].State = NodeStateDeparting
+
+	store.remove = remove
+	out := remove.Outputs[0]
+	key := crypto.ViewGhostOutputKey(&out.Keys[0], &payee.PrivateViewKey, &out.Mask, 0)
+	assert.Equal(payee.PublicSpendKey, *key)
+	tx = NewTransaction(XINAssetId)
+	tx.AddInput(remove.PayloadHash(), 0)
+	tx.AddScriptOutput([]Address{randomAccount()}, NewThresholdScript(1), NewInteger(10000), bytes.Repeat([]byte{3}, 64))
+	spend := tx.AsLatestVersion()
+	assert.Nil(spend.SignInput(store, 0, []Address{payee}))
+	assert.Nil(spend.Validate(store, timestamp))
+}
+
+type nodeStoreImpl struct {
+	storeImpl
+	nodes  []*Node
+	accept *VersionedTransaction
+	depart *VersionedTransaction
+	remove *VersionedTransaction
+}
+
+func (store *nodeStoreImpl) ReadUTXO(hash crypto.Hash, index int) (*UTXOWithLock, error) {
+	for _, ver := range []*VersionedTransaction{store.accept, store.depart, store.remove} {
+		if ver == nil || ver.PayloadHash() != hash {
+			continue
+		}
+		for _, utxo := range ver.UnspentOutputs() {
+			if utxo.Index == index {
+				return &UTXOWithLock{UTXO: *utxo}, nil
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (store *nodeStoreImpl) ReadConsensusNodes() []*Node {
+	return store.nodes
+}
+
+func (store *nodeStoreImpl) ReadTransaction(hash crypto.Hash) (*VersionedTransaction, string, error) {
+	if store.depart != nil && store.depart.PayloadHash() == hash {
+		return store.depart, "", nil
+	}
+	return nil, "", nil
+}
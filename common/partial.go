@@ -0,0 +1,134 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+type PartiallySignedTransaction struct {
+	Transaction Transaction                 `json:"transaction"`
+	UTXOs       []*UTXO                     `json:"utxos"`
+	Signatures  []map[int]*crypto.Signature `json:"signatures"`
+}
+
+func NewPartiallySignedTransaction(tx *Transaction, reader UTXOReader) (*PartiallySignedTransaction, error) {
+	pst := &PartiallySignedTransaction{Transaction: *tx}
+	for _, in := range tx.Inputs {
+		if in.Deposit != nil || in.Mint != nil || len(in.Genesis) > 0 {
+			return nil, fmt.Errorf("invalid partial input %s:%d", in.Hash.String(), in.Index)
+		}
+		utxo, err := reader.ReadUTXO(in.Hash, in.Index)
+		if err != nil {
+			return nil, err
+		}
+		if utxo == nil {
+			return nil, fmt.Errorf("input not found %s:%d", in.Hash.String(), in.Index)
+		}
+		u := utxo.UTXO
+		u.Hash, u.Index = in.Hash, in.Index
+		pst.UTXOs = append(pst.UTXOs, &u)
+		pst.Signatures = append(pst.Signatures, make(map[int]*crypto.Signature))
+	}
+	return pst, nil
+}
+
+func UnmarshalPartiallySignedTransaction(val []byte) (*PartiallySignedTransaction, error) {
+	var pst PartiallySignedTransaction
+	err := MsgpackUnmarshal(val, &pst)
+	if err != nil {
+		return nil, err
+	}
+	if len(pst.UTXOs) != len(pst.Transaction.Inputs) || len(pst.Signatures) != len(pst.UTXOs) {
+		return nil, fmt.Errorf("invalid partial transaction %d %d %d", len(pst.Transaction.Inputs), len(pst.UTXOs), len(pst.Signatures))
+	}
+	for i, sigs := range pst.Signatures {
+		if sigs == nil {
+			pst.Signatures[i] = make(map[int]*crypto.Signature)
+		}
+		for k, sig := range sigs {
+			if sig == nil {
+				return nil, fmt.Errorf("invalid partial signature %d %d", i, k)
+			}
+		}
+	}
+	for i, utxo := range pst.UTXOs {
+		if utxo == nil {
+			return nil, fmt.Errorf("invalid partial utxo %d", i)
+		}
+	}
+	return &pst, nil
+}
+
+func (pst *PartiallySignedTransaction) Marshal() []byte {
+	return MsgpackMarshalPanic(pst)
+}
+
+func (pst *PartiallySignedTransaction) SignInput(index int, accounts []Address) error {
+	if index >= len(pst.UTXOs) {
+		return fmt.Errorf("invalid input index %d/%d", index, len(pst.UTXOs))
+	}
+	msg := MsgpackMarshalPanic(pst.Transaction)
+	utxo := pst.UTXOs[index]
+
+	for _, acc := range accounts {
+		priv := crypto.DeriveGhostPrivateKey(&utxo.Mask, &acc.PrivateViewKey, &acc.PrivateSpendKey, uint64(utxo.Index))
+		pub := priv.Public()
+		for i, k := range utxo.Keys {
+			if k == pub {
+				sig := priv.Sign(msg)
+				pst.Signatures[index][i] = &sig
+			}
+		}
+	}
+	return nil
+}
+
+func (pst *PartiallySignedTransaction) Merge(other *PartiallySignedTransaction) error {
+	msg := MsgpackMarshalPanic(pst.Transaction)
+	if !bytes.Equal(msg, MsgpackMarshalPanic(other.Transaction)) {
+		return fmt.Errorf("invalid partial transaction to merge")
+	}
+	for i, sigs := range other.Signatures {
+		utxo := pst.UTXOs[i]
+		for k, sig := range sigs {
+			if k < 0 || k >= len(utxo.Keys) || !utxo.Keys[k].Verify(msg, *sig) {
+				return fmt.Errorf("invalid partial signature %d %d", i, k)
+			}
+			pst.Signatures[i][k] = sig
+		}
+	}
+	return nil
+}
+
+func (pst *PartiallySignedTransaction) Finalize() (*VersionedTransaction, error) {
+	msg := MsgpackMarshalPanic(pst.Transaction)
+	signed := &SignedTransaction{Transaction: pst.Transaction}
+
+	for i, utxo := range pst.UTXOs {
+		var indexes []int
+		for k, _ := range pst.Signatures[i] {
+			indexes = append(indexes, k)
+		}
+		sort.Ints(indexes)
+
+		sigs := make([]crypto.Signature, 0)
+		for _, k := range indexes {
+			sig := pst.Signatures[i][k]
+			if k >= len(utxo.Keys) || !utxo.Keys[k].Verify(msg, *sig) {
+				return nil, fmt.Errorf("invalid partial signature %d %d", i, k)
+			}
+			sigs = append(sigs, *sig)
+		}
+		if err := utxo.Script.VerifyFormat(); err != nil {
+			return nil, err
+		}
+		if len(sigs) < int(utxo.Script[2]) {
+			return nil, fmt.Errorf("input %d signatures not enough %d %d", i, len(sigs), utxo.Script[2])
+		}
+		signed.Signatures = append(signed.Signatures, sigs)
+	}
+	return signed.AsLatestVersion(), nil
+}
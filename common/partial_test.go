@@ -0,0 +1,84 @@
+package common
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPartiallySignedTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	accounts := make([]Address, 0)
+	for i := 0; i < 3; i++ {
+		seed := make([]byte, 64)
+		seed[i] = byte(i)
+		accounts = append(accounts, NewAddressFromSeed(seed))
+	}
+	store := storeImpl{seed: bytes.Repeat([]byte{1}, 64), accounts: accounts}
+	genesisHash := crypto.Hash{}
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddInput(genesisHash, 0)
+	tx.AddInput(genesisHash, 1)
+	tx.AddScriptOutput(accounts[2:], NewThresholdScript(1), NewInteger(20000), bytes.Repeat([]byte{2}, 64))
+
+	pst, err := NewPartiallySignedTransaction(tx, store)
+	assert.Nil(err)
+	assert.Len(pst.UTXOs, 2)
+	assert.Len(pst.UTXOs[1].Keys, 2)
+	_, err = pst.Finalize()
+	assert.NotNil(err)
+
+	first, err := UnmarshalPartiallySignedTransaction(pst.Marshal())
+	assert.Nil(err)
+	err = first.SignInput(0, accounts[:1])
+	assert.Nil(err)
+	err = first.SignInput(1, accounts[:1])
+	assert.Nil(err)
+	assert.Len(first.Signatures[1], 1)
+	_, err = first.Finalize()
+	assert.NotNil(err)
+
+	second, err := UnmarshalPartiallySignedTransaction(pst.Marshal())
+	assert.Nil(err)
+	err = second.SignInput(1, accounts[1:2])
+	assert.Nil(err)
+	err = second.SignInput(2, accounts[1:2])
+	assert.NotNil(err)
+
+	second, err = UnmarshalPartiallySignedTransaction(second.Marshal())
+	assert.Nil(err)
+	err = first.Merge(second)
+	assert.Nil(err)
+	assert.Len(first.Signatures[1], 2)
+
+	ver, err := first.Finalize()
+	assert.Nil(err)
+	assert.Len(ver.Signatures, 2)
+	assert.Len(ver.Signatures[1], 2)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()))
+	assert.Nil(err)
+
+	other := NewTransaction(XINAssetId)
+	other.AddInput(genesisHash, 0)
+	other.AddScriptOutput(accounts[2:], NewThresholdScript(1), NewInteger(10000), bytes.Repeat([]byte{3}, 64))
+	opst, err := NewPartiallySignedTransaction(other, store)
+	assert.Nil(err)
+	err = first.Merge(opst)
+	assert.NotNil(err)
+
+	nilsig, err := UnmarshalPartiallySignedTransaction(pst.Marshal())
+	assert.Nil(err)
+	nilsig.Signatures[0][0] = nil
+	_, err = UnmarshalPartiallySignedTransaction(nilsig.Marshal())
+	assert.NotNil(err)
+
+	tx = NewTransaction(XINAssetId)
+	tx.AddDepositInput(&DepositData{})
+	_, err = NewPartiallySignedTransaction(tx, store)
+	assert.NotNil(err)
+}
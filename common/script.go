@@ -1,16 +1,22 @@
 package common
 
 import (
+	"encoding/binary"
 	"encoding/hex"
-	"fmt"
 	"strconv"
+
+	"github.com/MixinNetwork/mixin/crypto"
 )
 
 const (
-	Operator0   = 0x00
-	Operator64  = 0x40
-	OperatorSum = 0xfe
-	OperatorCmp = 0xff
+	PreimageSizeLimit = 64
+
+	Operator0    = 0x00
+	Operator64   = 0x40
+	OperatorHash = 0xfc
+	OperatorTime = 0xfd
+	OperatorSum  = 0xfe
+	OperatorCmp  = 0xff
 )
 
 type Script []uint8
@@ -19,23 +25,117 @@ func NewThresholdScript(threshold uint8) Script {
 	return Script{OperatorCmp, OperatorSum, threshold}
 }
 
+// the lock is only a snapshot timestamp, which is signed by the consensus nodes
+// before the transaction is finalized, a topology order lock is not supported
+// because the order is assigned by each node after the finalization, so no node
+// could check it when it validates the transaction
+func NewTimelockScript(threshold uint8, timestamp uint64) Script {
+	lock := make([]byte, 8)
+	binary.BigEndian.PutUint64(lock, timestamp)
+	s := Script{OperatorCmp, OperatorSum, threshold, OperatorTime}
+	return append(s, lock...)
+}
+
+// the first recipients keys of the output can spend it with the preimage of lock,
+// the remaining keys can get a refund after the timestamp
+func NewHashLockScript(threshold uint8, lock crypto.Hash, timestamp uint64, recipients uint8) Script {
+	refund := make([]byte, 8)
+	binary.BigEndian.PutUint64(refund, timestamp)
+	s := Script{OperatorCmp, OperatorSum, threshold, OperatorHash}
+	s = append(s, lock[:]...)
+	s = append(s, OperatorTime)
+	s = append(s, refund...)
+	return append(s, recipients)
+}
+
 func (s Script) VerifyFormat() error {
-	if len(s) != 3 {
-		return fmt.Errorf("invalid script %d", len(s))
+	if len(s) != 3 && len(s) != 12 && len(s) != 46 {
+		return NewValidationError(ErrorCodeInvalidScript, "invalid script %d", len(s))
 	}
 	if s[0] != OperatorCmp || s[1] != OperatorSum {
-		return fmt.Errorf("invalid script %d %d", s[0], s[1])
+		return NewValidationError(ErrorCodeInvalidScript, "invalid script %d %d", s[0], s[1])
+	}
+	if len(s) == 12 && s[3] != OperatorTime {
+		return NewValidationError(ErrorCodeInvalidScript, "invalid script lock operator %d", s[3])
+	}
+	if len(s) == 46 && (s[3] != OperatorHash || s[36] != OperatorTime) {
+		return NewValidationError(ErrorCodeInvalidScript, "invalid script lock operator %d %d", s[3], s[36])
+	}
+	if len(s) == 46 && s[45] == 0 {
+		return NewValidationError(ErrorCodeInvalidScript, "invalid script hash lock recipients %d", s[45])
+	}
+	return nil
+}
+
+func (s Script) Locktime() uint64 {
+	if len(s) != 12 || s[3] != OperatorTime {
+		return 0
+	}
+	return binary.BigEndian.Uint64(s[4:])
+}
+
+func (s Script) IsHashLock() bool {
+	return len(s) == 46 && s[3] == OperatorHash
+}
+
+func (s Script) HashLock() (lock crypto.Hash, refund uint64, recipients int) {
+	if !s.IsHashLock() {
+		return
+	}
+	copy(lock[:], s[4:36])
+	refund = binary.BigEndian.Uint64(s[37:45])
+	recipients = int(s[45])
+	return
+}
+
+func (s Script) ValidateHashLock(preimage []byte, recipients, refunds int, timestamp uint64) error {
+	err := s.VerifyFormat()
+	if err != nil {
+		return err
+	}
+	if !s.IsHashLock() {
+		return NewValidationError(ErrorCodeInvalidScript, "invalid hash lock script %s", s)
+	}
+	lock, refund, _ := s.HashLock()
+	if len(preimage) > 0 {
+		if len(preimage) > PreimageSizeLimit {
+			return NewValidationError(ErrorCodeInvalidPreimage, "invalid hash lock preimage size %d", len(preimage))
+		}
+		if crypto.NewHash(preimage) != lock {
+			return NewValidationError(ErrorCodeInvalidPreimage, "invalid hash lock preimage %x", preimage)
+		}
+		if recipients < int(s[2]) {
+			return NewValidationError(ErrorCodeInvalidSignature, "invalid signature keys %d %d", recipients, s[2]).
+				With("signatures", recipients).With("threshold", s[2])
+		}
+		return nil
+	}
+	if timestamp < refund {
+		return NewValidationError(ErrorCodeScriptLocked, "script locked until %d %d", refund, timestamp).
+			With("locktime", refund).With("timestamp", timestamp)
+	}
+	if refunds < int(s[2]) {
+		return NewValidationError(ErrorCodeInvalidSignature, "invalid signature keys %d %d", refunds, s[2]).
+			With("signatures", refunds).With("threshold", s[2])
 	}
 	return nil
 }
 
-func (s Script) Validate(sum int) error {
+func (s Script) Validate(sum int, timestamp uint64) error {
 	err := s.VerifyFormat()
 	if err != nil {
 		return err
 	}
+	if s.IsHashLock() {
+		return NewValidationError(ErrorCodeInvalidScript, "hash lock script %s requires preimage or refund", s)
+	}
 	if sum < int(s[2]) {
-		return fmt.Errorf("invalid signature keys %d %d", sum, s[2])
+		return NewValidationError(ErrorCodeInvalidSignature, "invalid signature keys %d %d", sum, s[2]).
+			With("signatures", sum).With("threshold", s[2])
+	}
+	if lock := s.Locktime(); timestamp < lock {
+		return NewValidationError(ErrorCodeScriptLocked, "script locked until %d %d", lock, timestamp).
+			With("locktime", lock).With("timestamp", timestamp)
 	}
 	return nil
 }
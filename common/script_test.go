@@ -3,6 +3,7 @@ package common
 import (
 	"testing"
 
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -10,31 +11,31 @@ func TestScript(t *testing.T) {
 	assert := assert.New(t)
 
 	s := Script([]byte{})
-	err := s.Validate(0)
+	err := s.Validate(0, 0)
 	assert.NotNil(err)
 
 	s = Script([]byte{OperatorSum, OperatorSum, 0})
-	err = s.Validate(0)
+	err = s.Validate(0, 0)
 	assert.NotNil(err)
 
 	s = Script([]byte{OperatorCmp, OperatorCmp, 0})
-	err = s.Validate(0)
+	err = s.Validate(0, 0)
 	assert.NotNil(err)
 
 	s = Script([]byte{OperatorCmp, OperatorSum, 0})
-	err = s.Validate(0)
+	err = s.Validate(0, 0)
 	assert.Nil(err)
 	s = Script([]byte{OperatorCmp, OperatorSum, 0})
-	err = s.Validate(1)
+	err = s.Validate(1, 0)
 	assert.Nil(err)
 	s = Script([]byte{OperatorCmp, OperatorSum, 1})
-	err = s.Validate(0)
+	err = s.Validate(0, 0)
 	assert.NotNil(err)
 	s = Script([]byte{OperatorCmp, OperatorSum, 1})
-	err = s.Validate(1)
+	err = s.Validate(1, 0)
 	assert.Nil(err)
 	s = Script([]byte{OperatorCmp, OperatorSum, 1})
-	err = s.Validate(2)
+	err = s.Validate(2, 0)
 	assert.Nil(err)
 
 	j, err := s.MarshalJSON()
@@ -42,7 +43,75 @@ func TestScript(t *testing.T) {
 	assert.Equal("\"fffe01\"", string(j))
 	err = s.UnmarshalJSON(j)
 	assert.Nil(err)
-	err = s.Validate(1)
+	err = s.Validate(1, 0)
 	assert.Nil(err)
 	assert.Equal("fffe01", s.String())
 }
+
+func TestTimelockScript(t *testing.T) {
+	assert := assert.New(t)
+
+	s := NewTimelockScript(1, 1500000000000000000)
+	assert.Equal("fffe01fd14d1120d7b160000", s.String())
+	assert.Equal(uint64(1500000000000000000), s.Locktime())
+	assert.Nil(s.VerifyFormat())
+	err := s.Validate(1, 1500000000000000000)
+	assert.Nil(err)
+	err = s.Validate(1, 1600000000000000000)
+	assert.Nil(err)
+	err = s.Validate(1, 1400000000000000000)
+	assert.NotNil(err)
+	err = s.Validate(0, 1600000000000000000)
+	assert.NotNil(err)
+
+	s = NewThresholdScript(1)
+	assert.Equal(uint64(0), s.Locktime())
+	err = s.Validate(1, 0)
+	assert.Nil(err)
+
+	s = Script([]byte{OperatorCmp, OperatorSum, 1, OperatorSum, 0, 0, 0, 0, 0, 0, 0, 0})
+	assert.NotNil(s.VerifyFormat())
+	s = Script([]byte{OperatorCmp, OperatorSum, 1, OperatorTime, 0, 0, 0, 0})
+	assert.NotNil(s.VerifyFormat())
+
+	j, err := NewTimelockScript(2, 1500000000000000000).MarshalJSON()
+	assert.Nil(err)
+	assert.Equal("\"fffe02fd14d1120d7b160000\"", string(j))
+	err = s.UnmarshalJSON(j)
+	assert.Nil(err)
+	assert.Equal(uint64(1500000000000000000), s.Locktime())
+	err = s.Validate(2, 1500000000000000001)
+	assert.Nil(err)
+}
+
+func TestHashLockScript(t *testing.T) {
+	assert := assert.New(t)
+
+	preimage := []byte("mixin hash lock preimage")
+	lock := crypto.NewHash(preimage)
+	s := NewHashLockScript(1, lock, 1500000000000000000, 2)
+	assert.Len(s, 46)
+	assert.Nil(s.VerifyFormat())
+	assert.True(s.IsHashLock())
+	assert.Equal(uint64(0), s.Locktime())
+	l, refund, recipients := s.HashLock()
+	assert.Equal(lock, l)
+	assert.Equal(uint64(1500000000000000000), refund)
+	assert.Equal(2, recipients)
+	assert.Equal("fffe01fc"+lock.String()+"fd14d1120d7b16000002", s.String())
+
+	assert.NotNil(s.Validate(1, 1600000000000000000))
+	assert.Nil(s.ValidateHashLock(preimage, 1, 0, 0))
+	assert.NotNil(s.ValidateHashLock(preimage, 0, 1, 0))
+	assert.NotNil(s.ValidateHashLock([]byte("invalid"), 1, 0, 0))
+	assert.NotNil(s.ValidateHashLock(nil, 1, 1, 1400000000000000000))
+	assert.NotNil(s.ValidateHashLock(nil, 1, 0, 1600000000000000000))
+	assert.Nil(s.ValidateHashLock(nil, 0, 1, 1600000000000000000))
+	assert.NotNil(NewThresholdScript(1).ValidateHashLock(preimage, 1, 0, 0))
+
+	s = NewHashLockScript(1, lock, 1500000000000000000, 0)
+	assert.NotNil(s.VerifyFormat())
+	s = NewHashLockScript(1, lock, 1500000000000000000, 1)
+	s[36] = OperatorHash
+	assert.NotNil(s.VerifyFormat())
+}
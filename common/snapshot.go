@@ -16,6 +16,7 @@ type Round struct {
 	Number     uint64      `json:"number"`
 	Timestamp  uint64      `json:"timestamp"`
 	References *RoundLink  `json:"references"`
+	UTXORoot   crypto.Hash `json:"utxo_root"`
 }
 
 type RoundLink struct {
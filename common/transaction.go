@@ -45,6 +45,9 @@ type Input struct {
 	Genesis []byte       `json:"genesis,omitempty"`
 	Deposit *DepositData `json:"deposit,omitempty"`
 	Mint    *MintData    `json:"mint,omitempty"`
+
+	// hash lock script preimage
+	Preimage []byte `msgpack:",omitempty" json:"preimage,omitempty"`
 }
 
 type Output struct {
@@ -237,6 +240,15 @@ func (tx *Transaction) AddInput(hash crypto.Hash, index int) {
 	tx.Inputs = append(tx.Inputs, in)
 }
 
+func (tx *Transaction) AddHashLockInput(hash crypto.Hash, index int, preimage []byte) {
+	in := &Input{
+		Hash:     hash,
+		Index:    index,
+		Preimage: preimage,
+	}
+	tx.Inputs = append(tx.Inputs, in)
+}
+
 func (tx *Transaction) AddOutputWithType(ot uint8, accounts []Address, s Script, amount Integer, seed []byte) {
 	out := &Output{
 		Type:   ot,
@@ -261,6 +273,12 @@ func (tx *Transaction) AddScriptOutput(accounts []Address, s Script, amount Inte
 	tx.AddOutputWithType(OutputTypeScript, accounts, s, amount, seed)
 }
 
+func (tx *Transaction) AddHashLockOutput(recipients, refunds []Address, threshold uint8, lock crypto.Hash, timestamp uint64, amount Integer, seed []byte) {
+	s := NewHashLockScript(threshold, lock, timestamp, uint8(len(recipients)))
+	accounts := append(append([]Address{}, recipients...), refunds...)
+	tx.AddScriptOutput(accounts, s, amount, seed)
+}
+
 func (tx *Transaction) AddRandomScriptOutput(accounts []Address, s Script, amount Integer) error {
 	seed := make([]byte, 64)
 	_, err := rand.Read(seed)
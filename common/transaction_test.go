@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"testing"
+	"time"
 
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/assert"
@@ -72,7 +73,7 @@ func TestTransaction(t *testing.T) {
 		err := ver.SignInput(store, i, accounts)
 		assert.Nil(err)
 	}
-	err = ver.Validate(store)
+	err = ver.Validate(store, uint64(time.Now().UnixNano()))
 	assert.Nil(err)
 
 	outputs := ver.ViewGhostKey(&accounts[1].PrivateViewKey)
@@ -163,3 +164,83 @@ func randomAccount() Address {
 	rand.Read(seed)
 	return NewAddressFromSeed(seed)
 }
+
+func TestHashLockTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	preimage := []byte("mixin hash lock preimage")
+	recipient, refund := randomAccount(), randomAccount()
+	store := hashLockStoreImpl{
+		storeImpl: storeImpl{seed: bytes.Repeat([]byte{1}, 64)},
+		recipient: recipient,
+		refund:    refund,
+		script:    NewHashLockScript(1, crypto.NewHash(preimage), 1500000000000000000, 1),
+	}
+	genesisHash := crypto.Hash{}
+
+	tx := NewTransaction(XINAssetId)
+	tx.AddHashLockInput(genesisHash, 0, preimage)
+	tx.AddScriptOutput([]Address{recipient}, NewThresholdScript(1), NewInteger(10000), bytes.Repeat([]byte{2}, 64))
+	ver := tx.AsLatestVersion()
+	err := ver.SignInput(store, 0, []Address{recipient})
+	assert.Nil(err)
+	err = ver.Validate(store, 1400000000000000000)
+	assert.Nil(err)
+
+	tx = NewTransaction(XINAssetId)
+	tx.AddHashLockInput(genesisHash, 0, []byte("invalid"))
+	tx.AddScriptOutput([]Address{recipient}, NewThresholdScript(1), NewInteger(10000), bytes.Repeat([]byte{2}, 64))
+	ver = tx.AsLatestVersion()
+	err = ver.SignInput(store, 0, []Address{recipient})
+	assert.Nil(err)
+	err = ver.Validate(store, 1400000000000000000)
+	assert.NotNil(err)
+
+	tx = NewTransaction(XINAssetId)
+	tx.AddInput(genesisHash, 0)
+	tx.AddScriptOutput([]Address{refund}, NewThresholdScript(1), NewInteger(10000), bytes.Repeat([]byte{2}, 64))
+	ver = tx.AsLatestVersion()
+	err = ver.SignInput(store, 0, []Address{refund})
+	assert.Nil(err)
+	err = ver.Validate(store, 1400000000000000000)
+	assert.NotNil(err)
+	err = ver.Validate(store, 1600000000000000000)
+	assert.Nil(err)
+
+	ver = tx.AsLatestVersion()
+	err = ver.SignInput(store, 0, []Address{recipient})
+	assert.Nil(err)
+	err = ver.Validate(store, 1600000000000000000)
+	assert.NotNil(err)
+}
+
+type hashLockStoreImpl struct {
+	storeImpl
+	recipient Address
+	refund    Address
+	script    Script
+}
+
+func (store hashLockStoreImpl) ReadUTXO(hash crypto.Hash, index int) (*UTXOWithLock, error) {
+	mask := crypto.NewKeyFromSeed(store.seed)
+	utxo := &UTXOWithLock{
+		UTXO: UTXO{
+			Input: Input{
+				Hash:  hash,
+				Index: index,
+			},
+			Output: Output{
+				Type:   OutputTypeScript,
+				Amount: NewInteger(10000),
+				Script: store.script,
+				Mask:   mask.Public(),
+			},
+			Asset: XINAssetId,
+		},
+	}
+	for _, a := range []Address{store.recipient, store.refund} {
+		key := crypto.DeriveGhostPublicKey(&mask, &a.PublicViewKey, &a.PublicSpendKey, uint64(index))
+		utxo.Keys = append(utxo.Keys, *key)
+	}
+	return utxo, nil
+}
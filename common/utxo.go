@@ -48,6 +48,13 @@ type DataStore interface {
 	DomainReader
 }
 
+// SignatureCache is optional for the DataStore, the input signature checks
+// only depend on the transaction and the UTXO keys, so they are safe to share
+type SignatureCache interface {
+	ReadSignatureResult(msg crypto.Hash, key crypto.Key, sig crypto.Signature) (bool, bool)
+	WriteSignatureResult(msg crypto.Hash, key crypto.Key, sig crypto.Signature, valid bool)
+}
+
 func (tx *VersionedTransaction) UnspentOutputs() []*UTXO {
 	var utxos []*UTXO
 	for i, out := range tx.Outputs {
@@ -56,6 +63,8 @@ func (tx *VersionedTransaction) UnspentOutputs() []*UTXO {
 			OutputTypeNodePledge,
 			OutputTypeNodeCancel,
 			OutputTypeNodeAccept,
+			OutputTypeNodeDepart,
+			OutputTypeNodeRemove,
 			OutputTypeDomainAccept,
 			OutputTypeWithdrawalFuel,
 			OutputTypeWithdrawalClaim:
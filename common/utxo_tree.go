@@ -0,0 +1,97 @@
+package common
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	UTXOTreeDepth = 256
+)
+
+// the utxo tree is a sparse merkle tree with 256 bits keys, an empty subtree
+// has the zero hash, and a subtree with a single leaf has the hash of the leaf
+type UTXOProofLeaf struct {
+	Key   crypto.Hash `json:"key"`
+	Value crypto.Hash `json:"value"`
+}
+
+type UTXOProof struct {
+	Key      crypto.Hash    `json:"key"`
+	Siblings []crypto.Hash  `json:"siblings"`
+	Leaf     *UTXOProofLeaf `json:"leaf,omitempty" msgpack:",omitempty"`
+}
+
+func UTXOTreeKey(hash crypto.Hash, index int) crypto.Hash {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, uint64(index))
+	return crypto.NewHash(append(hash[:], buf...))
+}
+
+func (utxo *UTXO) TreeValue() crypto.Hash {
+	return crypto.NewHash(MsgpackMarshalPanic(utxo))
+}
+
+func UTXOTreeLeafHash(key, value crypto.Hash) crypto.Hash {
+	data := append([]byte{0}, key[:]...)
+	return crypto.NewHash(append(data, value[:]...))
+}
+
+func UTXOTreeNodeHash(left, right crypto.Hash) crypto.Hash {
+	data := append([]byte{1}, left[:]...)
+	return crypto.NewHash(append(data, right[:]...))
+}
+
+func UTXOTreeBit(key crypto.Hash, depth int) int {
+	return int(key[depth/8]>>uint(7-depth%8)) & 1
+}
+
+func (p *UTXOProof) VerifyInclusion(root crypto.Hash, utxo *UTXO) error {
+	key := UTXOTreeKey(utxo.Hash, utxo.Index)
+	if p.Key != key {
+		return fmt.Errorf("invalid utxo proof key %s %s", p.Key, key)
+	}
+	if p.Leaf == nil || p.Leaf.Key != key || p.Leaf.Value != utxo.TreeValue() {
+		return fmt.Errorf("invalid utxo inclusion proof leaf %s", key)
+	}
+	return p.verify(root)
+}
+
+// the exclusion proof ends at either an empty subtree or a leaf with another key
+// in the same subtree, which proves there is no leaf for the key
+func (p *UTXOProof) VerifyExclusion(root crypto.Hash) error {
+	if p.Leaf != nil {
+		if p.Leaf.Key == p.Key {
+			return fmt.Errorf("invalid utxo exclusion proof leaf %s", p.Key)
+		}
+		for i := range p.Siblings {
+			if UTXOTreeBit(p.Leaf.Key, i) != UTXOTreeBit(p.Key, i) {
+				return fmt.Errorf("invalid utxo exclusion proof path %s %s", p.Key, p.Leaf.Key)
+			}
+		}
+	}
+	return p.verify(root)
+}
+
+func (p *UTXOProof) verify(root crypto.Hash) error {
+	if len(p.Siblings) > UTXOTreeDepth {
+		return fmt.Errorf("invalid utxo proof depth %d", len(p.Siblings))
+	}
+	var hash crypto.Hash
+	if p.Leaf != nil {
+		hash = UTXOTreeLeafHash(p.Leaf.Key, p.Leaf.Value)
+	}
+	for i := len(p.Siblings) - 1; i >= 0; i-- {
+		if UTXOTreeBit(p.Key, i) == 0 {
+			hash = UTXOTreeNodeHash(hash, p.Siblings[i])
+		} else {
+			hash = UTXOTreeNodeHash(p.Siblings[i], hash)
+		}
+	}
+	if hash != root {
+		return fmt.Errorf("invalid utxo proof root %s %s", hash, root)
+	}
+	return nil
+}
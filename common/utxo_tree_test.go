@@ -0,0 +1,40 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTXOTreeProof(t *testing.T) {
+	assert := assert.New(t)
+
+	utxo := &UTXO{Asset: XINAssetId}
+	utxo.Hash = crypto.NewHash([]byte("utxo-tree-proof"))
+	utxo.Index = 1
+	utxo.Type = OutputTypeScript
+	utxo.Amount = NewInteger(100)
+
+	key := UTXOTreeKey(utxo.Hash, utxo.Index)
+	assert.NotEqual(key, UTXOTreeKey(utxo.Hash, 0))
+	root := UTXOTreeLeafHash(key, utxo.TreeValue())
+
+	proof := &UTXOProof{Key: key, Leaf: &UTXOProofLeaf{Key: key, Value: utxo.TreeValue()}}
+	assert.Nil(proof.VerifyInclusion(root, utxo))
+	assert.NotNil(proof.VerifyExclusion(root))
+	assert.NotNil(proof.VerifyInclusion(crypto.Hash{}, utxo))
+	utxo.Amount = NewInteger(101)
+	assert.NotNil(proof.VerifyInclusion(root, utxo))
+
+	other := UTXOTreeKey(utxo.Hash, 0)
+	proof = &UTXOProof{Key: other, Leaf: &UTXOProofLeaf{Key: key, Value: proof.Leaf.Value}}
+	assert.Nil(proof.VerifyExclusion(root))
+	proof.Siblings = []crypto.Hash{{}}
+	if UTXOTreeBit(key, 0) != UTXOTreeBit(other, 0) {
+		assert.NotNil(proof.VerifyExclusion(root))
+	}
+	proof = &UTXOProof{Key: other}
+	assert.Nil(proof.VerifyExclusion(crypto.Hash{}))
+	assert.NotNil(proof.VerifyExclusion(root))
+}
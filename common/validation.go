@@ -7,31 +7,31 @@ import (
 	"github.com/MixinNetwork/mixin/crypto"
 )
 
-func (ver *VersionedTransaction) Validate(store DataStore) error {
+func (ver *VersionedTransaction) Validate(store DataStore, timestamp uint64) error {
 	tx := &ver.SignedTransaction
 	msg := ver.PayloadMarshal()
 	txType := tx.TransactionType()
 
 	if ver.Version != TxVersion || tx.Version != TxVersion {
-		return fmt.Errorf("invalid tx version %d %d", ver.Version, tx.Version)
+		return NewValidationError(ErrorCodeInvalidVersion, "invalid tx version %d %d", ver.Version, tx.Version)
 	}
 	if txType == TransactionTypeUnknown {
-		return fmt.Errorf("invalid tx type %d", txType)
+		return NewValidationError(ErrorCodeInvalidTransactionType, "invalid tx type %d", txType)
 	}
 	if len(tx.Inputs) < 1 || len(tx.Outputs) < 1 {
-		return fmt.Errorf("invalid tx inputs or outputs %d %d", len(tx.Inputs), len(tx.Outputs))
+		return NewValidationError(ErrorCodeInvalidCount, "invalid tx inputs or outputs %d %d", len(tx.Inputs), len(tx.Outputs))
 	}
-	if len(tx.Inputs) != len(tx.Signatures) && txType != TransactionTypeNodeAccept {
-		return fmt.Errorf("invalid tx signature number %d %d %d", len(tx.Inputs), len(tx.Signatures), txType)
+	if len(tx.Inputs) != len(tx.Signatures) && txType != TransactionTypeNodeAccept && txType != TransactionTypeNodeRemove {
+		return NewValidationError(ErrorCodeInvalidCount, "invalid tx signature number %d %d %d", len(tx.Inputs), len(tx.Signatures), txType)
 	}
 	if len(tx.Extra) > ExtraSizeLimit {
-		return fmt.Errorf("invalid extra size %d", len(tx.Extra))
+		return NewValidationError(ErrorCodeInvalidExtra, "invalid extra size %d", len(tx.Extra))
 	}
 	if len(ver.Marshal()) > config.TransactionMaximumSize {
-		return fmt.Errorf("invalid transaction size %d", len(msg))
+		return NewValidationError(ErrorCodeInvalidSize, "invalid transaction size %d", len(msg))
 	}
 
-	inputsFilter, inputAmount, err := validateInputs(store, tx, msg, ver.PayloadHash(), txType)
+	inputsFilter, inputAmount, err := validateInputs(store, tx, msg, ver.PayloadHash(), txType, timestamp)
 	if err != nil {
 		return err
 	}
@@ -41,7 +41,8 @@ func (ver *VersionedTransaction) Validate(store DataStore) error {
 	}
 
 	if inputAmount.Sign() <= 0 || inputAmount.Cmp(outputAmount) != 0 {
-		return fmt.Errorf("invalid input output amount %s %s", inputAmount.String(), outputAmount.String())
+		return NewValidationError(ErrorCodeAmountMismatch, "invalid input output amount %s %s", inputAmount.String(), outputAmount.String()).
+			With("inputs", inputAmount.String()).With("outputs", outputAmount.String())
 	}
 
 	switch txType {
@@ -64,42 +65,46 @@ func (ver *VersionedTransaction) Validate(store DataStore) error {
 	case TransactionTypeNodeAccept:
 		return tx.validateNodeAccept(store)
 	case TransactionTypeNodeDepart:
-		return fmt.Errorf("invalid transaction type %d", txType)
+		return tx.validateNodeDepart(store, inputsFilter, msg, ver.Signatures)
 	case TransactionTypeNodeRemove:
-		return fmt.Errorf("invalid transaction type %d", txType)
+		return tx.validateNodeRemove(store, msg)
 	case TransactionTypeDomainAccept:
-		return fmt.Errorf("invalid transaction type %d", txType)
+		return NewValidationError(ErrorCodeInvalidTransactionType, "invalid transaction type %d", txType)
 	case TransactionTypeDomainRemove:
-		return fmt.Errorf("invalid transaction type %d", txType)
+		return NewValidationError(ErrorCodeInvalidTransactionType, "invalid transaction type %d", txType)
 	}
-	return fmt.Errorf("invalid transaction type %d", txType)
+	return NewValidationError(ErrorCodeInvalidTransactionType, "invalid transaction type %d", txType)
 }
 
 func validateScriptTransaction(inputs map[string]*UTXO) error {
 	for _, in := range inputs {
-		if in.Type != OutputTypeScript {
-			return fmt.Errorf("invalid utxo type %d", in.Type)
+		if in.Type != OutputTypeScript && in.Type != OutputTypeNodeRemove {
+			return NewValidationError(ErrorCodeInvalidInputType, "invalid utxo type %d", in.Type)
 		}
 	}
 	return nil
 }
 
-func validateInputs(store DataStore, tx *SignedTransaction, msg []byte, hash crypto.Hash, txType uint8) (map[string]*UTXO, Integer, error) {
+func validateInputs(store DataStore, tx *SignedTransaction, msg []byte, hash crypto.Hash, txType uint8, timestamp uint64) (map[string]*UTXO, Integer, error) {
 	inputAmount := NewInteger(0)
 	inputsFilter := make(map[string]*UTXO)
+	keys := make([]string, 0, len(tx.Inputs))
+	utxos := make([]*UTXO, 0, len(tx.Inputs))
 
-	for i, in := range tx.Inputs {
+	for _, in := range tx.Inputs {
 		if in.Mint != nil {
-			return inputsFilter, in.Mint.Amount, nil
+			err := validateInputsUTXOs(store, tx, keys, utxos, msg, hash, txType, timestamp)
+			return inputsFilter, in.Mint.Amount, err
 		}
 
 		if in.Deposit != nil {
-			return inputsFilter, in.Deposit.Amount, nil
+			err := validateInputsUTXOs(store, tx, keys, utxos, msg, hash, txType, timestamp)
+			return inputsFilter, in.Deposit.Amount, err
 		}
 
 		fk := fmt.Sprintf("%s:%d", in.Hash.String(), in.Index)
 		if inputsFilter[fk] != nil {
-			return inputsFilter, inputAmount, fmt.Errorf("invalid input %s", fk)
+			return inputsFilter, inputAmount, NewValidationError(ErrorCodeInputDuplicated, "invalid input %s", fk).With("input", fk)
 		}
 
 		utxo, err := store.ReadUTXO(in.Hash, in.Index)
@@ -107,24 +112,43 @@ func validateInputs(store DataStore, tx *SignedTransaction, msg []byte, hash cry
 			return inputsFilter, inputAmount, err
 		}
 		if utxo == nil {
-			return inputsFilter, inputAmount, fmt.Errorf("input not found %s:%d", in.Hash.String(), in.Index)
+			return inputsFilter, inputAmount, NewValidationError(ErrorCodeInputNotFound, "input not found %s:%d", in.Hash.String(), in.Index).With("input", fk)
 		}
 		if utxo.Asset != tx.Asset {
-			return inputsFilter, inputAmount, fmt.Errorf("invalid input asset %s %s", utxo.Asset.String(), tx.Asset.String())
+			return inputsFilter, inputAmount, NewValidationError(ErrorCodeInvalidAsset, "invalid input asset %s %s", utxo.Asset.String(), tx.Asset.String())
 		}
 		if utxo.LockHash.HasValue() && utxo.LockHash != hash {
-			return inputsFilter, inputAmount, fmt.Errorf("input locked for transaction %s", utxo.LockHash)
+			return inputsFilter, inputAmount, NewValidationError(ErrorCodeInputLocked, "input locked for transaction %s", utxo.LockHash).
+				With("input", fk).With("lock", utxo.LockHash)
 		}
 
-		err = validateUTXO(i, &utxo.UTXO, tx.Signatures, msg, txType)
-		if err != nil {
-			return inputsFilter, inputAmount, err
+		if len(in.Preimage) > 0 && !utxo.Script.IsHashLock() {
+			return inputsFilter, inputAmount, NewValidationError(ErrorCodeInvalidScript, "invalid input preimage for script %s", utxo.Script).With("input", fk)
 		}
+
 		inputsFilter[fk] = &utxo.UTXO
 		inputAmount = inputAmount.Add(utxo.Amount)
+		keys = append(keys, fk)
+		utxos = append(utxos, &utxo.UTXO)
 	}
 
-	return inputsFilter, inputAmount, nil
+	err := validateInputsUTXOs(store, tx, keys, utxos, msg, hash, txType, timestamp)
+	return inputsFilter, inputAmount, err
+}
+
+func validateInputsUTXOs(store DataStore, tx *SignedTransaction, keys []string, utxos []*UTXO, msg []byte, hash crypto.Hash, txType uint8, timestamp uint64) error {
+	sv := &signatureVerifier{msg: msg, hash: hash}
+	sv.cache, _ = store.(SignatureCache)
+	verified := sv.batchVerifyInputs(utxos, tx.Signatures)
+	for i, utxo := range utxos {
+		err := validateUTXO(sv, i, utxo, tx.Signatures, txType, tx.Inputs[i].Preimage, timestamp, verified[i])
+		if ve, ok := err.(*ValidationError); ok {
+			return ve.With("input", keys[i])
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func validateOutputs(store DataStore, tx *SignedTransaction) (Integer, error) {
@@ -132,7 +156,7 @@ func validateOutputs(store DataStore, tx *SignedTransaction) (Integer, error) {
 	outputsFilter := make(map[crypto.Key]bool)
 	for _, o := range tx.Outputs {
 		if o.Amount.Sign() <= 0 {
-			return outputAmount, fmt.Errorf("invalid output amount %s", o.Amount.String())
+			return outputAmount, NewValidationError(ErrorCodeInvalidAmount, "invalid output amount %s", o.Amount.String())
 		}
 
 		if o.Withdrawal != nil {
@@ -142,17 +166,17 @@ func validateOutputs(store DataStore, tx *SignedTransaction) (Integer, error) {
 
 		for _, k := range o.Keys {
 			if outputsFilter[k] {
-				return outputAmount, fmt.Errorf("invalid output key %s", k.String())
+				return outputAmount, NewValidationError(ErrorCodeGhostKeyReused, "invalid output key %s", k.String()).With("key", k)
 			}
 			outputsFilter[k] = true
 			if !k.CheckKey() {
-				return outputAmount, fmt.Errorf("invalid output key format %s", k.String())
+				return outputAmount, NewValidationError(ErrorCodeInvalidOutput, "invalid output key format %s", k.String())
 			}
 			exist, err := store.CheckGhost(k)
 			if err != nil {
 				return outputAmount, err
 			} else if exist {
-				return outputAmount, fmt.Errorf("invalid output key %s", k.String())
+				return outputAmount, NewValidationError(ErrorCodeGhostKeyReused, "invalid output key %s", k.String()).With("key", k)
 			}
 		}
 
@@ -162,26 +186,32 @@ func validateOutputs(store DataStore, tx *SignedTransaction) (Integer, error) {
 			OutputTypeWithdrawalClaim,
 			OutputTypeNodePledge,
 			OutputTypeNodeCancel,
-			OutputTypeNodeAccept:
+			OutputTypeNodeAccept,
+			OutputTypeNodeDepart:
 			if len(o.Keys) != 0 {
-				return outputAmount, fmt.Errorf("invalid output keys count %d for kernel multisig transaction", len(o.Keys))
+				return outputAmount, NewValidationError(ErrorCodeInvalidOutput, "invalid output keys count %d for kernel multisig transaction", len(o.Keys))
 			}
 			if len(o.Script) != 0 {
-				return outputAmount, fmt.Errorf("invalid output script %s for kernel multisig transaction", o.Script)
+				return outputAmount, NewValidationError(ErrorCodeInvalidOutput, "invalid output script %s for kernel multisig transaction", o.Script)
 			}
 			if o.Mask.HasValue() {
-				return outputAmount, fmt.Errorf("invalid output empty mask %s for kernel multisig transaction", o.Mask)
+				return outputAmount, NewValidationError(ErrorCodeInvalidOutput, "invalid output empty mask %s for kernel multisig transaction", o.Mask)
 			}
 		default:
 			err := o.Script.VerifyFormat()
 			if err != nil {
 				return outputAmount, err
 			}
+			if o.Script.IsHashLock() {
+				if _, _, n := o.Script.HashLock(); n >= len(o.Keys) {
+					return outputAmount, NewValidationError(ErrorCodeInvalidScript, "invalid hash lock keys count %d %d", n, len(o.Keys))
+				}
+			}
 			if !o.Mask.HasValue() {
-				return outputAmount, fmt.Errorf("invalid script output empty mask %s", o.Mask)
+				return outputAmount, NewValidationError(ErrorCodeInvalidOutput, "invalid script output empty mask %s", o.Mask)
 			}
 			if o.Withdrawal != nil {
-				return outputAmount, fmt.Errorf("invalid script output with withdrawal %s", o.Withdrawal.Address)
+				return outputAmount, NewValidationError(ErrorCodeInvalidOutput, "invalid script output with withdrawal %s", o.Withdrawal.Address)
 			}
 		}
 		outputAmount = outputAmount.Add(o.Amount)
@@ -189,32 +219,141 @@ func validateOutputs(store DataStore, tx *SignedTransaction) (Integer, error) {
 	return outputAmount, nil
 }
 
-func validateUTXO(index int, utxo *UTXO, sigs [][]crypto.Signature, msg []byte, txType uint8) error {
+// verified means all the signatures have been checked against the keys at
+// the same positions by batchVerifyInputs
+func validateUTXO(sv *signatureVerifier, index int, utxo *UTXO, sigs [][]crypto.Signature, txType uint8, preimage []byte, timestamp uint64, verified bool) error {
 	switch utxo.Type {
-	case OutputTypeScript:
-		var offset, valid int
-		for _, sig := range sigs[index] {
-			for i, k := range utxo.Keys {
-				if i < offset {
-					continue
-				}
-				if k.Verify(msg, sig) {
-					valid = valid + 1
-					offset = i + 1
-				}
+	case OutputTypeScript, OutputTypeNodeRemove:
+		if utxo.Script.IsHashLock() {
+			_, _, n := utxo.Script.HashLock()
+			if n >= len(utxo.Keys) {
+				return NewValidationError(ErrorCodeInvalidScript, "invalid hash lock keys count %d %d", n, len(utxo.Keys))
 			}
+			recipients := sv.countValidSignatures(utxo.Keys[:n], sigs[index])
+			refunds := sv.countValidSignatures(utxo.Keys[n:], sigs[index])
+			return utxo.Script.ValidateHashLock(preimage, recipients, refunds, timestamp)
+		}
+		valid := len(sigs[index])
+		if !verified {
+			valid = sv.countValidSignatures(utxo.Keys, sigs[index])
 		}
-		return utxo.Script.Validate(valid)
+		return utxo.Script.Validate(valid, timestamp)
 	case OutputTypeNodePledge:
 		if txType == TransactionTypeNodeAccept || txType == TransactionTypeNodeCancel {
 			return nil
 		}
-		return fmt.Errorf("pledge input used for invalid transaction type %d", txType)
+		return NewValidationError(ErrorCodeInvalidTransactionType, "pledge input used for invalid transaction type %d", txType)
 	case OutputTypeNodeAccept:
-		return fmt.Errorf("should do more validation on those %d UTXOs", utxo.Type)
+		if txType == TransactionTypeNodeDepart {
+			return nil
+		}
+		return NewValidationError(ErrorCodeInvalidTransactionType, "accept input used for invalid transaction type %d", txType)
+	case OutputTypeNodeDepart:
+		if txType == TransactionTypeNodeRemove {
+			return nil
+		}
+		return NewValidationError(ErrorCodeInvalidTransactionType, "depart input used for invalid transaction type %d", txType)
 	case OutputTypeNodeCancel:
-		return fmt.Errorf("should do more validation on those %d UTXOs", utxo.Type)
+		return NewValidationError(ErrorCodeInvalidInputType, "should do more validation on those %d UTXOs", utxo.Type)
 	default:
-		return fmt.Errorf("invalid input type %d", utxo.Type)
+		return NewValidationError(ErrorCodeInvalidInputType, "invalid input type %d", utxo.Type)
+	}
+}
+
+type signatureVerifier struct {
+	cache SignatureCache
+	msg   []byte
+	hash  crypto.Hash
+}
+
+type signatureEntry struct {
+	input int
+	key   crypto.Key
+	sig   crypto.Signature
+}
+
+// only the inputs signed by all the distinct keys in order are batched, they are
+// counted the same as countValidSignatures, others fall back to it, and the
+// batch results are always the same as Key.Verify, so it doesn't matter which
+// signatures are already in the cache and left out of the batch
+func (sv *signatureVerifier) batchVerifyInputs(utxos []*UTXO, sigs [][]crypto.Signature) []bool {
+	verified := make([]bool, len(utxos))
+	entries := make([]*signatureEntry, 0)
+	bv := crypto.NewBatchVerifier(len(utxos))
+	for i, utxo := range utxos {
+		if utxo.Type != OutputTypeScript || utxo.Script.IsHashLock() || i >= len(sigs) {
+			continue
+		}
+		if len(sigs[i]) == 0 || len(sigs[i]) != len(utxo.Keys) || !distinctKeys(utxo.Keys) {
+			continue
+		}
+		verified[i] = true
+		for j, sig := range sigs[i] {
+			if valid, found := sv.read(utxo.Keys[j], sig); found {
+				verified[i] = verified[i] && valid
+				continue
+			}
+			bv.Add(utxo.Keys[j], sv.msg, sig)
+			entries = append(entries, &signatureEntry{input: i, key: utxo.Keys[j], sig: sig})
+		}
+	}
+
+	_, results := bv.Verify()
+	for j, valid := range results {
+		e := entries[j]
+		sv.write(e.key, e.sig, valid)
+		if !valid {
+			verified[e.input] = false
+		}
+	}
+	return verified
+}
+
+func (sv *signatureVerifier) verify(key crypto.Key, sig crypto.Signature) bool {
+	if valid, found := sv.read(key, sig); found {
+		return valid
+	}
+	valid := key.Verify(sv.msg, sig)
+	sv.write(key, sig, valid)
+	return valid
+}
+
+func (sv *signatureVerifier) read(key crypto.Key, sig crypto.Signature) (bool, bool) {
+	if sv.cache == nil {
+		return false, false
+	}
+	return sv.cache.ReadSignatureResult(sv.hash, key, sig)
+}
+
+func (sv *signatureVerifier) write(key crypto.Key, sig crypto.Signature, valid bool) {
+	if sv.cache != nil {
+		sv.cache.WriteSignatureResult(sv.hash, key, sig, valid)
+	}
+}
+
+func distinctKeys(keys []crypto.Key) bool {
+	filter := make(map[crypto.Key]bool)
+	for _, k := range keys {
+		if filter[k] {
+			return false
+		}
+		filter[k] = true
+	}
+	return true
+}
+
+func (sv *signatureVerifier) countValidSignatures(keys []crypto.Key, sigs []crypto.Signature) int {
+	var offset, valid int
+	for _, sig := range sigs {
+		for i, k := range keys {
+			if i < offset {
+				continue
+			}
+			if sv.verify(k, sig) {
+				valid = valid + 1
+				offset = i + 1
+			}
+		}
 	}
+	return valid
 }
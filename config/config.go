@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"time"
 
@@ -15,27 +16,23 @@ const (
 	LoggerLevel  = logger.INFO
 
 	MainnetId                  = "6430225c42bb015b4da03102fa962e4f4ef3969e03e04345db229f8377ef7997"
-	SnapshotRoundGap           = uint64(3 * time.Second)
 	SnapshotReferenceThreshold = 10
 	SnapshotSyncRoundThreshold = 100
 	TransactionMaximumSize     = 1024 * 1024
 	WithdrawalClaimFee         = "0.0001"
 
-	KernelMintTimeBegin = 7
-	KernelMintTimeEnd   = 9
-
-	KernelNodeAcceptTimeBegin     = 13
-	KernelNodeAcceptTimeEnd       = 19
-	KernelNodePledgePeriodMinimum = 12 * time.Hour
-	KernelNodeAcceptPeriodMinimum = 12 * time.Hour
-	KernelNodeAcceptPeriodMaximum = 7 * 24 * time.Hour
+	CheckpointTopologyInterval = 1000000
+	PruneTopologyDepthMinimum  = CheckpointTopologyInterval * 2
 )
 
 type custom struct {
-	Signer       crypto.Key    `json:"signer"`
-	Listener     string        `json:"listener"`
-	MaxCacheSize int           `json:"max-cache-size"`
-	CacheTTL     time.Duration `json:"cache-ttl"`
+	Signer               crypto.Key    `json:"signer"`
+	SignerKeystore       string        `json:"signer-keystore"`
+	SignerPassphraseFile string        `json:"signer-passphrase-file"`
+	Listener             string        `json:"listener"`
+	MaxCacheSize         int           `json:"max-cache-size"`
+	CacheTTL             time.Duration `json:"cache-ttl"`
+	PruneTopologyDepth   uint64        `json:"prune-topology-depth"`
 }
 
 var Custom *custom
@@ -59,6 +56,9 @@ func Initialize(file string) error {
 	if config.MaxCacheSize == 0 {
 		config.MaxCacheSize = 1024 * 16
 	}
+	if d := config.PruneTopologyDepth; d > 0 && d < PruneTopologyDepthMinimum {
+		return fmt.Errorf("prune topology depth %d should be at least %d", d, PruneTopologyDepthMinimum)
+	}
 	Custom = &config
 	return nil
 }
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+const NetworkParamsVersion = 1
+
+// the network parameters are the mainnet ones by default, and replaced by the
+// params of genesis.json or the node state before the kernel starts
+var (
+	SnapshotRoundGap = uint64(3 * time.Second)
+
+	KernelMintPool        uint64 = 500000
+	KernelMintYearShares         = 10
+	KernelMintYearBatches        = 365
+	KernelMintTimeBegin          = 7
+	KernelMintTimeEnd            = 9
+
+	// the mint is split equally before this batch and weighted by the work
+	// of the nodes since, and 0 means the weighted split is never activated
+	KernelMintWorkActivationBatch uint64 = 2900
+
+	KernelNodePledgeAmount        uint64 = 10000
+	KernelNodeAcceptTimeBegin            = 13
+	KernelNodeAcceptTimeEnd              = 19
+	KernelNodePledgePeriodMinimum        = 12 * time.Hour
+	KernelNodeAcceptPeriodMinimum        = 12 * time.Hour
+	KernelNodeAcceptPeriodMaximum        = 7 * 24 * time.Hour
+)
+
+// NetworkParams are declared in genesis.json, all the periods are in seconds
+// and the time windows are the hours of the day since the network epoch
+type NetworkParams struct {
+	Version                 int    `json:"version"`
+	SnapshotRoundGap        uint64 `json:"snapshot-round-gap"`
+	MintPool                uint64 `json:"mint-pool"`
+	MintYearShares          int    `json:"mint-year-shares"`
+	MintYearBatches         int    `json:"mint-year-batches"`
+	MintTimeBegin           int    `json:"mint-time-begin"`
+	MintTimeEnd             int    `json:"mint-time-end"`
+	MintWorkActivationBatch uint64 `json:"mint-work-activation-batch"`
+	NodePledgeAmount        uint64 `json:"node-pledge-amount"`
+	NodeAcceptTimeBegin     int    `json:"node-accept-time-begin"`
+	NodeAcceptTimeEnd       int    `json:"node-accept-time-end"`
+	NodePledgePeriodMinimum uint64 `json:"node-pledge-period-minimum"`
+	NodeAcceptPeriodMinimum uint64 `json:"node-accept-period-minimum"`
+	NodeAcceptPeriodMaximum uint64 `json:"node-accept-period-maximum"`
+}
+
+func DefaultNetworkParams() *NetworkParams {
+	return &NetworkParams{
+		Version:                 NetworkParamsVersion,
+		SnapshotRoundGap:        3,
+		MintPool:                500000,
+		MintYearShares:          10,
+		MintYearBatches:         365,
+		MintTimeBegin:           7,
+		MintTimeEnd:             9,
+		MintWorkActivationBatch: 2900,
+		NodePledgeAmount:        10000,
+		NodeAcceptTimeBegin:     13,
+		NodeAcceptTimeEnd:       19,
+		NodePledgePeriodMinimum: 12 * 3600,
+		NodeAcceptPeriodMinimum: 12 * 3600,
+		NodeAcceptPeriodMaximum: 7 * 24 * 3600,
+	}
+}
+
+// CurrentNetworkParams returns the params in use by the kernel
+func CurrentNetworkParams() *NetworkParams {
+	return &NetworkParams{
+		Version:                 NetworkParamsVersion,
+		SnapshotRoundGap:        SnapshotRoundGap / uint64(time.Second),
+		MintPool:                KernelMintPool,
+		MintYearShares:          KernelMintYearShares,
+		MintYearBatches:         KernelMintYearBatches,
+		MintTimeBegin:           KernelMintTimeBegin,
+		MintTimeEnd:             KernelMintTimeEnd,
+		MintWorkActivationBatch: KernelMintWorkActivationBatch,
+		NodePledgeAmount:        KernelNodePledgeAmount,
+		NodeAcceptTimeBegin:     KernelNodeAcceptTimeBegin,
+		NodeAcceptTimeEnd:       KernelNodeAcceptTimeEnd,
+		NodePledgePeriodMinimum: uint64(KernelNodePledgePeriodMinimum / time.Second),
+		NodeAcceptPeriodMinimum: uint64(KernelNodeAcceptPeriodMinimum / time.Second),
+		NodeAcceptPeriodMaximum: uint64(KernelNodeAcceptPeriodMaximum / time.Second),
+	}
+}
+
+func (p *NetworkParams) Validate() error {
+	if p.Version != NetworkParamsVersion {
+		return fmt.Errorf("invalid network params version %d", p.Version)
+	}
+	// the reference threshold of rounds should be less than 3 minutes
+	if p.SnapshotRoundGap < 1 || p.SnapshotRoundGap*SnapshotReferenceThreshold >= 180 {
+		return fmt.Errorf("invalid snapshot round gap %d", p.SnapshotRoundGap)
+	}
+	if p.MintPool == 0 || p.MintYearShares <= 0 || p.MintYearBatches <= 0 {
+		return fmt.Errorf("invalid mint params %d %d %d", p.MintPool, p.MintYearShares, p.MintYearBatches)
+	}
+	if p.MintTimeBegin < 0 || p.MintTimeBegin > p.MintTimeEnd || p.MintTimeEnd > 23 {
+		return fmt.Errorf("invalid mint time %d %d", p.MintTimeBegin, p.MintTimeEnd)
+	}
+	if p.NodePledgeAmount == 0 {
+		return fmt.Errorf("invalid node pledge amount %d", p.NodePledgeAmount)
+	}
+	if p.NodeAcceptTimeBegin < 0 || p.NodeAcceptTimeBegin > p.NodeAcceptTimeEnd || p.NodeAcceptTimeEnd > 23 {
+		return fmt.Errorf("invalid node accept time %d %d", p.NodeAcceptTimeBegin, p.NodeAcceptTimeEnd)
+	}
+	if p.NodePledgePeriodMinimum == 0 {
+		return fmt.Errorf("invalid node pledge period %d", p.NodePledgePeriodMinimum)
+	}
+	if p.NodeAcceptPeriodMinimum < 3600 || p.NodeAcceptPeriodMaximum <= p.NodeAcceptPeriodMinimum {
+		return fmt.Errorf("invalid node accept period %d %d", p.NodeAcceptPeriodMinimum, p.NodeAcceptPeriodMaximum)
+	}
+	return nil
+}
+
+// ResolveNetworkParams returns the params declared by the genesis, or the
+// default ones if not declared, and they must match the stored ones if any
+func ResolveNetworkParams(genesis, stored *NetworkParams) (*NetworkParams, error) {
+	params := genesis
+	if params == nil {
+		params = DefaultNetworkParams()
+	}
+	if stored != nil && *stored != *params {
+		return nil, fmt.Errorf("invalid genesis params %v for the stored %v", *params, *stored)
+	}
+	return params, params.Validate()
+}
+
+// the network params and the address prefix are globals read by all the kernel
+// goroutines without any lock, so they are sealed before any goroutine starts
+var networkSealed int32
+
+// SealNetwork makes the network params and the address prefix final, and any
+// later change of them fails
+func SealNetwork() {
+	atomic.StoreInt32(&networkSealed, 1)
+}
+
+func NetworkSealed() bool {
+	return atomic.LoadInt32(&networkSealed) == 1
+}
+
+// ApplyNetworkParams replaces the network parameters in use, it should be
+// done before the kernel or any graph validation starts
+func ApplyNetworkParams(p *NetworkParams) error {
+	if NetworkSealed() {
+		return fmt.Errorf("network params sealed")
+	}
+	err := p.Validate()
+	if err != nil {
+		return err
+	}
+	SnapshotRoundGap = p.SnapshotRoundGap * uint64(time.Second)
+	KernelMintPool = p.MintPool
+	KernelMintYearShares = p.MintYearShares
+	KernelMintYearBatches = p.MintYearBatches
+	KernelMintTimeBegin = p.MintTimeBegin
+	KernelMintTimeEnd = p.MintTimeEnd
+	KernelMintWorkActivationBatch = p.MintWorkActivationBatch
+	KernelNodePledgeAmount = p.NodePledgeAmount
+	KernelNodeAcceptTimeBegin = p.NodeAcceptTimeBegin
+	KernelNodeAcceptTimeEnd = p.NodeAcceptTimeEnd
+	KernelNodePledgePeriodMinimum = time.Duration(p.NodePledgePeriodMinimum) * time.Second
+	KernelNodeAcceptPeriodMinimum = time.Duration(p.NodeAcceptPeriodMinimum) * time.Second
+	KernelNodeAcceptPeriodMaximum = time.Duration(p.NodeAcceptPeriodMaximum) * time.Second
+	return nil
+}
@@ -0,0 +1,104 @@
+package config
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNetworkParamsValidate(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(DefaultNetworkParams().Validate())
+
+	cases := []func(p *NetworkParams){
+		func(p *NetworkParams) { p.Version = 0 },
+		func(p *NetworkParams) { p.SnapshotRoundGap = 0 },
+		func(p *NetworkParams) { p.SnapshotRoundGap = 18 },
+		func(p *NetworkParams) { p.MintPool = 0 },
+		func(p *NetworkParams) { p.MintYearShares = 0 },
+		func(p *NetworkParams) { p.MintYearBatches = -1 },
+		func(p *NetworkParams) { p.MintTimeBegin = -1 },
+		func(p *NetworkParams) { p.MintTimeBegin, p.MintTimeEnd = 10, 9 },
+		func(p *NetworkParams) { p.MintTimeEnd = 24 },
+		func(p *NetworkParams) { p.NodePledgeAmount = 0 },
+		func(p *NetworkParams) { p.NodeAcceptTimeBegin = -1 },
+		func(p *NetworkParams) { p.NodeAcceptTimeBegin, p.NodeAcceptTimeEnd = 20, 19 },
+		func(p *NetworkParams) { p.NodeAcceptTimeEnd = 24 },
+		func(p *NetworkParams) { p.NodePledgePeriodMinimum = 0 },
+		func(p *NetworkParams) { p.NodeAcceptPeriodMinimum = 3599 },
+		func(p *NetworkParams) { p.NodeAcceptPeriodMaximum = p.NodeAcceptPeriodMinimum },
+	}
+	for i, update := range cases {
+		p := DefaultNetworkParams()
+		update(p)
+		assert.NotNil(p.Validate(), i)
+	}
+
+	p := DefaultNetworkParams()
+	p.SnapshotRoundGap = 17
+	p.MintTimeBegin, p.MintTimeEnd = 0, 0
+	p.NodeAcceptTimeBegin, p.NodeAcceptTimeEnd = 23, 23
+	p.NodeAcceptPeriodMinimum = 3600
+	p.MintWorkActivationBatch = 1000
+	assert.Nil(p.Validate())
+}
+
+func TestResolveNetworkParams(t *testing.T) {
+	assert := assert.New(t)
+
+	params, err := ResolveNetworkParams(nil, nil)
+	assert.Nil(err)
+	assert.Equal(*DefaultNetworkParams(), *params)
+	params, err = ResolveNetworkParams(nil, DefaultNetworkParams())
+	assert.Nil(err)
+	assert.Equal(*DefaultNetworkParams(), *params)
+
+	genesis := DefaultNetworkParams()
+	genesis.SnapshotRoundGap = 1
+	genesis.MintWorkActivationBatch = 100
+	params, err = ResolveNetworkParams(genesis, nil)
+	assert.Nil(err)
+	assert.Equal(*genesis, *params)
+	stored := *genesis
+	params, err = ResolveNetworkParams(genesis, &stored)
+	assert.Nil(err)
+	assert.Equal(stored, *params)
+
+	_, err = ResolveNetworkParams(genesis, DefaultNetworkParams())
+	assert.NotNil(err)
+	_, err = ResolveNetworkParams(nil, &stored)
+	assert.NotNil(err)
+	stored.MintWorkActivationBatch = 0
+	_, err = ResolveNetworkParams(genesis, &stored)
+	assert.NotNil(err)
+
+	genesis.NodePledgeAmount = 0
+	_, err = ResolveNetworkParams(genesis, nil)
+	assert.NotNil(err)
+}
+
+func TestApplyNetworkParams(t *testing.T) {
+	assert := assert.New(t)
+	defer ApplyNetworkParams(DefaultNetworkParams())
+
+	p := DefaultNetworkParams()
+	p.SnapshotRoundGap = 0
+	assert.NotNil(ApplyNetworkParams(p))
+	assert.Equal(*DefaultNetworkParams(), *CurrentNetworkParams())
+
+	p = DefaultNetworkParams()
+	p.SnapshotRoundGap = 1
+	p.NodeAcceptPeriodMinimum = 7200
+	p.MintWorkActivationBatch = 10
+	assert.Nil(ApplyNetworkParams(p))
+	assert.Equal(*p, *CurrentNetworkParams())
+
+	assert.False(NetworkSealed())
+	SealNetwork()
+	defer atomic.StoreInt32(&networkSealed, 0)
+	assert.True(NetworkSealed())
+	assert.NotNil(ApplyNetworkParams(DefaultNetworkParams()))
+	assert.Equal(*p, *CurrentNetworkParams())
+}
@@ -0,0 +1,188 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"math/big"
+
+	"github.com/MixinNetwork/mixin/crypto/edwards25519"
+)
+
+var (
+	groupOrder, _   = new(big.Int).SetString("7237005577332262213973186563042994240857116359379907606001950938285454250989", 10)
+	cofactorOrder   = new(big.Int).Lsh(groupOrder, 3)
+	fieldPrime      = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 255), big.NewInt(19))
+	identityEncoded = [32]byte{1}
+
+	groupOrderScalar = intToScalar(groupOrder)
+)
+
+type batchEntry struct {
+	publicKey Key
+	message   []byte
+	signature Signature
+}
+
+// BatchVerifier checks many signatures with a single multi scalar
+// multiplication, the batch passes only if every signature passes Key.Verify,
+// so the results never depend on which signatures are batched together
+type BatchVerifier struct {
+	entries []*batchEntry
+}
+
+func NewBatchVerifier(size int) *BatchVerifier {
+	return &BatchVerifier{entries: make([]*batchEntry, 0, size)}
+}
+
+func (bv *BatchVerifier) Add(publicKey Key, message []byte, sig Signature) {
+	bv.entries = append(bv.entries, &batchEntry{
+		publicKey: publicKey,
+		message:   message,
+		signature: sig,
+	})
+}
+
+func (bv *BatchVerifier) Len() int {
+	return len(bv.entries)
+}
+
+// Verify returns true if all signatures are valid, otherwise it falls back
+// to verify the signatures one by one to find out the bad ones
+func (bv *BatchVerifier) Verify() (bool, []bool) {
+	results := make([]bool, len(bv.entries))
+	if len(bv.entries) > 1 && bv.verifyBatch() {
+		for i := range results {
+			results[i] = true
+		}
+		return true, results
+	}
+
+	valid := true
+	for i, e := range bv.entries {
+		results[i] = e.publicKey.Verify(e.message, e.signature)
+		valid = valid && results[i]
+	}
+	return valid, results
+}
+
+// check (z0*s0 + z1*s1 + ...)B = z0*R0 + z0*h0*A0 + z1*R1 + z1*h1*A1 + ...
+// the small order components of different signatures could cancel out each
+// other in the sum, so any signature with R + h*A not in the prime order group
+// fails the batch, and it is left to Verify which may still accept it
+func (bv *BatchVerifier) verifyBatch() bool {
+	var sB [32]byte
+	scalars := make([]*[32]byte, 0, len(bv.entries)*2)
+	points := make([]*edwards25519.ExtendedGroupElement, 0, len(bv.entries)*2)
+
+	for _, e := range bv.entries {
+		var s, encodedR, encodedA [32]byte
+		copy(s[:], e.signature[32:])
+		copy(encodedR[:], e.signature[:32])
+		copy(encodedA[:], e.publicKey[:])
+		if !edwards25519.ScMinimal(&s) || !isCanonicalPoint(&encodedR) {
+			return false
+		}
+
+		var A, R edwards25519.ExtendedGroupElement
+		if !A.FromBytes(&encodedA) || !R.FromBytes(&encodedR) {
+			return false
+		}
+
+		var digest [64]byte
+		var hReduced [32]byte
+		h := sha512.New()
+		h.Write(e.signature[:32])
+		h.Write(e.publicKey[:])
+		h.Write(e.message)
+		h.Sum(digest[:0])
+		edwards25519.ScReduce(&hReduced, &digest)
+		if !isTorsionFree(&R, &A, &hReduced) {
+			return false
+		}
+
+		edwards25519.FeNeg(&A.X, &A.X)
+		edwards25519.FeNeg(&A.T, &A.T)
+		edwards25519.FeNeg(&R.X, &R.X)
+		edwards25519.FeNeg(&R.T, &R.T)
+
+		var z [32]byte
+		_, err := rand.Read(z[:16])
+		if err != nil {
+			panic(err)
+		}
+		z[0] = z[0]&0xf8 | 1
+
+		zh := new(big.Int).Mul(scalarToInt(&z), scalarToInt(&hReduced))
+		zh.Mod(zh, cofactorOrder)
+		if zh.BitLen() > 255 {
+			zh.Sub(cofactorOrder, zh)
+			edwards25519.FeNeg(&A.X, &A.X)
+			edwards25519.FeNeg(&A.T, &A.T)
+		}
+
+		edwards25519.ScMulAdd(&sB, &z, &s, &sB)
+		scalars = append(scalars, &z, intToScalar(zh))
+		points = append(points, &R, &A)
+	}
+
+	var check edwards25519.ProjectiveGroupElement
+	var encoded [32]byte
+	edwards25519.GeMultiScalarMultVartime(&check, &sB, scalars, points)
+	check.ToBytes(&encoded)
+	return encoded == identityEncoded
+}
+
+// L*(R + h*A) is the identity only if the small order components of R and
+// h*A cancel out, which is required by Verify to get the exact R back, and
+// the order of the small components divides 8, so h is reduced by 8 here
+func isTorsionFree(R, A *edwards25519.ExtendedGroupElement, hReduced *[32]byte) bool {
+	var zero, encoded [32]byte
+	var sum edwards25519.CompletedGroupElement
+	var cached edwards25519.CachedGroupElement
+	var check edwards25519.ProjectiveGroupElement
+	Q := *R
+	A.ToCached(&cached)
+	for i := byte(0); i < hReduced[0]&7; i++ {
+		edwards25519.GeAdd(&sum, &Q, &cached)
+		sum.ToExtended(&Q)
+	}
+	edwards25519.GeDoubleScalarMultVartime(&check, groupOrderScalar, &Q, &zero)
+	check.ToBytes(&encoded)
+	return encoded == identityEncoded
+}
+
+// Verify compares the encoded R, so the non canonical encodings never pass
+func isCanonicalPoint(s *[32]byte) bool {
+	y := new(big.Int).SetBytes(reverseBytes(s[:]))
+	sign := y.Bit(255)
+	y.SetBit(y, 255, 0)
+	if y.Cmp(fieldPrime) >= 0 {
+		return false
+	}
+	if sign == 0 {
+		return true
+	}
+	// x is zero when y is 1 or -1, and the sign bit must not be set
+	return y.Cmp(big.NewInt(1)) != 0 && y.Cmp(new(big.Int).Sub(fieldPrime, big.NewInt(1))) != 0
+}
+
+func scalarToInt(s *[32]byte) *big.Int {
+	return new(big.Int).SetBytes(reverseBytes(s[:]))
+}
+
+func intToScalar(i *big.Int) *[32]byte {
+	var s [32]byte
+	b := i.Bytes()
+	for j := range b {
+		s[j] = b[len(b)-1-j]
+	}
+	return &s
+}
+
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[i] = b[len(b)-1-i]
+	}
+	return r
+}
@@ -0,0 +1,197 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha512"
+	"fmt"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto/edwards25519"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBatchVerifier(t *testing.T) {
+	assert := assert.New(t)
+
+	bv := NewBatchVerifier(0)
+	valid, results := bv.Verify()
+	assert.True(valid)
+	assert.Len(results, 0)
+
+	keys, messages, sigs := batchTestSignatures(32)
+	bv = NewBatchVerifier(len(keys))
+	for i := range keys {
+		bv.Add(keys[i], messages[i], sigs[i])
+	}
+	assert.Equal(32, bv.Len())
+	assert.True(bv.verifyBatch())
+	valid, results = bv.Verify()
+	assert.True(valid)
+	for _, r := range results {
+		assert.True(r)
+	}
+
+	bv = NewBatchVerifier(len(keys))
+	for i := range keys {
+		if i == 7 {
+			bv.Add(keys[i], messages[i+1], sigs[i])
+		} else if i == 19 {
+			sig := sigs[i]
+			sig[40] ^= 1
+			bv.Add(keys[i], messages[i], sig)
+		} else {
+			bv.Add(keys[i], messages[i], sigs[i])
+		}
+	}
+	assert.False(bv.verifyBatch())
+	valid, results = bv.Verify()
+	assert.False(valid)
+	for i, r := range results {
+		assert.Equal(i != 7 && i != 19, r)
+	}
+
+	var T edwards25519.ExtendedGroupElement
+	assert.True(T.FromBytes(&torsionPointEncoded))
+	for i := 0; i < 16; i++ {
+		seed := NewHash([]byte(fmt.Sprintf("torsion%d", i)))
+		priv := NewKeyFromSeed(append(seed[:], seed[:]...))
+		message := seed[:]
+
+		var a, A [32]byte
+		var P edwards25519.ExtendedGroupElement
+		var c edwards25519.CachedGroupElement
+		var sum edwards25519.CompletedGroupElement
+		copy(a[:], priv[:])
+		edwards25519.GeScalarMultBase(&P, &a)
+		T.ToCached(&c)
+		edwards25519.GeAdd(&sum, &P, &c)
+		sum.ToExtended(&P)
+		P.ToBytes(&A)
+		pub := Key(A)
+
+		r := CosiCommit(rand.Reader)
+		R := r.Public()
+		var sig Signature
+		copy(sig[:], R[:])
+		s := batchTestResponse(R, pub, message, priv, *r)
+		copy(sig[32:], s[:])
+
+		bv = NewBatchVerifier(2)
+		bv.Add(keys[0], messages[0], sigs[0])
+		bv.Add(pub, message, sig)
+		assert.Equal(pub.Verify(message, sig), bv.verifyBatch())
+		valid, results = bv.Verify()
+		assert.Equal(pub.Verify(message, sig), valid)
+		assert.True(results[0])
+	}
+
+	// the small order components of the two R cancel out each other in the
+	// sum of the batch, but each of them fails Verify
+	bv = NewBatchVerifier(3)
+	bv.Add(keys[0], messages[0], sigs[0])
+	for i := 1; i < 3; i++ {
+		priv := NewKeyFromSeed(append(messages[i], messages[i]...))
+		r := CosiCommit(rand.Reader)
+		var rs, R [32]byte
+		var P edwards25519.ExtendedGroupElement
+		var c edwards25519.CachedGroupElement
+		var sum edwards25519.CompletedGroupElement
+		copy(rs[:], r[:])
+		edwards25519.GeScalarMultBase(&P, &rs)
+		T.ToCached(&c)
+		edwards25519.GeAdd(&sum, &P, &c)
+		sum.ToExtended(&P)
+		P.ToBytes(&R)
+
+		var sig Signature
+		copy(sig[:], R[:])
+		s := batchTestResponse(Key(R), keys[i], messages[i], priv, *r)
+		copy(sig[32:], s[:])
+		assert.False(keys[i].Verify(messages[i], sig))
+		bv.Add(keys[i], messages[i], sig)
+	}
+	assert.False(bv.verifyBatch())
+	valid, results = bv.Verify()
+	assert.False(valid)
+	assert.Equal([]bool{true, false, false}, results)
+
+	var encoded [32]byte
+	assert.True(isCanonicalPoint(&encoded))
+	encoded = [32]byte{1}
+	assert.True(isCanonicalPoint(&encoded))
+	encoded[31] = 0x80
+	assert.False(isCanonicalPoint(&encoded))
+	assert.True(isCanonicalPoint(&torsionPointEncoded))
+	for i := range encoded {
+		encoded[i] = 0xff
+	}
+	encoded[31] = 0x7f
+	assert.False(isCanonicalPoint(&encoded))
+	encoded[0] = 0xec
+	assert.True(isCanonicalPoint(&encoded))
+	encoded[31] = 0xff
+	assert.False(isCanonicalPoint(&encoded))
+}
+
+func BenchmarkVerify(b *testing.B) {
+	keys, messages, sigs := batchTestSignatures(64)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range keys {
+			if !keys[i].Verify(messages[i], sigs[i]) {
+				b.Fatal(i)
+			}
+		}
+	}
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	keys, messages, sigs := batchTestSignatures(64)
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		bv := NewBatchVerifier(len(keys))
+		for i := range keys {
+			bv.Add(keys[i], messages[i], sigs[i])
+		}
+		if valid, _ := bv.Verify(); !valid {
+			b.Fatal(n)
+		}
+	}
+}
+
+// the order 2 point (0, -1)
+var torsionPointEncoded = [32]byte{
+	0xec, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+	0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f,
+}
+
+func batchTestResponse(R, pub Key, message []byte, priv Key, r Key) [32]byte {
+	var digest [64]byte
+	var hReduced, out [32]byte
+	h := sha512.New()
+	h.Write(R[:])
+	h.Write(pub[:])
+	h.Write(message)
+	h.Sum(digest[:0])
+	edwards25519.ScReduce(&hReduced, &digest)
+	var a, rs [32]byte
+	copy(a[:], priv[:])
+	copy(rs[:], r[:])
+	edwards25519.ScMulAdd(&out, &hReduced, &a, &rs)
+	return out
+}
+
+func batchTestSignatures(n int) ([]Key, [][]byte, []Signature) {
+	keys := make([]Key, n)
+	messages := make([][]byte, n+1)
+	sigs := make([]Signature, n)
+	for i := 0; i < n; i++ {
+		seed := NewHash([]byte(fmt.Sprintf("batch%d", i)))
+		priv := NewKeyFromSeed(append(seed[:], seed[:]...))
+		keys[i] = priv.Public()
+		messages[i] = seed[:]
+		sigs[i] = priv.Sign(messages[i])
+	}
+	messages[n] = []byte("batch")
+	return keys, messages, sigs
+}
@@ -2,20 +2,37 @@ package crypto
 
 import (
 	"crypto/sha512"
+	"encoding/binary"
 	"encoding/hex"
 	"fmt"
 	"io"
 	"strconv"
 
 	"github.com/MixinNetwork/mixin/crypto/edwards25519"
+	"github.com/MixinNetwork/msgpack"
+)
+
+const (
+	CosiMaskVersionLegacy = 0 // the uint64 mask for signers 0 to 63
+	CosiMaskVersionBitmap = 1 // the variable length bitmap for any signers
+
+	cosiLegacyMaskBits = 64
 )
 
 type CosiSignature struct {
-	Signature   Signature
-	Mask        uint64
+	Signature Signature
+	// the signers bitmap, signer i is marked by the bit i%8 of byte i/8
+	Bitmap      []byte
 	commitments map[int]*Key
 }
 
+type cosiSignatureEncoding struct {
+	Signature Signature
+	Mask      uint64
+	Version   uint8  `msgpack:",omitempty"`
+	Bitmap    []byte `msgpack:",omitempty"`
+}
+
 func CosiCommit(randReader io.Reader) *Key {
 	var messageDigest [64]byte
 	n, err := randReader.Read(messageDigest[:])
@@ -152,24 +169,49 @@ func (c *CosiSignature) VerifyResponse(publics []*Key, signer int, s *[32]byte,
 }
 
 func (c *CosiSignature) Mark(i int) error {
-	if i >= 64 || i < 0 {
+	if i < 0 {
 		return fmt.Errorf("invalid cosi signature mask index %d", i)
 	}
-	c.Mask ^= (1 << uint64(i))
+	for len(c.Bitmap) <= i/8 {
+		c.Bitmap = append(c.Bitmap, 0)
+	}
+	c.Bitmap[i/8] ^= 1 << uint(i%8)
+	c.Bitmap = trimCosiBitmap(c.Bitmap)
 	return nil
 }
 
 func (c *CosiSignature) Keys() []int {
 	keys := make([]int, 0)
-	for i := uint64(0); i < 64; i++ {
-		mask := uint64(1) << i
-		if c.Mask&mask == mask {
-			keys = append(keys, int(i))
+	for i, b := range c.Bitmap {
+		for j := 0; j < 8; j++ {
+			if b&(1<<uint(j)) != 0 {
+				keys = append(keys, i*8+j)
+			}
 		}
 	}
 	return keys
 }
 
+func (c *CosiSignature) MaskVersion() int {
+	if len(c.Bitmap)*8 > cosiLegacyMaskBits {
+		return CosiMaskVersionBitmap
+	}
+	return CosiMaskVersionLegacy
+}
+
+// the legacy mask holds the signers 0 to 63 only
+func (c *CosiSignature) LegacyMask() uint64 {
+	var buf [8]byte
+	copy(buf[:], c.Bitmap)
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+func (c *CosiSignature) SetLegacyMask(mask uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], mask)
+	c.Bitmap = trimCosiBitmap(buf[:])
+}
+
 func (c *CosiSignature) AggregatePublicKey(publics []*Key) (*Key, error) {
 	var key *Key
 	for _, i := range c.Keys() {
@@ -202,7 +244,10 @@ func (c *CosiSignature) FullVerify(publics []*Key, threshold int, message []byte
 }
 
 func (c CosiSignature) String() string {
-	return c.Signature.String() + fmt.Sprintf("%016x", c.Mask)
+	if c.MaskVersion() == CosiMaskVersionLegacy {
+		return c.Signature.String() + fmt.Sprintf("%016x", c.LegacyMask())
+	}
+	return c.Signature.String() + fmt.Sprintf("%02x", CosiMaskVersionBitmap) + hex.EncodeToString(c.Bitmap)
 }
 
 func (c CosiSignature) MarshalJSON() ([]byte, error) {
@@ -218,13 +263,74 @@ func (c *CosiSignature) UnmarshalJSON(b []byte) error {
 	if err != nil {
 		return err
 	}
-	if len(data) != len(c.Signature)+8 {
+	if len(data) == len(c.Signature)+8 {
+		copy(c.Signature[:], data)
+		mask, err := strconv.ParseUint(unquoted[len(c.Signature)*2:], 16, 64)
+		if err != nil {
+			return fmt.Errorf("invalid mask data %x", unquoted[len(c.Signature)*2:])
+		}
+		c.SetLegacyMask(mask)
+		return nil
+	}
+	if len(data) <= len(c.Signature)+1 || data[len(c.Signature)] != CosiMaskVersionBitmap {
 		return fmt.Errorf("invalid signature length %d", len(data))
 	}
 	copy(c.Signature[:], data)
-	c.Mask, err = strconv.ParseUint(unquoted[len(c.Signature)*2:], 16, 64)
+	return c.SetBitmap(data[len(c.Signature)+1:])
+}
+
+func (c *CosiSignature) EncodeMsgpack(enc *msgpack.Encoder) error {
+	e := cosiSignatureEncoding{
+		Signature: c.Signature,
+		Mask:      c.LegacyMask(),
+	}
+	if c.MaskVersion() == CosiMaskVersionBitmap {
+		e.Version = CosiMaskVersionBitmap
+		e.Bitmap = c.Bitmap
+	}
+	return enc.Encode(e)
+}
+
+func (c *CosiSignature) DecodeMsgpack(dec *msgpack.Decoder) error {
+	var e cosiSignatureEncoding
+	err := dec.Decode(&e)
 	if err != nil {
-		return fmt.Errorf("invalid mask data %x", unquoted[len(c.Signature)*2:])
+		return err
+	}
+	c.Signature = e.Signature
+	switch e.Version {
+	case CosiMaskVersionLegacy:
+		c.SetLegacyMask(e.Mask)
+		return nil
+	case CosiMaskVersionBitmap:
+		err = c.SetBitmap(e.Bitmap)
+		if err != nil {
+			return err
+		}
+		if c.LegacyMask() != e.Mask {
+			return fmt.Errorf("invalid cosi signature mask %x %x", e.Mask, c.Bitmap)
+		}
+		return nil
+	default:
+		return fmt.Errorf("invalid cosi signature mask version %d", e.Version)
+	}
+}
+
+// only the canonical bitmap beyond the legacy mask is accepted
+func (c *CosiSignature) SetBitmap(bitmap []byte) error {
+	if len(bitmap)*8 <= cosiLegacyMaskBits || bitmap[len(bitmap)-1] == 0 {
+		return fmt.Errorf("invalid cosi signature bitmap %x", bitmap)
 	}
+	c.Bitmap = append([]byte{}, bitmap...)
 	return nil
 }
+
+func trimCosiBitmap(bitmap []byte) []byte {
+	for len(bitmap) > 0 && bitmap[len(bitmap)-1] == 0 {
+		bitmap = bitmap[:len(bitmap)-1]
+	}
+	if len(bitmap) == 0 {
+		return nil
+	}
+	return bitmap
+}
@@ -1,10 +1,12 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/rand"
 	"fmt"
 	"testing"
 
+	"github.com/MixinNetwork/msgpack"
 	"github.com/stretchr/testify/assert"
 	"go.dedis.ch/kyber/v3/xof/blake2xb"
 )
@@ -92,3 +94,95 @@ func TestCosi(t *testing.T) {
 	valid = cosi.FullVerify(publics, len(randoms), message)
 	assert.True(valid)
 }
+
+func TestCosiBitmap(t *testing.T) {
+	assert := assert.New(t)
+
+	keys := make([]*Key, 100)
+	publics := make([]*Key, len(keys))
+	for i := 0; i < len(keys); i++ {
+		seed := NewHash([]byte(fmt.Sprintf("%d", i)))
+		priv := NewKeyFromSeed(append(seed[:], seed[:]...))
+		pub := priv.Public()
+		keys[i] = &priv
+		publics[i] = &pub
+	}
+
+	randReader := blake2xb.New(nil)
+	message := []byte("Schnorr Signature in Mixin Kernel")
+	randoms := make(map[int]*Key)
+	randKeys := make(map[int]*Key)
+	masks := make([]int, 0)
+	for i := 30; i < len(keys); i++ {
+		r := CosiCommit(randReader)
+		R := r.Public()
+		randKeys[i] = r
+		randoms[i] = &R
+		masks = append(masks, i)
+	}
+
+	cosi, err := CosiAggregateCommitment(randoms)
+	assert.Nil(err)
+	assert.Equal(masks, cosi.Keys())
+	assert.Equal(CosiMaskVersionBitmap, cosi.MaskVersion())
+	assert.Len(cosi.Bitmap, 13)
+
+	responses := make(map[int]*[32]byte)
+	for _, i := range masks {
+		s, err := cosi.Response(keys[i], randKeys[i], publics, message)
+		assert.Nil(err)
+		responses[i] = &s
+		err = cosi.VerifyResponse(publics, i, &s, message)
+		assert.Nil(err)
+	}
+	err = cosi.AggregateResponse(publics, responses, message, true)
+	assert.Nil(err)
+	assert.True(cosi.FullVerify(publics, len(masks), message))
+	assert.False(cosi.FullVerify(publics, len(masks)+1, message))
+
+	str := cosi.String()
+	assert.Len(str, 128+2+26)
+	assert.Equal("01", str[128:130])
+	var sig CosiSignature
+	err = sig.UnmarshalJSON([]byte(`"` + str + `"`))
+	assert.Nil(err)
+	assert.Equal(masks, sig.Keys())
+	assert.Equal(cosi.Signature, sig.Signature)
+	err = sig.UnmarshalJSON([]byte(`"` + str + "00" + `"`))
+	assert.NotNil(err)
+
+	var buf bytes.Buffer
+	err = msgpack.NewEncoder(&buf).UseCompactEncoding(true).Encode(cosi)
+	assert.Nil(err)
+	var decoded CosiSignature
+	err = msgpack.Unmarshal(buf.Bytes(), &decoded)
+	assert.Nil(err)
+	assert.Equal(masks, decoded.Keys())
+	assert.True(decoded.FullVerify(publics, len(masks), message))
+
+	legacy := &CosiSignature{Signature: cosi.Signature}
+	for i := 0; i < 64; i += 3 {
+		assert.Nil(legacy.Mark(i))
+	}
+	assert.Nil(legacy.Mark(63))
+	assert.Nil(legacy.Mark(63))
+	assert.Equal(CosiMaskVersionLegacy, legacy.MaskVersion())
+	assert.Equal(uint64(0x9249249249249249), legacy.LegacyMask())
+	assert.Equal(legacy.Signature.String()+"9249249249249249", legacy.String())
+
+	buf.Reset()
+	err = msgpack.NewEncoder(&buf).UseCompactEncoding(true).Encode(legacy)
+	assert.Nil(err)
+	var old bytes.Buffer
+	err = msgpack.NewEncoder(&old).UseCompactEncoding(true).Encode(struct {
+		Signature Signature
+		Mask      uint64
+	}{legacy.Signature, legacy.LegacyMask()})
+	assert.Nil(err)
+	assert.Equal(old.Bytes(), buf.Bytes())
+	decoded = CosiSignature{}
+	err = msgpack.Unmarshal(buf.Bytes(), &decoded)
+	assert.Nil(err)
+	assert.Equal(legacy.Keys(), decoded.Keys())
+	assert.NotNil(legacy.Mark(-1))
+}
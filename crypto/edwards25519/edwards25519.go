@@ -1140,6 +1140,74 @@ func GeDoubleScalarMultVartime(r *ProjectiveGroupElement, a *[32]byte, A *Extend
 	}
 }
 
+// GeMultiScalarMultVartime sets r = b*B + a[0]*A[0] + ... + a[n-1]*A[n-1]
+// where all scalars are little endian and less than 2^255.
+// B is the Ed25519 base point (x,4/5) with x positive.
+func GeMultiScalarMultVartime(r *ProjectiveGroupElement, b *[32]byte, a []*[32]byte, A []*ExtendedGroupElement) {
+	var bSlide [256]int8
+	aSlides := make([][256]int8, len(a))
+	Ai := make([][8]CachedGroupElement, len(A)) // A,3A,5A,7A,9A,11A,13A,15A
+	var t CompletedGroupElement
+	var u, A2 ExtendedGroupElement
+	var i int
+
+	slide(&bSlide, b)
+	for j := range a {
+		slide(&aSlides[j], a[j])
+
+		A[j].ToCached(&Ai[j][0])
+		A[j].Double(&t)
+		t.ToExtended(&A2)
+		for k := 0; k < 7; k++ {
+			geAdd(&t, &A2, &Ai[j][k])
+			t.ToExtended(&u)
+			u.ToCached(&Ai[j][k+1])
+		}
+	}
+
+	r.Zero()
+
+	for i = 255; i >= 0; i-- {
+		if bSlide[i] != 0 {
+			break
+		}
+		nonzero := false
+		for j := range aSlides {
+			if aSlides[j][i] != 0 {
+				nonzero = true
+				break
+			}
+		}
+		if nonzero {
+			break
+		}
+	}
+
+	for ; i >= 0; i-- {
+		r.Double(&t)
+
+		for j := range aSlides {
+			if aSlides[j][i] > 0 {
+				t.ToExtended(&u)
+				geAdd(&t, &u, &Ai[j][aSlides[j][i]/2])
+			} else if aSlides[j][i] < 0 {
+				t.ToExtended(&u)
+				geSub(&t, &u, &Ai[j][(-aSlides[j][i])/2])
+			}
+		}
+
+		if bSlide[i] > 0 {
+			t.ToExtended(&u)
+			geMixedAdd(&t, &u, &bi[bSlide[i]/2])
+		} else if bSlide[i] < 0 {
+			t.ToExtended(&u)
+			geMixedSub(&t, &u, &bi[(-bSlide[i])/2])
+		}
+
+		t.ToProjective(r)
+	}
+}
+
 // equal returns 1 if b == c and 0 otherwise, assuming that b and c are
 // non-negative.
 func equal(b, c int32) int32 {
@@ -0,0 +1,297 @@
+package kernel
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	graphArchiveBatchSize = 1000
+	graphArchiveLogSize   = 100000
+	stateKeyImportGraph   = "importgraph"
+
+	// an entry has at most one transaction with its snapshot or round
+	graphArchiveEntryMaximumSize = 2 * config.TransactionMaximumSize
+)
+
+type graphArchiveEntry struct {
+	Network     *crypto.Hash                         `msgpack:",omitempty"`
+	Timestamp   uint64                               `msgpack:",omitempty"`
+	Round       *common.Round                        `msgpack:",omitempty"`
+	Snapshot    *common.SnapshotWithTopologicalOrder `msgpack:",omitempty"`
+	Transaction []byte                               `msgpack:",omitempty"`
+	Total       *uint64                              `msgpack:",omitempty"`
+}
+
+type graphArchiveProgress struct {
+	Archive  crypto.Hash
+	Offset   int64
+	Checksum crypto.Hash
+	Total    uint64
+}
+
+// each entry in the archive is the length, the compressed entry and the checksum
+// of all the entries until it, so the reader verifies every entry before use
+type graphArchiveWriter struct {
+	w        io.Writer
+	checksum crypto.Hash
+}
+
+type graphArchiveReader struct {
+	r        *bufio.Reader
+	offset   int64
+	checksum crypto.Hash
+}
+
+// ExportGraph writes all the snapshots with their transactions in topology order,
+// and each final round after the snapshot which references it
+func ExportGraph(store storage.Store, networkId crypto.Hash, w io.Writer) (uint64, error) {
+	bw := bufio.NewWriter(w)
+	aw := &graphArchiveWriter{w: bw}
+	err := aw.write(&graphArchiveEntry{Network: &networkId, Timestamp: uint64(time.Now().UnixNano())})
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	rounds := make(map[crypto.Hash]uint64)
+	var offset, total uint64
+	for {
+		snapshots, transactions, err := store.ReadSnapshotWithTransactionsSinceTopology(offset, graphArchiveBatchSize)
+		if err != nil {
+			return total, err
+		}
+		for i, s := range snapshots {
+			tx := transactions[i]
+			if tx == nil {
+				return total, fmt.Errorf("graph transaction %s not found", s.Transaction)
+			}
+			err := aw.write(&graphArchiveEntry{Snapshot: s, Transaction: tx.Marshal()})
+			if err != nil {
+				return total, err
+			}
+			last, found := rounds[s.NodeId]
+			if found && s.RoundNumber > last {
+				round, err := store.ReadRound(s.References.Self)
+				if err != nil {
+					return total, err
+				}
+				if round == nil {
+					return total, fmt.Errorf("graph round %s not found", s.References.Self)
+				}
+				round.Hash = s.References.Self
+				err = aw.write(&graphArchiveEntry{Round: round})
+				if err != nil {
+					return total, err
+				}
+			}
+			rounds[s.NodeId] = s.RoundNumber
+			offset = s.TopologicalOrder + 1
+			total++
+			if total%graphArchiveLogSize == 0 {
+				logger.Printf("EXPORT GRAPH %d SNAPSHOTS IN %s\n", total, time.Now().Sub(start).String())
+			}
+		}
+		if len(snapshots) < graphArchiveBatchSize {
+			break
+		}
+	}
+
+	err = aw.write(&graphArchiveEntry{Total: &total})
+	if err != nil {
+		return total, err
+	}
+	logger.Printf("EXPORT GRAPH %d SNAPSHOTS IN %s DONE\n", total, time.Now().Sub(start).String())
+	return total, bw.Flush()
+}
+
+// ImportGraph replays a graph archive through the snapshot finalization of the
+// node, and resumes from the last saved progress if the same archive is imported
+func (node *Node) ImportGraph(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	ar := &graphArchiveReader{r: bufio.NewReader(f)}
+	e, err := ar.read()
+	if err != nil {
+		return 0, err
+	}
+	if e.Network == nil || *e.Network != node.networkId {
+		return 0, fmt.Errorf("invalid graph archive network %v %s", e.Network, node.networkId)
+	}
+
+	var progress graphArchiveProgress
+	found, err := node.persistStore.StateGet(stateKeyImportGraph, &progress)
+	if err != nil {
+		return 0, err
+	}
+	if found && progress.Archive == ar.checksum {
+		_, err := f.Seek(progress.Offset, io.SeekStart)
+		if err != nil {
+			return 0, err
+		}
+		ar = &graphArchiveReader{r: bufio.NewReader(f), offset: progress.Offset, checksum: progress.Checksum}
+		logger.Printf("IMPORT GRAPH RESUME FROM %d SNAPSHOTS\n", progress.Total)
+	} else {
+		progress = graphArchiveProgress{Archive: ar.checksum, Offset: ar.offset, Checksum: ar.checksum}
+	}
+
+	start := time.Now()
+	for {
+		offset, checksum := ar.offset, ar.checksum
+		e, err := ar.read()
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return progress.Total, fmt.Errorf("graph archive truncated at %d", ar.offset)
+		} else if err != nil {
+			return progress.Total, err
+		}
+
+		switch {
+		case e.Total != nil:
+			if *e.Total != progress.Total {
+				return progress.Total, fmt.Errorf("invalid graph archive total %d %d", *e.Total, progress.Total)
+			}
+			progress.Offset, progress.Checksum = offset, checksum
+			logger.Printf("IMPORT GRAPH %d SNAPSHOTS IN %s DONE\n", progress.Total, time.Now().Sub(start).String())
+			return progress.Total, node.persistStore.StateSet(stateKeyImportGraph, progress)
+		case e.Round != nil:
+			round, err := node.persistStore.ReadRound(e.Round.Hash)
+			if err != nil {
+				return progress.Total, err
+			}
+			if round == nil || round.NodeId != e.Round.NodeId || round.Number != e.Round.Number {
+				return progress.Total, fmt.Errorf("graph round %s %d %s not final", e.Round.NodeId, e.Round.Number, e.Round.Hash)
+			}
+		case e.Snapshot != nil:
+			tx, err := common.UnmarshalVersionedTransaction(e.Transaction)
+			if err != nil {
+				return progress.Total, err
+			}
+			err = node.replayGraphSnapshot(&e.Snapshot.Snapshot, tx)
+			if err != nil {
+				return progress.Total, err
+			}
+			progress.Total++
+			if progress.Total%graphArchiveBatchSize == 0 {
+				progress.Offset, progress.Checksum = ar.offset, ar.checksum
+				err := node.persistStore.StateSet(stateKeyImportGraph, progress)
+				if err != nil {
+					return progress.Total, err
+				}
+			}
+			if progress.Total%graphArchiveLogSize == 0 {
+				logger.Printf("IMPORT GRAPH %d SNAPSHOTS IN %s\n", progress.Total, time.Now().Sub(start).String())
+			}
+		default:
+			return progress.Total, fmt.Errorf("invalid graph archive entry at %d", offset)
+		}
+	}
+}
+
+func (node *Node) replayGraphSnapshot(s *common.Snapshot, tx *common.VersionedTransaction) error {
+	s.Hash = s.PayloadHash()
+	if hash := tx.PayloadHash(); hash != s.Transaction {
+		return fmt.Errorf("invalid graph transaction %s %s", s.Transaction, hash)
+	}
+	inNode, err := node.persistStore.CheckTransactionInNode(s.NodeId, s.Transaction)
+	if err != nil || inNode {
+		return err
+	}
+
+	if s.Version == 0 {
+		s.Signatures = node.legacyValidSignatures(s)
+	}
+	if !node.verifyFinalization(s) {
+		return fmt.Errorf("invalid graph snapshot finalization %s", s.Hash)
+	}
+	err = node.persistStore.CachePutTransaction(tx)
+	if err != nil {
+		return err
+	}
+	err = node.tryToStartNewRound(s)
+	if err != nil {
+		return err
+	}
+	tx, err = node.checkFinalSnapshotTransaction(s)
+	if err != nil {
+		return err
+	}
+	if tx == nil {
+		return fmt.Errorf("graph snapshot transaction %s not found", s.Transaction)
+	}
+	if s.RoundNumber == 0 && tx.TransactionType() != common.TransactionTypeNodeAccept {
+		return fmt.Errorf("invalid initial transaction type %d", tx.TransactionType())
+	}
+
+	err = node.cosiHandleFinalization(&CosiAction{
+		PeerId:      node.IdForNetwork,
+		Action:      CosiActionFinalization,
+		Snapshot:    s,
+		Transaction: tx,
+	})
+	if err != nil {
+		return err
+	}
+	inNode, err = node.persistStore.CheckTransactionInNode(s.NodeId, s.Transaction)
+	if err != nil {
+		return err
+	}
+	if !inNode {
+		return fmt.Errorf("graph snapshot %s not finalized %s %d", s.Hash, s.NodeId, s.RoundNumber)
+	}
+	return nil
+}
+
+func (aw *graphArchiveWriter) write(e *graphArchiveEntry) error {
+	data := common.CompressMsgpackMarshalPanic(e)
+	aw.checksum = crypto.NewHash(append(aw.checksum[:], data...))
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, uint32(len(data)))
+	buf = append(buf, data...)
+	_, err := aw.w.Write(append(buf, aw.checksum[:]...))
+	return err
+}
+
+func (ar *graphArchiveReader) read() (*graphArchiveEntry, error) {
+	var size [4]byte
+	_, err := io.ReadFull(ar.r, size[:])
+	if err != nil {
+		return nil, err
+	}
+	n := binary.BigEndian.Uint32(size[:])
+	if n > graphArchiveEntryMaximumSize {
+		return nil, fmt.Errorf("invalid graph archive entry size %d at %d", n, ar.offset)
+	}
+	data := make([]byte, n)
+	_, err = io.ReadFull(ar.r, data)
+	if err != nil {
+		return nil, err
+	}
+	var checksum crypto.Hash
+	_, err = io.ReadFull(ar.r, checksum[:])
+	if err != nil {
+		return nil, err
+	}
+	if crypto.NewHash(append(ar.checksum[:], data...)) != checksum {
+		return nil, fmt.Errorf("invalid graph archive checksum at %d", ar.offset)
+	}
+	ar.offset += int64(len(size) + len(data) + len(checksum))
+	ar.checksum = checksum
+
+	var e graphArchiveEntry
+	err = common.DecompressMsgpackUnmarshal(data, &e)
+	return &e, err
+}
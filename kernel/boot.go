@@ -3,8 +3,11 @@ package kernel
 func (node *Node) Loop() error {
 	panicGo(node.ListenNeighbors)
 	panicGo(node.CosiLoop)
+	panicGo(node.ValidationLoop)
 	panicGo(node.LoadCacheToQueue)
 	panicGo(node.MintLoop)
 	panicGo(node.ElectionLoop)
+	panicGo(node.CheckpointLoop)
+	panicGo(node.PruneLoop)
 	return node.ConsumeQueue()
 }
@@ -0,0 +1,448 @@
+package kernel
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	CheckpointActionAnnouncement = iota
+	CheckpointActionCommitment
+	CheckpointActionChallenge
+	CheckpointActionResponse
+	CheckpointActionFinalization
+)
+
+type CheckpointAction struct {
+	Action     int
+	PeerId     crypto.Hash
+	Hash       crypto.Hash
+	Checkpoint *common.Checkpoint
+	Commitment *crypto.Key
+	Signature  *crypto.CosiSignature
+	Response   *[32]byte
+}
+
+type CheckpointAggregator struct {
+	Checkpoint  *common.Checkpoint
+	Commitments map[int]*crypto.Key
+	Responses   map[int]*[32]byte
+	random      *crypto.Key
+}
+
+type CheckpointVerifier struct {
+	Checkpoint *common.Checkpoint
+	Commitment *crypto.Key
+	random     *crypto.Key
+}
+
+func (node *Node) CheckpointLoop() error {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case m := <-node.checkpointActionsChan:
+			err := node.checkpointHandleAction(m)
+			if err != nil {
+				logger.Println(node.IdForNetwork, "checkpointHandleAction", m.Action, err)
+			}
+		case <-ticker.C:
+			err := node.checkpointPropose()
+			if err != nil {
+				logger.Println(node.IdForNetwork, "checkpointPropose", err)
+			}
+		}
+	}
+}
+
+func (node *Node) checkpointHandleAction(m *CheckpointAction) error {
+	switch m.Action {
+	case CheckpointActionAnnouncement:
+		return node.checkpointHandleAnnouncement(m)
+	case CheckpointActionCommitment:
+		return node.checkpointHandleCommitment(m)
+	case CheckpointActionChallenge:
+		return node.checkpointHandleChallenge(m)
+	case CheckpointActionResponse:
+		return node.checkpointHandleResponse(m)
+	case CheckpointActionFinalization:
+		return node.persistStore.WriteCheckpoint(m.Checkpoint)
+	}
+	return nil
+}
+
+// the checkpoint leader rotates by number among the sorted consensus nodes, and
+// a checkpoint is proposed for every config.CheckpointTopologyInterval snapshots
+func (node *Node) checkpointPropose() error {
+	if node.ConsensusIndex < 0 || !node.CheckCatchUpWithPeers() {
+		return nil
+	}
+	number := node.TopologicalOrder() / config.CheckpointTopologyInterval
+	if number == 0 || node.checkpointLeader(number) != node.IdForNetwork {
+		return nil
+	}
+	last, err := node.persistStore.ReadLastCheckpoint()
+	if err != nil {
+		return err
+	}
+	if last != nil && last.Number >= number {
+		return nil
+	}
+	if node.checkpointProposedAt.Add(10 * time.Minute).After(time.Now()) {
+		return nil
+	}
+	node.checkpointProposedAt = time.Now()
+
+	c := &common.Checkpoint{
+		Version:   common.CheckpointVersion,
+		Number:    number,
+		Timestamp: uint64(time.Now().UnixNano()),
+	}
+	for _, p := range node.BuildGraph() {
+		c.Rounds = append(c.Rounds, &common.CheckpointRound{NodeId: p.NodeId, Number: p.Number, Hash: p.Hash})
+	}
+	sortCheckpointRounds(c.Rounds)
+	state, err := node.persistStore.ComputeCheckpointState(node.networkId, c)
+	if err != nil {
+		return err
+	}
+	c.State = *state
+
+	hash := c.PayloadHash()
+	agg := &CheckpointAggregator{
+		Checkpoint:  c,
+		Commitments: make(map[int]*crypto.Key),
+		Responses:   make(map[int]*[32]byte),
+		random:      crypto.CosiCommit(rand.Reader),
+	}
+	R := agg.random.Public()
+	agg.Commitments[node.ConsensusIndex] = &R
+	node.checkpointAggregators = map[crypto.Hash]*CheckpointAggregator{hash: agg}
+	logger.Printf("CHECKPOINT PROPOSE %d %s\n", c.Number, hash)
+	for peerId, _ := range node.ConsensusNodes {
+		err := node.Peer.SendCheckpointAnnouncementMessage(peerId, c, R)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (node *Node) checkpointHandleAnnouncement(m *CheckpointAction) error {
+	if node.ConsensusIndex < 0 || !node.CheckCatchUpWithPeers() {
+		return nil
+	}
+	c := m.Checkpoint
+	if node.checkpointLeader(c.Number) != m.PeerId {
+		return nil
+	}
+	now := uint64(time.Now().UnixNano())
+	if c.Timestamp+uint64(5*time.Minute) < now || c.Timestamp > now+uint64(5*time.Minute) {
+		return nil
+	}
+	if node.checkpointVerifiers[m.Hash] != nil {
+		return nil
+	}
+	last, err := node.persistStore.ReadLastCheckpoint()
+	if err != nil || (last != nil && last.Number >= c.Number) {
+		return err
+	}
+	state, err := node.persistStore.ComputeCheckpointState(node.networkId, c)
+	if err != nil {
+		return err
+	}
+	if *state != c.State {
+		return fmt.Errorf("checkpoint %d state not match %s", c.Number, m.Hash)
+	}
+
+	v := &CheckpointVerifier{Checkpoint: c, Commitment: m.Commitment, random: crypto.CosiCommit(rand.Reader)}
+	node.checkpointVerifiers = map[crypto.Hash]*CheckpointVerifier{m.Hash: v}
+	return node.Peer.SendCheckpointCommitmentMessage(m.PeerId, m.Hash, v.random.Public())
+}
+
+func (node *Node) checkpointHandleCommitment(m *CheckpointAction) error {
+	agg := node.checkpointAggregators[m.Hash]
+	if agg == nil || agg.Checkpoint.Signature != nil {
+		return nil
+	}
+	index := node.checkpointConsensusIndex(m.PeerId)
+	if index < 0 || agg.Commitments[index] != nil {
+		return nil
+	}
+	agg.Commitments[index] = m.Commitment
+
+	c := agg.Checkpoint
+	if len(agg.Commitments) < node.ConsensusThreshold(c.Timestamp) {
+		return nil
+	}
+	cosi, err := crypto.CosiAggregateCommitment(agg.Commitments)
+	if err != nil {
+		return err
+	}
+	priv := node.Signer.PrivateSpendKey
+	publics := node.ConsensusKeys(c.Timestamp)
+	response, err := cosi.Response(&priv, agg.random, publics, m.Hash[:])
+	if err != nil {
+		return err
+	}
+	agg.Responses[node.ConsensusIndex] = &response
+	copy(cosi.Signature[32:], response[:])
+	c.Signature = cosi
+	for i, id := range node.SortedConsensusNodes {
+		if agg.Commitments[i] == nil {
+			continue
+		}
+		err := node.Peer.SendCheckpointChallengeMessage(id, m.Hash, cosi)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (node *Node) checkpointHandleChallenge(m *CheckpointAction) error {
+	v := node.checkpointVerifiers[m.Hash]
+	if v == nil || node.checkpointLeader(v.Checkpoint.Number) != m.PeerId {
+		return nil
+	}
+	leader := node.ConsensusNodes[m.PeerId]
+	if leader == nil {
+		return nil
+	}
+	delete(node.checkpointVerifiers, m.Hash)
+
+	publics := node.ConsensusKeys(v.Checkpoint.Timestamp)
+	challenge, err := m.Signature.Challenge(publics, m.Hash[:])
+	if err != nil {
+		return err
+	}
+	var sig crypto.Signature
+	copy(sig[:], v.Commitment[:])
+	copy(sig[32:], m.Signature.Signature[32:])
+	if !leader.Signer.PublicSpendKey.VerifyWithChallenge(m.Hash[:], sig, challenge) {
+		return fmt.Errorf("invalid checkpoint challenge signature %s", m.Hash)
+	}
+
+	priv := node.Signer.PrivateSpendKey
+	response, err := m.Signature.Response(&priv, v.random, publics, m.Hash[:])
+	if err != nil {
+		return err
+	}
+	return node.Peer.SendCheckpointResponseMessage(m.PeerId, m.Hash, response)
+}
+
+func (node *Node) checkpointHandleResponse(m *CheckpointAction) error {
+	agg := node.checkpointAggregators[m.Hash]
+	if agg == nil || agg.Checkpoint.Signature == nil {
+		return nil
+	}
+	index := node.checkpointConsensusIndex(m.PeerId)
+	if index < 0 || agg.Commitments[index] == nil || agg.Responses[index] != nil {
+		return nil
+	}
+	c := agg.Checkpoint
+	publics := node.ConsensusKeys(c.Timestamp)
+	err := c.Signature.VerifyResponse(publics, index, m.Response, m.Hash[:])
+	if err != nil {
+		return err
+	}
+	agg.Responses[index] = m.Response
+	if len(agg.Responses) != len(agg.Commitments) {
+		return nil
+	}
+
+	err = c.Signature.AggregateResponse(publics, agg.Responses, m.Hash[:], false)
+	if err != nil {
+		return err
+	}
+	err = c.Verify(publics, node.ConsensusThreshold(c.Timestamp))
+	if err != nil {
+		return err
+	}
+	delete(node.checkpointAggregators, m.Hash)
+	err = node.persistStore.WriteCheckpoint(c)
+	if err != nil {
+		return err
+	}
+	logger.Printf("CHECKPOINT FINALIZED %d %s\n", c.Number, m.Hash)
+	for peerId, _ := range node.ConsensusNodes {
+		err := node.Peer.SendCheckpointFinalizationMessage(peerId, c)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (node *Node) CheckpointQueueAnnouncement(peerId crypto.Hash, c *common.Checkpoint, R *crypto.Key) error {
+	if node.ConsensusNodes[peerId] == nil || c == nil || c.Signature != nil {
+		return nil
+	}
+	if c.Validate() != nil {
+		return nil
+	}
+	node.checkpointActionsChan <- &CheckpointAction{
+		Action:     CheckpointActionAnnouncement,
+		PeerId:     peerId,
+		Hash:       c.PayloadHash(),
+		Checkpoint: c,
+		Commitment: R,
+	}
+	return nil
+}
+
+func (node *Node) CheckpointAggregateCommitment(peerId crypto.Hash, hash crypto.Hash, R *crypto.Key) error {
+	if node.ConsensusNodes[peerId] == nil {
+		return nil
+	}
+	node.checkpointActionsChan <- &CheckpointAction{
+		Action:     CheckpointActionCommitment,
+		PeerId:     peerId,
+		Hash:       hash,
+		Commitment: R,
+	}
+	return nil
+}
+
+func (node *Node) CheckpointQueueChallenge(peerId crypto.Hash, hash crypto.Hash, cosi *crypto.CosiSignature) error {
+	if node.ConsensusNodes[peerId] == nil {
+		return nil
+	}
+	node.checkpointActionsChan <- &CheckpointAction{
+		Action:    CheckpointActionChallenge,
+		PeerId:    peerId,
+		Hash:      hash,
+		Signature: cosi,
+	}
+	return nil
+}
+
+func (node *Node) CheckpointAggregateResponse(peerId crypto.Hash, hash crypto.Hash, response *[32]byte) error {
+	if node.ConsensusNodes[peerId] == nil {
+		return nil
+	}
+	node.checkpointActionsChan <- &CheckpointAction{
+		Action:   CheckpointActionResponse,
+		PeerId:   peerId,
+		Hash:     hash,
+		Response: response,
+	}
+	return nil
+}
+
+func (node *Node) CheckpointVerifyFinalization(peerId crypto.Hash, c *common.Checkpoint) error {
+	if node.ConsensusNodes[peerId] == nil || c == nil {
+		return nil
+	}
+	err := c.Verify(node.ConsensusKeys(c.Timestamp), node.ConsensusThreshold(c.Timestamp))
+	if err != nil {
+		return nil
+	}
+	node.checkpointActionsChan <- &CheckpointAction{
+		Action:     CheckpointActionFinalization,
+		PeerId:     peerId,
+		Hash:       c.PayloadHash(),
+		Checkpoint: c,
+	}
+	return nil
+}
+
+func (node *Node) checkpointLeader(number uint64) crypto.Hash {
+	if len(node.SortedConsensusNodes) == 0 {
+		return crypto.Hash{}
+	}
+	return node.SortedConsensusNodes[number%uint64(len(node.SortedConsensusNodes))]
+}
+
+func sortCheckpointRounds(rounds []*common.CheckpointRound) {
+	sort.Slice(rounds, func(i, j int) bool {
+		a, b := rounds[i].NodeId, rounds[j].NodeId
+		return bytes.Compare(a[:], b[:]) < 0
+	})
+}
+
+func (node *Node) checkpointConsensusIndex(id crypto.Hash) int {
+	for i, n := range node.SortedConsensusNodes {
+		if n == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// ImportCheckpoint loads a checkpoint archive into an empty store, the checkpoint
+// must match the trusted hash and be signed by the consensus nodes in its state
+func ImportCheckpoint(store *storage.BadgerStore, configDir, path string, trusted crypto.Hash) (*common.Checkpoint, error) {
+	gns, err := readGenesis(configDir + "/genesis.json")
+	if err != nil {
+		return nil, err
+	}
+	// the consensus keys to verify the checkpoint depend on the params
+	params, err := applyGenesisParams(store, gns)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(gns)
+	if err != nil {
+		return nil, err
+	}
+	node := &Node{
+		networkId:       crypto.NewHash(data),
+		epoch:           uint64(time.Unix(gns.Epoch, 0).UnixNano()),
+		genesisNodesMap: make(map[crypto.Hash]bool),
+		ConsensusIndex:  -1,
+	}
+	for _, in := range gns.Nodes {
+		node.genesisNodesMap[in.Signer.Hash().ForNetwork(node.networkId)] = true
+	}
+
+	c, err := store.ImportCheckpoint(path, func(networkId crypto.Hash, c *common.Checkpoint, nodes []*common.Node) error {
+		if networkId != node.networkId {
+			return fmt.Errorf("invalid checkpoint network %s %s", networkId, node.networkId)
+		}
+		if hash := c.PayloadHash(); hash != trusted {
+			return fmt.Errorf("invalid checkpoint hash %s %s", hash, trusted)
+		}
+		node.persistStore = &checkpointNodesStore{Store: store, nodes: nodes}
+		err := node.LoadConsensusNodes()
+		if err != nil {
+			return err
+		}
+		return c.Verify(node.ConsensusKeys(c.Timestamp), node.ConsensusThreshold(c.Timestamp))
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = store.StateSet(stateKeyNetworkParams, params)
+	if err != nil {
+		return nil, err
+	}
+	return c, store.StateSet("network", struct{ Id crypto.Hash }{node.networkId})
+}
+
+type checkpointNodesStore struct {
+	storage.Store
+	nodes []*common.Node
+}
+
+func (s *checkpointNodesStore) ReadConsensusNodes() []*common.Node {
+	nodes := make([]*common.Node, 0)
+	for _, n := range s.nodes {
+		switch n.State {
+		case common.NodeStateAccepted, common.NodeStatePledging, common.NodeStateDeparting:
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
@@ -31,6 +31,7 @@ type CosiAction struct {
 	Response     *[32]byte
 	Transaction  *common.VersionedTransaction
 	WantTx       bool
+	validated    *validationResult
 }
 
 type CosiAggregator struct {
@@ -52,7 +53,9 @@ func (node *Node) CosiLoop() error {
 	for {
 		select {
 		case m := <-node.cosiActionsChan:
+			node.validated = m.validated
 			err := node.cosiHandleAction(m)
+			node.validated = nil
 			if err != nil {
 				return err
 			}
@@ -281,6 +284,9 @@ func (node *Node) cosiHandleAnnouncement(m *CosiAction) error {
 	}
 	if s.RoundNumber == cache.Number && !s.References.Equal(cache.References) {
 		if len(cache.Snapshots) > 0 {
+			// never commit to the conflicting round, the evidence is recorded
+			// when it is finalized by the other nodes
+			logger.Verbosef("cosiHandleAnnouncement conflict %s %d %s %s\n", s.NodeId, s.RoundNumber, s.References.Self, s.References.External)
 			return nil
 		}
 		if s.References.Self != cache.References.Self {
@@ -467,6 +473,14 @@ func (node *Node) cosiHandleChallenge(m *CosiAction) error {
 	if !pub.VerifyWithChallenge(m.SnapshotHash[:], sig, challenge) {
 		return nil
 	}
+	if s.Commitment != nil {
+		signed := *s
+		signed.Signature = m.Signature
+		node.checkSnapshotEquivocation(common.EvidenceTypeAnnouncement, &common.EvidenceSnapshot{
+			Snapshot:   &signed,
+			Commitment: s.Commitment,
+		})
+	}
 
 	priv := node.Signer.PrivateSpendKey
 	response, err := m.Signature.Response(&priv, v.random, publics, m.SnapshotHash[:])
@@ -561,6 +575,7 @@ func (node *Node) cosiHandleResponse(m *CosiAction) error {
 	if err != nil {
 		panic(err)
 	}
+	node.Mempool.RemoveFinalized(tx)
 	if !cache.ValidateSnapshot(s, true) {
 		panic("should never be here")
 	}
@@ -646,6 +661,7 @@ func (node *Node) cosiHandleFinalization(m *CosiAction) error {
 	if err != nil {
 		panic(err)
 	}
+	node.Mempool.RemoveFinalized(tx)
 	if !cache.ValidateSnapshot(s, true) {
 		panic("should never be here")
 	}
@@ -695,6 +711,16 @@ func (node *Node) CosiQueueExternalAnnouncement(peerId crypto.Hash, s *common.Sn
 	}
 	s.Hash = s.PayloadHash()
 	s.Commitment = commitment
+	tx, err := node.persistStore.CacheGetTransaction(s.Transaction)
+	if err != nil {
+		return err
+	}
+	if tx != nil {
+		err = node.addPeerPendingTransaction(peerId, tx)
+		if err != nil {
+			return err
+		}
+	}
 	return node.QueueAppendSnapshot(peerId, s, false)
 }
 
@@ -796,6 +822,8 @@ func (node *Node) VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s
 	if !node.CacheVerifyCosi(s.Hash, s.Signature, publics, base) {
 		return nil
 	}
+	signed := *s
+	node.checkSnapshotEquivocation(common.EvidenceTypeFinalization, &common.EvidenceSnapshot{Snapshot: &signed})
 
 	node.Peer.ConfirmSnapshotForPeer(peerId, s.Hash)
 	err := node.Peer.SendSnapshotConfirmMessage(peerId, s.Hash)
@@ -14,7 +14,7 @@ import (
 )
 
 func (node *Node) ElectionLoop() error {
-	for node.Graph.MyCacheRound == nil {
+	for {
 		time.Sleep(13 * time.Minute)
 		now := uint64(time.Now().UnixNano())
 		if now < node.epoch {
@@ -26,13 +26,18 @@ func (node *Node) ElectionLoop() error {
 			continue
 		}
 
-		err := node.tryToSendAcceptTransaction()
+		if node.Graph.MyCacheRound == nil {
+			err := node.tryToSendAcceptTransaction()
+			if err != nil {
+				logger.Println("tryToSendAcceptTransaction", err)
+			}
+			continue
+		}
+		err := node.tryToSendRemoveTransaction(now)
 		if err != nil {
-			logger.Println("tryToSendAcceptTransaction", err)
+			logger.Println("tryToSendRemoveTransaction", err)
 		}
 	}
-	logger.Println("ElectionLoop DONE")
-	return nil
 }
 
 func (node *Node) tryToSendAcceptTransaction() error {
@@ -67,7 +72,7 @@ func (node *Node) tryToSendAcceptTransaction() error {
 	tx.Extra = pledge.Extra
 	ver := tx.AsLatestVersion()
 
-	err = ver.Validate(node.persistStore)
+	err = ver.Validate(node.persistStore, uint64(time.Now().UnixNano()))
 	if err != nil {
 		return err
 	}
@@ -84,6 +89,55 @@ func (node *Node) tryToSendAcceptTransaction() error {
 	return nil
 }
 
+func (node *Node) consensusDeparting() *common.Node {
+	for _, cn := range node.ActiveNodes {
+		if cn.State == common.NodeStateDeparting {
+			return cn
+		}
+	}
+	return nil
+}
+
+func (node *Node) tryToSendRemoveTransaction(now uint64) error {
+	departing := node.consensusDeparting()
+	if departing == nil {
+		return nil
+	}
+	if node.ConsensusNodes[node.IdForNetwork] == nil {
+		return fmt.Errorf("invalid consensus node %s", node.IdForNetwork)
+	}
+	if now < departing.Timestamp+uint64(config.KernelNodeAcceptPeriodMinimum) {
+		return nil
+	}
+	depart, _, err := node.persistStore.ReadTransaction(departing.Transaction)
+	if err != nil {
+		return err
+	}
+	if depart == nil {
+		return fmt.Errorf("depart transaction not available yet %s", departing.Transaction)
+	}
+	if depart.TransactionType() != common.TransactionTypeNodeDepart {
+		return fmt.Errorf("invalid depart transaction type %d", depart.TransactionType())
+	}
+
+	ver := common.NewNodeRemoveTransaction(depart).AsLatestVersion()
+	err = ver.Validate(node.persistStore, now)
+	if err != nil {
+		return err
+	}
+	err = node.persistStore.CachePutTransaction(ver)
+	if err != nil {
+		return err
+	}
+	err = node.persistStore.QueueAppendSnapshot(node.IdForNetwork, &common.Snapshot{
+		Version:     common.SnapshotVersion,
+		NodeId:      node.IdForNetwork,
+		Transaction: ver.PayloadHash(),
+	}, false)
+	logger.Println("tryToSendRemoveTransaction", ver.PayloadHash(), hex.EncodeToString(ver.Marshal()))
+	return err
+}
+
 func (node *Node) reloadConsensusNodesList(s *common.Snapshot, tx *common.VersionedTransaction) error {
 	switch tx.TransactionType() {
 	case common.TransactionTypeNodePledge,
@@ -206,7 +260,7 @@ func (node *Node) validateNodePledgeSnapshot(s *common.Snapshot, tx *common.Vers
 	if len(tx.Extra) != 2*len(crypto.Key{}) {
 		return fmt.Errorf("invalid extra length %d for pledge transaction", len(tx.Extra))
 	}
-	if tx.Outputs[0].Amount.Cmp(common.NewInteger(10000)) != 0 {
+	if tx.Outputs[0].Amount.Cmp(common.NewInteger(config.KernelNodePledgeAmount)) != 0 {
 		return fmt.Errorf("invalid pledge amount %s", tx.Outputs[0].Amount.String())
 	}
 
@@ -385,3 +439,127 @@ func (node *Node) validateNodeAcceptSnapshot(s *common.Snapshot, tx *common.Vers
 
 	return nil
 }
+
+func (node *Node) validateNodeDepartSnapshot(s *common.Snapshot, tx *common.VersionedTransaction) error {
+	if tx.Asset != common.XINAssetId {
+		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+	}
+	if len(tx.Outputs) != 1 {
+		return fmt.Errorf("invalid outputs count %d for depart transaction", len(tx.Outputs))
+	}
+	if len(tx.Inputs) != 1 {
+		return fmt.Errorf("invalid inputs count %d for depart transaction", len(tx.Inputs))
+	}
+	if len(tx.Extra) != len(crypto.Key{})*2 {
+		return fmt.Errorf("invalid extra %s for depart transaction", hex.EncodeToString(tx.Extra))
+	}
+	if cn := node.ConsensusPledging; cn != nil {
+		return fmt.Errorf("invalid node state %s %s", cn.Signer, cn.State)
+	}
+	if cn := node.consensusDeparting(); cn != nil {
+		return fmt.Errorf("invalid node state %s %s", cn.Signer, cn.State)
+	}
+
+	var accepted *common.Node
+	for _, cn := range node.ConsensusNodes {
+		if bytes.Compare(cn.Signer.PublicSpendKey[:], tx.Extra[:len(crypto.Key{})]) == 0 {
+			accepted = cn
+		}
+	}
+	if accepted == nil {
+		return fmt.Errorf("invalid depart signer %s", hex.EncodeToString(tx.Extra[:len(crypto.Key{})]))
+	}
+	if accepted.Transaction != tx.Inputs[0].Hash {
+		return fmt.Errorf("invalid accept utxo source %s %s", accepted.Transaction, tx.Inputs[0].Hash)
+	}
+
+	timestamp := s.Timestamp
+	if s.Timestamp == 0 && s.NodeId == node.IdForNetwork {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	if timestamp < node.epoch {
+		return fmt.Errorf("invalid snapshot timestamp %d %d", node.epoch, timestamp)
+	}
+
+	since := timestamp - node.epoch
+	hours := int(since / 3600000000000)
+	if hours%24 < config.KernelNodeAcceptTimeBegin || hours%24 > config.KernelNodeAcceptTimeEnd {
+		return fmt.Errorf("invalid node depart hour %d", hours%24)
+	}
+
+	threshold := config.SnapshotRoundGap * config.SnapshotReferenceThreshold
+	if timestamp+threshold*2 < node.Graph.GraphTimestamp {
+		return fmt.Errorf("invalid snapshot timestamp %d %d", node.Graph.GraphTimestamp, timestamp)
+	}
+
+	if timestamp < accepted.Timestamp {
+		return fmt.Errorf("invalid snapshot timestamp %d %d", accepted.Timestamp, timestamp)
+	}
+	elapse := time.Duration(timestamp - accepted.Timestamp)
+	if elapse < config.KernelNodeAcceptPeriodMinimum {
+		return fmt.Errorf("invalid depart period %d %d", config.KernelNodeAcceptPeriodMinimum, elapse)
+	}
+
+	return node.persistStore.AddNodeOperation(tx, timestamp, uint64(config.KernelNodePledgePeriodMinimum)*2)
+}
+
+func (node *Node) validateNodeRemoveSnapshot(s *common.Snapshot, tx *common.VersionedTransaction) error {
+	if tx.Asset != common.XINAssetId {
+		return fmt.Errorf("invalid node asset %s", tx.Asset.String())
+	}
+	if len(tx.Outputs) != 1 {
+		return fmt.Errorf("invalid outputs count %d for remove transaction", len(tx.Outputs))
+	}
+	if len(tx.Inputs) != 1 {
+		return fmt.Errorf("invalid inputs count %d for remove transaction", len(tx.Inputs))
+	}
+	departing := node.consensusDeparting()
+	if departing == nil {
+		return fmt.Errorf("invalid consensus status")
+	}
+	if departing.Transaction != tx.Inputs[0].Hash {
+		return fmt.Errorf("invalid depart utxo source %s %s", departing.Transaction, tx.Inputs[0].Hash)
+	}
+
+	depart, _, err := node.persistStore.ReadTransaction(tx.Inputs[0].Hash)
+	if err != nil {
+		return err
+	}
+	if depart == nil {
+		return fmt.Errorf("depart transaction not available yet %s", tx.Inputs[0].Hash)
+	}
+	if remove := common.NewNodeRemoveTransaction(depart).AsLatestVersion(); remove.PayloadHash() != tx.PayloadHash() {
+		return fmt.Errorf("invalid remove transaction %s %s", remove.PayloadHash(), tx.PayloadHash())
+	}
+
+	timestamp := s.Timestamp
+	if s.Timestamp == 0 && s.NodeId == node.IdForNetwork {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	if timestamp < node.epoch {
+		return fmt.Errorf("invalid snapshot timestamp %d %d", node.epoch, timestamp)
+	}
+
+	since := timestamp - node.epoch
+	hours := int(since / 3600000000000)
+	if hours%24 < config.KernelNodeAcceptTimeBegin || hours%24 > config.KernelNodeAcceptTimeEnd {
+		return fmt.Errorf("invalid node remove hour %d", hours%24)
+	}
+
+	threshold := config.SnapshotRoundGap * config.SnapshotReferenceThreshold
+	if timestamp+threshold*2 < node.Graph.GraphTimestamp {
+		return fmt.Errorf("invalid snapshot timestamp %d %d", node.Graph.GraphTimestamp, timestamp)
+	}
+
+	if timestamp < departing.Timestamp {
+		return fmt.Errorf("invalid snapshot timestamp %d %d", departing.Timestamp, timestamp)
+	}
+	elapse := time.Duration(timestamp - departing.Timestamp)
+	if elapse < config.KernelNodeAcceptPeriodMinimum {
+		return fmt.Errorf("invalid remove period %d %d", config.KernelNodeAcceptPeriodMinimum, elapse)
+	}
+
+	// the remove transaction is identical on all nodes, so the lock only
+	// needs to cover the period between depart and remove
+	return node.persistStore.AddNodeOperation(tx, timestamp, uint64(config.KernelNodeAcceptPeriodMinimum))
+}
@@ -0,0 +1,38 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+// the signed snapshot is compared with the finalized snapshots in the same
+// node round, and all the conflicts are persisted as evidences of the node
+func (node *Node) checkSnapshotEquivocation(typ uint8, es *common.EvidenceSnapshot) {
+	s := es.Snapshot
+	if s.Version != common.SnapshotVersion || s.References == nil || s.Signature == nil {
+		return
+	}
+	snapshots, err := node.persistStore.ReadSnapshotsForNodeRound(s.NodeId, s.RoundNumber)
+	if err != nil {
+		logger.Println("checkSnapshotEquivocation", s.NodeId, s.RoundNumber, err)
+		return
+	}
+	for _, final := range snapshots {
+		if !final.Snapshot.ConflictsWith(s) {
+			continue
+		}
+		snap := final.Snapshot
+		e := common.NewEvidence(typ, &snap, es, uint64(time.Now().UnixNano()))
+		if err := e.Validate(); err != nil {
+			logger.Verbosef("checkSnapshotEquivocation %s %s %s\n", snap.Hash, s.PayloadHash(), err.Error())
+			continue
+		}
+		logger.Printf("EQUIVOCATION %d %s %d %s %s\n", typ, s.NodeId, s.RoundNumber, snap.Hash, s.PayloadHash())
+		err := node.persistStore.WriteEvidence(e)
+		if err != nil {
+			logger.Println("WriteEvidence", e.Hash(), err)
+		}
+	}
+}
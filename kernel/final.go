@@ -84,37 +84,108 @@ func (node *Node) legacyAppendFinalization(peerId crypto.Hash, s *common.Snapsho
 		return err
 	}
 
-	sigs := make([]*crypto.Signature, 0)
-	signaturesFilter := make(map[string]bool)
-	signersMap := make(map[crypto.Hash]bool)
-	for _, sig := range s.Signatures {
-		if signaturesFilter[sig.String()] {
+	s.Signatures = node.legacyValidSignatures(s)
+	if !node.legacyVerifyFinalization(s.Timestamp, s.Signatures) {
+		return nil
+	}
+
+	return node.QueueAppendSnapshot(peerId, s, true)
+}
+
+// the legacy signatures carry no signer index, so the signers matched for the
+// last legacy snapshot of the same node are guessed in the same order
+func (node *Node) legacyValidSignatures(s *common.Snapshot) []*crypto.Signature {
+	signers := make([]*crypto.Key, 0)
+	for _, cn := range node.ConsensusNodes {
+		signers = append(signers, &cn.Signer.PublicSpendKey)
+	}
+	if n := node.ConsensusPledging; n != nil {
+		id := n.Signer.Hash().ForNetwork(node.networkId)
+		if id == s.NodeId && s.RoundNumber == 0 {
+			signers = append(signers, &n.Signer.PublicSpendKey)
+		}
+	}
+
+	key := []byte("KERNEL:LEGACY:SIGNERS:" + s.NodeId.String())
+	val := node.cacheStore.Get(nil, key)
+	guess := make([]crypto.Key, len(val)/len(crypto.Key{}))
+	for i := range guess {
+		copy(guess[i][:], val[i*len(crypto.Key{}):])
+	}
+	sigs, keys := legacyMatchSignatures(s.Hash, s.Signatures, signers, guess, func(sig crypto.Signature, pub crypto.Key) bool {
+		return node.CacheVerify(s.Hash, sig, pub)
+	})
+	val = make([]byte, 0, len(keys)*len(crypto.Key{}))
+	for _, k := range keys {
+		val = append(val, k[:]...)
+	}
+	node.cacheStore.Set(key, val)
+	return sigs
+}
+
+// each distinct signature is matched with a distinct signer, the guessed pairs
+// are batch verified first, and only the signatures failed in the batch are
+// matched against the other signers one by one
+func legacyMatchSignatures(hash crypto.Hash, signatures []*crypto.Signature, signers []*crypto.Key, guess []crypto.Key, verify func(crypto.Signature, crypto.Key) bool) ([]*crypto.Signature, []crypto.Key) {
+	filter := make(map[crypto.Signature]bool)
+	unique := make([]*crypto.Signature, 0)
+	for _, sig := range signatures {
+		if filter[*sig] {
 			continue
 		}
-		for idForNetwork, cn := range node.ConsensusNodes {
-			if signersMap[idForNetwork] {
-				continue
-			}
-			if node.CacheVerify(s.Hash, *sig, cn.Signer.PublicSpendKey) {
-				sigs = append(sigs, sig)
-				signersMap[idForNetwork] = true
-				break
-			}
+		filter[*sig] = true
+		unique = append(unique, sig)
+	}
+
+	allowed := make(map[crypto.Key]bool)
+	for _, k := range signers {
+		allowed[*k] = true
+	}
+	used := make(map[crypto.Key]bool)
+	matched := make([]*crypto.Key, len(unique))
+	entries := make([]int, 0)
+	bv := crypto.NewBatchVerifier(len(unique))
+	for i, sig := range unique {
+		if i >= len(guess) {
+			break
 		}
-		if n := node.ConsensusPledging; n != nil {
-			id := n.Signer.Hash().ForNetwork(node.networkId)
-			if id == s.NodeId && s.RoundNumber == 0 && node.CacheVerify(s.Hash, *sig, n.Signer.PublicSpendKey) {
-				sigs = append(sigs, sig)
-				signersMap[id] = true
-			}
+		k := guess[i]
+		if !allowed[k] || used[k] {
+			continue
+		}
+		used[k] = true
+		matched[i] = &k
+		bv.Add(k, hash[:], *sig)
+		entries = append(entries, i)
+	}
+	_, results := bv.Verify()
+	for j, valid := range results {
+		if i := entries[j]; !valid {
+			used[*matched[i]] = false
+			matched[i] = nil
 		}
-		signaturesFilter[sig.String()] = true
 	}
-	s.Signatures = sigs
 
-	if !node.legacyVerifyFinalization(s.Timestamp, s.Signatures) {
-		return nil
+	for i, sig := range unique {
+		if matched[i] != nil {
+			continue
+		}
+		for _, k := range signers {
+			if !used[*k] && verify(*sig, *k) {
+				used[*k] = true
+				matched[i] = k
+				break
+			}
+		}
 	}
 
-	return node.QueueAppendSnapshot(peerId, s, true)
+	sigs := make([]*crypto.Signature, 0)
+	keys := make([]crypto.Key, 0)
+	for i, sig := range unique {
+		if matched[i] != nil {
+			sigs = append(sigs, sig)
+			keys = append(keys, *matched[i])
+		}
+	}
+	return sigs, keys
 }
@@ -0,0 +1,65 @@
+package kernel
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLegacyMatchSignatures(t *testing.T) {
+	assert := assert.New(t)
+
+	hash := crypto.NewHash([]byte("legacy-snapshot"))
+	signers := make([]*crypto.Key, 7)
+	sigs := make([]*crypto.Signature, 5)
+	for i := range signers {
+		seed := crypto.NewHash([]byte(fmt.Sprintf("legacy-signer-%d", i)))
+		priv := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+		pub := priv.Public()
+		signers[i] = &pub
+		if i < len(sigs) {
+			sig := priv.Sign(hash[:])
+			sigs[i] = &sig
+		}
+	}
+	var verified int
+	verify := func(sig crypto.Signature, pub crypto.Key) bool {
+		verified++
+		return pub.Verify(hash[:], sig)
+	}
+
+	var invalid crypto.Signature
+	signatures := append([]*crypto.Signature{sigs[0], &invalid}, sigs...)
+	matched, keys := legacyMatchSignatures(hash, signatures, signers, nil, verify)
+	assert.Len(matched, 5)
+	assert.Len(keys, 5)
+	for i, k := range keys {
+		assert.Equal(*sigs[i], *matched[i])
+		assert.Equal(*signers[i], k)
+	}
+	assert.Greater(verified, 5)
+
+	verified = 0
+	matched, keys = legacyMatchSignatures(hash, sigs, signers, keys, verify)
+	assert.Len(matched, 5)
+	assert.Len(keys, 5)
+	assert.Equal(0, verified)
+
+	verified = 0
+	guess := []crypto.Key{keys[1], keys[0], keys[2], keys[3], keys[4]}
+	matched, keys = legacyMatchSignatures(hash, sigs, signers, guess, verify)
+	assert.Len(matched, 5)
+	for i, k := range keys {
+		assert.Equal(*signers[i], k)
+	}
+	assert.Greater(verified, 0)
+
+	verified = 0
+	matched, keys = legacyMatchSignatures(hash, sigs, signers[1:], keys, verify)
+	assert.Len(matched, 4)
+	assert.Len(keys, 4)
+	assert.Equal(*signers[1], keys[0])
+	assert.Equal(len(signers)-len(sigs), verified)
+}
@@ -9,16 +9,18 @@ import (
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
 )
 
 const (
 	MinimumNodeCount = 7
-	PledgeAmount     = 10000
 )
 
 type Genesis struct {
-	Epoch int64 `json:"epoch"`
-	Nodes []struct {
+	Epoch         int64                 `json:"epoch"`
+	AddressPrefix string                `json:"address-prefix,omitempty"`
+	Params        *config.NetworkParams `json:"params,omitempty"`
+	Nodes         []struct {
 		Signer  common.Address `json:"signer"`
 		Payee   common.Address `json:"payee"`
 		Balance common.Integer `json:"balance"`
@@ -29,6 +31,38 @@ type Genesis struct {
 	} `json:"domains"`
 }
 
+const stateKeyNetworkParams = "params"
+
+// loadNetworkParams applies the params in the node state before any graph
+// validation, a node without the params state uses the default ones
+func (node *Node) loadNetworkParams() error {
+	var params config.NetworkParams
+	found, err := node.persistStore.StateGet(stateKeyNetworkParams, &params)
+	if err != nil || !found {
+		return err
+	}
+	return config.ApplyNetworkParams(&params)
+}
+
+// applyGenesisParams compares the genesis params with the stored ones and
+// applies them, the caller persists them after the genesis is accepted
+func applyGenesisParams(store storage.Store, gns *Genesis) (*config.NetworkParams, error) {
+	var stored *config.NetworkParams
+	var params config.NetworkParams
+	found, err := store.StateGet(stateKeyNetworkParams, &params)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		stored = &params
+	}
+	resolved, err := config.ResolveNetworkParams(gns.Params, stored)
+	if err != nil {
+		return nil, err
+	}
+	return resolved, config.ApplyNetworkParams(resolved)
+}
+
 func (node *Node) LoadGenesis(configDir string) error {
 	const stateKeyNetwork = "network"
 
@@ -36,6 +70,14 @@ func (node *Node) LoadGenesis(configDir string) error {
 	if err != nil {
 		return err
 	}
+	params, err := applyGenesisParams(node.persistStore, gns)
+	if err != nil {
+		return err
+	}
+	err = node.persistStore.StateSet(stateKeyNetworkParams, params)
+	if err != nil {
+		return err
+	}
 
 	data, err := json.Marshal(gns)
 	if err != nil {
@@ -79,7 +121,7 @@ func (node *Node) LoadGenesis(configDir string) error {
 
 		tx := common.NewTransaction(common.XINAssetId)
 		tx.Inputs = []*common.Input{{Genesis: node.networkId[:]}}
-		tx.AddOutputWithType(common.OutputTypeNodeAccept, accounts, script, common.NewInteger(PledgeAmount), seed)
+		tx.AddOutputWithType(common.OutputTypeNodeAccept, accounts, script, common.NewInteger(config.KernelNodePledgeAmount), seed)
 		tx.Extra = append(in.Signer.PublicSpendKey[:], in.Payee.PublicSpendKey[:]...)
 
 		nodeId := in.Signer.Hash().ForNetwork(node.networkId)
@@ -196,11 +238,46 @@ func readGenesis(path string) (*Genesis, error) {
 		return nil, err
 	}
 
+	// the address prefix must be set before decoding any address
+	var network struct {
+		AddressPrefix string `json:"address-prefix"`
+	}
+	err = json.Unmarshal(f, &network)
+	if err != nil {
+		return nil, err
+	}
+	if network.AddressPrefix != "" {
+		err = common.SetAddressNetworkId(network.AddressPrefix)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	var gns Genesis
 	err = json.Unmarshal(f, &gns)
 	if err != nil {
 		return nil, err
 	}
+	// the params not declared in genesis.json are the default ones
+	var declared struct {
+		Params json.RawMessage `json:"params"`
+	}
+	err = json.Unmarshal(f, &declared)
+	if err != nil {
+		return nil, err
+	}
+	params := config.DefaultNetworkParams()
+	if len(declared.Params) > 0 {
+		err = json.Unmarshal(declared.Params, params)
+		if err != nil {
+			return nil, err
+		}
+		err = params.Validate()
+		if err != nil {
+			return nil, err
+		}
+		gns.Params = params
+	}
 	if len(gns.Nodes) < MinimumNodeCount {
 		return nil, fmt.Errorf("invalid genesis inputs number %d/%d", len(gns.Nodes), MinimumNodeCount)
 	}
@@ -211,7 +288,7 @@ func readGenesis(path string) (*Genesis, error) {
 		if err != nil {
 			return nil, err
 		}
-		if in.Balance.Cmp(common.NewInteger(PledgeAmount)) != 0 {
+		if in.Balance.Cmp(common.NewInteger(params.NodePledgeAmount)) != 0 {
 			return nil, fmt.Errorf("invalid genesis node input amount %s", in.Balance.String())
 		}
 		if inputsFilter[in.Signer.String()] {
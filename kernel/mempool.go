@@ -0,0 +1,172 @@
+package kernel
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+const (
+	MempoolCapacity = 8192
+)
+
+type PendingTransaction struct {
+	Transaction *common.VersionedTransaction
+	Hash        crypto.Hash
+	Size        int
+	Timestamp   time.Time
+}
+
+type MempoolInfo struct {
+	Count  int
+	Inputs int
+	Size   int
+	TTL    time.Duration
+	Oldest time.Time
+}
+
+type Mempool struct {
+	mutex  *sync.RWMutex
+	txs    map[crypto.Hash]*PendingTransaction
+	inputs map[string]crypto.Hash
+	ttl    time.Duration
+}
+
+func NewMempool(ttl time.Duration) *Mempool {
+	return &Mempool{
+		mutex:  new(sync.RWMutex),
+		txs:    make(map[crypto.Hash]*PendingTransaction),
+		inputs: make(map[string]crypto.Hash),
+		ttl:    ttl,
+	}
+}
+
+func (m *Mempool) Add(tx *common.VersionedTransaction, now time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.evict(now)
+	hash := tx.PayloadHash()
+	if m.txs[hash] != nil {
+		return nil
+	}
+	if len(m.txs) >= MempoolCapacity {
+		return fmt.Errorf("mempool full %d", len(m.txs))
+	}
+	keys := make([]string, 0)
+	for _, in := range tx.Inputs {
+		key := mempoolInputKey(in)
+		if other, found := m.inputs[key]; found {
+			return common.NewValidationError(common.ErrorCodeInputConflict, "input %s spent by pending transaction %s", key, other).
+				With("input", key).With("transaction", other)
+		}
+		keys = append(keys, key)
+	}
+	for _, k := range keys {
+		m.inputs[k] = hash
+	}
+	m.txs[hash] = &PendingTransaction{
+		Transaction: tx,
+		Hash:        hash,
+		Size:        len(tx.Marshal()),
+		Timestamp:   now,
+	}
+	return nil
+}
+
+func (m *Mempool) Remove(hash crypto.Hash) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.remove(hash)
+}
+
+// remove the finalized transaction and all pending ones spending the same inputs
+func (m *Mempool) RemoveFinalized(tx *common.VersionedTransaction) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.remove(tx.PayloadHash())
+	for _, in := range tx.Inputs {
+		if other, found := m.inputs[mempoolInputKey(in)]; found {
+			m.remove(other)
+		}
+	}
+}
+
+func (m *Mempool) Get(hash crypto.Hash) *PendingTransaction {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.txs[hash]
+}
+
+func (m *Mempool) List(now time.Time) []*PendingTransaction {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.evict(now)
+	pending := make([]*PendingTransaction, 0)
+	for _, p := range m.txs {
+		pending = append(pending, p)
+	}
+	sort.Slice(pending, func(i, j int) bool {
+		return pending[i].Timestamp.Before(pending[j].Timestamp)
+	})
+	return pending
+}
+
+func (m *Mempool) Info(now time.Time) *MempoolInfo {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.evict(now)
+	info := &MempoolInfo{
+		Count:  len(m.txs),
+		Inputs: len(m.inputs),
+		TTL:    m.ttl,
+	}
+	for _, p := range m.txs {
+		info.Size = info.Size + p.Size
+		if info.Oldest.IsZero() || p.Timestamp.Before(info.Oldest) {
+			info.Oldest = p.Timestamp
+		}
+	}
+	return info
+}
+
+func (m *Mempool) evict(now time.Time) {
+	for h, p := range m.txs {
+		if p.Timestamp.Add(m.ttl).Before(now) {
+			m.remove(h)
+		}
+	}
+}
+
+func (m *Mempool) remove(hash crypto.Hash) {
+	p := m.txs[hash]
+	if p == nil {
+		return
+	}
+	for _, in := range p.Transaction.Inputs {
+		key := mempoolInputKey(in)
+		if m.inputs[key] == hash {
+			delete(m.inputs, key)
+		}
+	}
+	delete(m.txs, hash)
+}
+
+func mempoolInputKey(in *common.Input) string {
+	if in.Deposit != nil {
+		return "DEPOSIT:" + in.Deposit.UniqueKey().String()
+	}
+	if in.Mint != nil {
+		return fmt.Sprintf("MINT:%s:%d", in.Mint.Group, in.Mint.Batch)
+	}
+	return fmt.Sprintf("%s:%d", in.Hash.String(), in.Index)
+}
@@ -0,0 +1,73 @@
+package kernel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMempool(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Unix(1600000000, 0)
+	m := NewMempool(time.Minute)
+	a := mempoolTestTransaction("a", 0, 1)
+	assert.Nil(m.Add(a, now))
+	assert.Nil(m.Add(a, now))
+	assert.NotNil(m.Get(a.PayloadHash()))
+	info := m.Info(now)
+	assert.Equal(1, info.Count)
+	assert.Equal(2, info.Inputs)
+
+	b := mempoolTestTransaction("a", 1, 2)
+	err := m.Add(b, now)
+	assert.NotNil(err)
+	verr, ok := err.(*common.ValidationError)
+	assert.True(ok)
+	assert.Equal(common.ErrorCodeInputConflict, verr.Code)
+	assert.Nil(m.Get(b.PayloadHash()))
+
+	c := mempoolTestTransaction("c", 0)
+	assert.Nil(m.Add(c, now.Add(30*time.Second)))
+	pending := m.List(now.Add(time.Minute))
+	assert.Len(pending, 2)
+	assert.Equal(a.PayloadHash(), pending[0].Hash)
+	pending = m.List(now.Add(time.Minute + time.Second))
+	assert.Len(pending, 1)
+	assert.Equal(c.PayloadHash(), pending[0].Hash)
+	assert.Nil(m.Get(a.PayloadHash()))
+	assert.Nil(m.Add(b, now.Add(time.Minute+time.Second)))
+
+	d := mempoolTestTransaction("a", 3)
+	assert.Nil(m.Add(d, now.Add(time.Minute+time.Second)))
+	final := mempoolTestTransaction("a", 1, 3)
+	final.Extra = []byte("final")
+	m.RemoveFinalized(final)
+	assert.Nil(m.Get(b.PayloadHash()))
+	assert.NotNil(m.Get(c.PayloadHash()))
+	assert.Nil(m.Get(d.PayloadHash()))
+	info = m.Info(now.Add(time.Minute + time.Second))
+	assert.Equal(1, info.Count)
+	assert.Equal(1, info.Inputs)
+	m.RemoveFinalized(c)
+	assert.Equal(0, m.Info(now).Count)
+
+	for i := 0; i < MempoolCapacity; i++ {
+		assert.Nil(m.Add(mempoolTestTransaction(fmt.Sprint(i), 0), now))
+	}
+	assert.NotNil(m.Add(a, now))
+	assert.Nil(m.Add(a, now.Add(time.Minute+time.Second)))
+	assert.Equal(1, m.Info(now.Add(time.Minute+time.Second)).Count)
+}
+
+func mempoolTestTransaction(seed string, indexes ...int) *common.VersionedTransaction {
+	tx := common.NewTransaction(common.XINAssetId)
+	for _, i := range indexes {
+		tx.AddInput(crypto.NewHash([]byte(seed)), i)
+	}
+	return tx.AsLatestVersion()
+}
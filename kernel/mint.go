@@ -1,6 +1,7 @@
 package kernel
 
 import (
+	"encoding/binary"
 	"fmt"
 	"sort"
 	"time"
@@ -11,18 +12,6 @@ import (
 	"github.com/MixinNetwork/mixin/logger"
 )
 
-var (
-	MintPool        common.Integer
-	MintYearShares  int
-	MintYearBatches int
-)
-
-func init() {
-	MintPool = common.NewInteger(500000)
-	MintYearShares = 10
-	MintYearBatches = 365
-}
-
 func (node *Node) MintLoop() error {
 	for {
 		time.Sleep(7 * time.Minute)
@@ -41,18 +30,33 @@ func (node *Node) MintLoop() error {
 }
 
 func (node *Node) tryToMintKernelNode(batch uint64, amount common.Integer) error {
-	nodes := node.sortMintNodes()
-	per := amount.Div(len(nodes))
-	diff := amount.Sub(per.Mul(len(nodes)))
+	var head crypto.Hash
+	if mintWorkActivated(batch) {
+		cache, err := node.persistStore.ReadRound(node.IdForNetwork)
+		if err != nil {
+			return err
+		}
+		if cache == nil || cache.References == nil {
+			return fmt.Errorf("mint node %s not accepted yet", node.IdForNetwork)
+		}
+		head = cache.References.Self
+	}
+	outputs, diff, err := node.distributeMintByWork(batch, amount, head)
+	if err != nil {
+		return err
+	}
 
 	tx := common.NewTransaction(common.XINAssetId)
 	tx.AddKernelNodeMintInput(batch, amount)
+	if head.HasValue() {
+		tx.Extra = head[:]
+	}
 	script := common.NewThresholdScript(1)
-	for _, n := range nodes {
+	for _, o := range outputs {
 		in := fmt.Sprintf("MINTKERNELNODE%d", batch)
-		si := crypto.NewHash([]byte(n.Signer.String() + in))
+		si := crypto.NewHash([]byte(o.node.Signer.String() + in))
 		seed := append(si[:], si[:]...)
-		tx.AddScriptOutput([]common.Address{n.Payee}, script, per, seed)
+		tx.AddScriptOutput([]common.Address{o.node.Payee}, script, o.amount, seed)
 	}
 
 	if diff.Sign() > 0 {
@@ -65,11 +69,11 @@ func (node *Node) tryToMintKernelNode(batch uint64, amount common.Integer) error
 	}
 
 	signed := tx.AsLatestVersion()
-	err := signed.SignInput(node.persistStore, 0, []common.Address{node.Signer})
+	err = signed.SignInput(node.persistStore, 0, []common.Address{node.Signer})
 	if err != nil {
 		return err
 	}
-	err = signed.Validate(node.persistStore)
+	err = signed.Validate(node.persistStore, uint64(time.Now().UnixNano()))
 	if err != nil {
 		return err
 	}
@@ -98,15 +102,20 @@ func (node *Node) validateMintSnapshot(snap *common.Snapshot, tx *common.Version
 		return fmt.Errorf("invalid mint data %d %s", batch, amount.String())
 	}
 
-	nodes := node.sortMintNodes()
-	per := amount.Div(len(nodes))
-	diff := amount.Sub(per.Mul(len(nodes)))
+	head, err := node.mintWorkHead(snap, tx, uint64(batch))
+	if err != nil {
+		return err
+	}
+	outputs, diff, err := node.distributeMintByWork(uint64(batch), amount, head)
+	if err != nil {
+		return err
+	}
 
 	if diff.Sign() > 0 {
-		if len(nodes)+1 != len(tx.Outputs) {
-			return fmt.Errorf("invalid mint outputs count with diff %d %d", len(nodes), len(tx.Outputs))
+		if len(outputs)+1 != len(tx.Outputs) {
+			return fmt.Errorf("invalid mint outputs count with diff %d %d", len(outputs), len(tx.Outputs))
 		}
-		out := tx.Outputs[len(nodes)]
+		out := tx.Outputs[len(outputs)]
 		if diff.Cmp(out.Amount) != 0 {
 			return fmt.Errorf("invalid mint diff %s", diff.String())
 		}
@@ -126,24 +135,24 @@ func (node *Node) validateMintSnapshot(snap *common.Snapshot, tx *common.Version
 		if r.Public() != out.Mask {
 			return fmt.Errorf("invalid mint diff mask %s %s", r.Public().String(), out.Mask.String())
 		}
-		ghost := crypto.ViewGhostOutputKey(&out.Keys[0], &addr.PrivateViewKey, &out.Mask, uint64(len(nodes)))
+		ghost := crypto.ViewGhostOutputKey(&out.Keys[0], &addr.PrivateViewKey, &out.Mask, uint64(len(outputs)))
 		if *ghost != addr.PublicSpendKey {
 			return fmt.Errorf("invalid mint diff signature %s %s", addr.PublicSpendKey.String(), ghost.String())
 		}
-		return nil
-	} else if len(nodes) != len(tx.Outputs) {
-		return fmt.Errorf("invalid mint outputs count %d %d", len(nodes), len(tx.Outputs))
+	} else if len(outputs) != len(tx.Outputs) {
+		return fmt.Errorf("invalid mint outputs count %d %d", len(outputs), len(tx.Outputs))
 	}
 
 	for i, out := range tx.Outputs {
-		if i == len(nodes) {
+		if i == len(outputs) {
 			break
 		}
 		if out.Type != common.OutputTypeScript {
 			return fmt.Errorf("invalid mint output type %d", out.Type)
 		}
-		if per.Cmp(out.Amount) != 0 {
-			return fmt.Errorf("invalid mint output amount %s %s", per.String(), out.Amount.String())
+		o := outputs[i]
+		if o.amount.Cmp(out.Amount) != 0 {
+			return fmt.Errorf("invalid mint output amount %s %s", o.amount.String(), out.Amount.String())
 		}
 		if out.Script.String() != common.NewThresholdScript(1).String() {
 			return fmt.Errorf("invalid mint output script %s", out.Script.String())
@@ -151,7 +160,7 @@ func (node *Node) validateMintSnapshot(snap *common.Snapshot, tx *common.Version
 		if len(out.Keys) != 1 {
 			return fmt.Errorf("invalid mint output keys %d", len(out.Keys))
 		}
-		n := nodes[i]
+		n := o.node
 		in := fmt.Sprintf("MINTKERNELNODE%d", mint.Batch)
 		seed := crypto.NewHash([]byte(n.Signer.String() + in))
 		r := crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
@@ -167,6 +176,189 @@ func (node *Node) validateMintSnapshot(snap *common.Snapshot, tx *common.Version
 	return nil
 }
 
+type mintOutput struct {
+	node   *common.Node
+	amount common.Integer
+}
+
+// the amount is split equally before the activation batch, and weighted by
+// the work of the nodes in all the days of the amount since, the nodes without
+// any work get nothing, and the remainder goes to the diff
+func (node *Node) distributeMintByWork(batch uint64, amount common.Integer, head crypto.Hash) ([]*mintOutput, common.Integer, error) {
+	nodes := node.sortMintNodes()
+	works := make([]uint64, len(nodes))
+	if mintWorkActivated(batch) {
+		days := mintWorkDays(batch, amount)
+		if days == 0 {
+			return nil, common.Zero, fmt.Errorf("invalid mint work amount %d %s", batch, amount.String())
+		}
+		start, _ := mintWorkWindow(node.epoch, batch, days)
+		heads, err := readMintWorkHeads(node.persistStore.ReadRound, head, start)
+		if err != nil {
+			return nil, common.Zero, err
+		}
+		for i, n := range nodes {
+			id := n.IdForNetwork(node.networkId)
+			works[i], err = node.readMintWork(id, batch, days, head, heads[id])
+			if err != nil {
+				return nil, common.Zero, err
+			}
+		}
+	}
+
+	shares, diff := common.DistributeMintByWork(amount, works)
+	outputs := make([]*mintOutput, 0)
+	for i, n := range nodes {
+		if shares[i].Sign() > 0 {
+			outputs = append(outputs, &mintOutput{node: n, amount: shares[i]})
+		}
+	}
+	return outputs, diff, nil
+}
+
+func mintWorkActivated(batch uint64) bool {
+	activation := config.KernelMintWorkActivationBatch
+	return activation > 0 && batch >= activation
+}
+
+// the amount of the batch is the daily amount of all the days not minted
+// since the last distribution, so the days are recovered from it
+func mintWorkDays(batch uint64, amount common.Integer) uint64 {
+	full := kernelMintDailyAmount(int(batch))
+	for days := uint64(1); days <= batch; days++ {
+		if c := full.Mul(int(days)).Cmp(amount); c == 0 {
+			return days
+		} else if c > 0 {
+			break
+		}
+	}
+	return 0
+}
+
+func mintWorkWindow(epoch, batch, days uint64) (uint64, uint64) {
+	end := epoch + batch*uint64(24*time.Hour)
+	return end - days*uint64(24*time.Hour), end
+}
+
+// the work is counted only from the final rounds referenced by the round chain
+// of the mint snapshot node from the head, which is fixed by the mint snapshot
+// and the same on all nodes, the head round of each node is the latest one
+// referenced by the chain
+func readMintWorkHeads(read func(crypto.Hash) (*common.Round, error), head crypto.Hash, start uint64) (map[crypto.Hash]crypto.Hash, error) {
+	heads := make(map[crypto.Hash]crypto.Hash)
+	hash := head
+	for {
+		round, err := read(hash)
+		if err != nil {
+			return nil, err
+		}
+		if round == nil {
+			return nil, fmt.Errorf("mint work round %s not final yet", hash)
+		}
+		if len(heads) == 0 {
+			heads[round.NodeId] = hash
+		}
+		if round.Timestamp < start || round.Number == 0 || round.References == nil {
+			return heads, nil
+		}
+		external, err := read(round.References.External)
+		if err != nil {
+			return nil, err
+		}
+		if external == nil {
+			return nil, fmt.Errorf("mint work round %s not final yet", round.References.External)
+		}
+		if !heads[external.NodeId].HasValue() {
+			heads[external.NodeId] = round.References.External
+		}
+		hash = round.References.Self
+	}
+}
+
+// the work of a node is the number of its final rounds started in the days of
+// the batch, counted from its head round referenced by the mint chain
+func (node *Node) readMintWork(id crypto.Hash, batch, days uint64, head, hash crypto.Hash) (uint64, error) {
+	if !hash.HasValue() {
+		return 0, nil
+	}
+	key := []byte(fmt.Sprintf("KERNEL:MINTWORK:%d:%d:%s:%s", batch, days, head, id))
+	if value := node.cacheStore.Get(nil, key); len(value) == 8 {
+		return binary.BigEndian.Uint64(value), nil
+	}
+
+	start, end := mintWorkWindow(node.epoch, batch, days)
+	work, err := countMintWork(node.persistStore.ReadRound, hash, start, end)
+	if err != nil {
+		return 0, err
+	}
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, work)
+	node.cacheStore.Set(key, buf)
+	return work, nil
+}
+
+func countMintWork(read func(crypto.Hash) (*common.Round, error), hash crypto.Hash, start, end uint64) (uint64, error) {
+	var work uint64
+	for {
+		round, err := read(hash)
+		if err != nil {
+			return 0, err
+		}
+		if round == nil {
+			return 0, fmt.Errorf("mint work round %s not final yet", hash)
+		}
+		if round.Timestamp < start {
+			return work, nil
+		}
+		if round.Timestamp < end {
+			work++
+		}
+		if round.Number == 0 || round.References == nil {
+			return work, nil
+		}
+		hash = round.References.Self
+	}
+}
+
+// the work head is a final round of the mint snapshot node in the extra, it
+// must start after the work days, and not after the self reference of the
+// snapshot, which is not assigned before the announcement of the node
+func (node *Node) mintWorkHead(s *common.Snapshot, tx *common.VersionedTransaction, batch uint64) (crypto.Hash, error) {
+	var head crypto.Hash
+	if !mintWorkActivated(batch) {
+		if len(tx.Extra) > 0 {
+			return head, fmt.Errorf("invalid mint extra %x", tx.Extra)
+		}
+		return head, nil
+	}
+	if len(tx.Extra) != len(head) {
+		return head, fmt.Errorf("invalid mint work head %x", tx.Extra)
+	}
+	copy(head[:], tx.Extra)
+
+	round, err := node.persistStore.ReadRound(head)
+	if err != nil {
+		return head, err
+	}
+	if round == nil || head == s.NodeId || round.NodeId != s.NodeId {
+		return head, fmt.Errorf("mint work head %s not final for %s", head, s.NodeId)
+	}
+	if _, end := mintWorkWindow(node.epoch, batch, 1); round.Timestamp < end {
+		return head, fmt.Errorf("mint work head %s before the batch %d", head, batch)
+	}
+	if s.References == nil {
+		return head, nil
+	}
+	self, err := node.persistStore.ReadRound(s.References.Self)
+	if err != nil {
+		return head, err
+	}
+	if self == nil || self.NodeId != s.NodeId || self.Number < round.Number {
+		return head, fmt.Errorf("mint work head %s after the snapshot %s", head, s.References.Self)
+	}
+	return head, nil
+}
+
 func (node *Node) checkMintPossibility(timestamp uint64, validateOnly bool) (int, common.Integer) {
 	if timestamp <= node.epoch {
 		return 0, common.Zero
@@ -182,14 +374,7 @@ func (node *Node) checkMintPossibility(timestamp uint64, validateOnly bool) (int
 		return 0, common.Zero
 	}
 
-	pool := MintPool
-	for i := 0; i < batch/MintYearBatches; i++ {
-		pool = pool.Sub(pool.Div(MintYearShares))
-	}
-	pool = pool.Div(MintYearShares)
-	total := pool.Div(MintYearBatches)
-	light := total.Div(10)
-	full := light.Mul(9)
+	pool, total, light, full := kernelMintBatchAmounts(batch)
 
 	dist, err := node.persistStore.ReadLastMintDistribution(common.MintGroupKernelNode)
 	if err != nil {
@@ -213,6 +398,23 @@ func (node *Node) checkMintPossibility(timestamp uint64, validateOnly bool) (int
 	return batch, amount
 }
 
+func kernelMintBatchAmounts(batch int) (common.Integer, common.Integer, common.Integer, common.Integer) {
+	pool := common.NewInteger(config.KernelMintPool)
+	for i := 0; i < batch/config.KernelMintYearBatches; i++ {
+		pool = pool.Sub(pool.Div(config.KernelMintYearShares))
+	}
+	pool = pool.Div(config.KernelMintYearShares)
+	total := pool.Div(config.KernelMintYearBatches)
+	light := total.Div(10)
+	full := light.Mul(9)
+	return pool, total, light, full
+}
+
+func kernelMintDailyAmount(batch int) common.Integer {
+	_, _, _, full := kernelMintBatchAmounts(batch)
+	return full
+}
+
 func (node *Node) sortMintNodes() []*common.Node {
 	var nodes []*common.Node
 	for _, n := range node.ConsensusNodes {
@@ -0,0 +1,91 @@
+package kernel
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMintWorkMultipleBatches(t *testing.T) {
+	assert := assert.New(t)
+
+	full := kernelMintDailyAmount(10)
+	assert.Equal(uint64(1), mintWorkDays(10, full))
+	assert.Equal(uint64(3), mintWorkDays(10, full.Mul(3)))
+	assert.Equal(uint64(0), mintWorkDays(10, full.Mul(3).Add(common.NewInteger(1))))
+	assert.Equal(uint64(0), mintWorkDays(10, full.Mul(11)))
+
+	day := uint64(24 * time.Hour)
+	epoch := uint64(1551312000) * uint64(time.Second)
+	start, end := mintWorkWindow(epoch, 10, 3)
+	assert.Equal(epoch+7*day, start)
+	assert.Equal(epoch+10*day, end)
+
+	rounds := make(map[crypto.Hash]*common.Round)
+	read := func(hash crypto.Hash) (*common.Round, error) {
+		return rounds[hash], nil
+	}
+	add := func(id crypto.Hash, number, timestamp uint64, self, external crypto.Hash) crypto.Hash {
+		hash := crypto.NewHash([]byte(fmt.Sprintf("%s:%d", id, number)))
+		round := &common.Round{NodeId: id, Number: number, Timestamp: timestamp}
+		if number > 0 {
+			round.References = &common.RoundLink{Self: self, External: external}
+		}
+		rounds[hash] = round
+		return hash
+	}
+
+	a, b := crypto.NewHash([]byte("mint-a")), crypto.NewHash([]byte("mint-b"))
+	bs := []crypto.Hash{add(b, 0, epoch+6*day, crypto.Hash{}, crypto.Hash{})}
+	for i := uint64(1); i <= 4; i++ {
+		bs = append(bs, add(b, i, epoch+(6+i)*day+uint64(time.Hour), bs[i-1], crypto.Hash{}))
+	}
+	head := add(a, 0, epoch+6*day, crypto.Hash{}, crypto.Hash{})
+	for i := uint64(1); i <= 8; i++ {
+		timestamp := epoch + 7*day + (i-1)*day/2
+		external := bs[0]
+		for j, h := range bs {
+			if rounds[h].Timestamp <= timestamp {
+				external = bs[j]
+			}
+		}
+		head = add(a, i, timestamp, head, external)
+	}
+
+	heads, err := readMintWorkHeads(read, head, start)
+	assert.Nil(err)
+	assert.Len(heads, 2)
+	assert.Equal(head, heads[a])
+	assert.Equal(bs[4], heads[b])
+
+	works := make([]uint64, 2)
+	for i, id := range []crypto.Hash{a, b} {
+		works[i], err = countMintWork(read, heads[id], start, end)
+		assert.Nil(err)
+	}
+	assert.Equal([]uint64{6, 3}, works)
+	amount := full.Mul(3)
+	shares, diff := common.DistributeMintByWork(amount, works)
+	assert.Equal(amount.Mul(6).Div(9), shares[0])
+	assert.Equal(amount.Mul(3).Div(9), shares[1])
+	total := shares[0].Add(shares[1])
+	if diff.Sign() > 0 {
+		total = total.Add(diff)
+	}
+	assert.Equal(amount, total)
+
+	start, end = mintWorkWindow(epoch, 10, 1)
+	for i, id := range []crypto.Hash{a, b} {
+		works[i], err = countMintWork(read, heads[id], start, end)
+		assert.Nil(err)
+	}
+	assert.Equal([]uint64{2, 1}, works)
+
+	delete(rounds, bs[2])
+	_, err = countMintWork(read, heads[b], epoch+7*day, epoch+10*day)
+	assert.NotNil(err)
+}
@@ -21,7 +21,8 @@ import (
 )
 
 const (
-	MempoolSize = 8192
+	MempoolSize           = 8192
+	CheckpointActionsSize = 1024
 )
 
 type Node struct {
@@ -32,6 +33,7 @@ type Node struct {
 	cacheStore   *fastcache.Cache
 	Peer         *network.Peer
 	SyncPoints   *syncMap
+	Mempool      *Mempool
 	Listener     string
 
 	ActiveNodes          []*common.Node
@@ -43,6 +45,13 @@ type Node struct {
 	CosiAggregators *aggregatorMap
 	CosiVerifiers   map[crypto.Hash]*CosiVerifier
 
+	checkpointActionsChan chan *CheckpointAction
+	checkpointAggregators map[crypto.Hash]*CheckpointAggregator
+	checkpointVerifiers   map[crypto.Hash]*CheckpointVerifier
+	checkpointProposedAt  time.Time
+
+	observers *observerMap
+
 	genesisNodesMap map[crypto.Hash]bool
 	genesisNodes    []crypto.Hash
 	epoch           uint64
@@ -50,19 +59,32 @@ type Node struct {
 	networkId       crypto.Hash
 	persistStore    storage.Store
 	cosiActionsChan chan *CosiAction
+	validationQueue chan *validationJob
+	validationJobs  chan *validationJob
+	validated       *validationResult
 	configDir       string
 }
 
 func SetupNode(persistStore storage.Store, cacheStore *fastcache.Cache, addr string, dir string) (*Node, error) {
 	var node = &Node{
 		SyncPoints:      &syncMap{mutex: new(sync.RWMutex), m: make(map[crypto.Hash]*network.SyncPoint)},
+		Mempool:         NewMempool(config.Custom.CacheTTL * time.Second),
 		ConsensusIndex:  -1,
 		CosiAggregators: &aggregatorMap{mutex: new(sync.RWMutex), m: make(map[crypto.Hash]*CosiAggregator)},
 		CosiVerifiers:   make(map[crypto.Hash]*CosiVerifier),
+
+		checkpointActionsChan: make(chan *CheckpointAction, CheckpointActionsSize),
+		checkpointAggregators: make(map[crypto.Hash]*CheckpointAggregator),
+		checkpointVerifiers:   make(map[crypto.Hash]*CheckpointVerifier),
+
+		observers: &observerMap{mutex: new(sync.Mutex), m: make(map[string]Observer)},
+
 		genesisNodesMap: make(map[crypto.Hash]bool),
 		persistStore:    persistStore,
 		cacheStore:      cacheStore,
 		cosiActionsChan: make(chan *CosiAction, MempoolSize),
+		validationQueue: make(chan *validationJob, MempoolSize),
+		validationJobs:  make(chan *validationJob, MempoolSize),
 		configDir:       dir,
 		TopoCounter:     getTopologyCounter(persistStore),
 		startAt:         time.Now(),
@@ -70,10 +92,15 @@ func SetupNode(persistStore storage.Store, cacheStore *fastcache.Cache, addr str
 
 	node.LoadNodeConfig()
 
+	err := node.loadNetworkParams()
+	if err != nil {
+		return nil, err
+	}
+
 	logger.Println("Validating graph entries...")
 	start := time.Now()
 	var state struct{ Id crypto.Hash }
-	_, err := node.persistStore.StateGet("network", &state)
+	_, err = node.persistStore.StateGet("network", &state)
 	if err != nil {
 		return nil, err
 	}
@@ -113,6 +140,7 @@ func SetupNode(persistStore storage.Store, cacheStore *fastcache.Cache, addr str
 	logger.Printf("Network:\t%s\n", node.networkId.String())
 	logger.Printf("Node Id:\t%s\n", node.IdForNetwork.String())
 	logger.Printf("Topology:\t%d\n", node.TopoCounter.seq)
+	config.SealNetwork()
 	return node, nil
 }
 
@@ -332,7 +360,25 @@ func (node *Node) SendTransactionToPeer(peerId, hash crypto.Hash) error {
 }
 
 func (node *Node) CachePutTransaction(peerId crypto.Hash, tx *common.VersionedTransaction) error {
-	return node.persistStore.CachePutTransaction(tx)
+	err := node.persistStore.CachePutTransaction(tx)
+	if err != nil {
+		return err
+	}
+	return node.addPeerPendingTransaction(peerId, tx)
+}
+
+// the transactions from the peers are pending in the mempool until finalized
+// as well, and a conflict with another pending one is left to the consensus
+func (node *Node) addPeerPendingTransaction(peerId crypto.Hash, tx *common.VersionedTransaction) error {
+	_, finalized, err := node.persistStore.ReadTransaction(tx.PayloadHash())
+	if err != nil || len(finalized) > 0 {
+		return err
+	}
+	err = node.Mempool.Add(tx, time.Now())
+	if err != nil {
+		logger.Verbosef("addPeerPendingTransaction %s %s %s\n", peerId, tx.PayloadHash(), err.Error())
+	}
+	return nil
 }
 
 func (node *Node) ReadAllNodes() []crypto.Hash {
@@ -344,7 +390,16 @@ func (node *Node) ReadAllNodes() []crypto.Hash {
 	return hashes
 }
 
+// the peers sync the transactions of these snapshots, which are not available
+// before the pruned topology
 func (node *Node) ReadSnapshotsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, error) {
+	pruned, err := node.persistStore.ReadPrunedTopology()
+	if err != nil {
+		return nil, err
+	}
+	if offset < pruned {
+		return nil, fmt.Errorf("snapshots before the pruned topology %d %d", offset, pruned)
+	}
 	return node.persistStore.ReadSnapshotsSinceTopology(offset, count)
 }
 
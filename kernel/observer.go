@@ -0,0 +1,210 @@
+package kernel
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+const (
+	EventSnapshotFinalized = iota
+	EventRoundStarted
+	EventNodeStateChanged
+	EventMintDistributed
+	EventUTXOCreated
+	EventUTXOSpent
+)
+
+const (
+	observerBatchSize  = 100
+	observerRetryDelay = 3 * time.Second
+	stateKeyObserver   = "observer:"
+)
+
+// Event is delivered to the observers in the topology order of the snapshots,
+// all the events of a snapshot have the same topology and follow its snapshot
+// event, and they may be delivered again after a restart or observer error
+type Event struct {
+	Type        int
+	Topology    uint64
+	Snapshot    *common.SnapshotWithTopologicalOrder
+	Transaction *common.VersionedTransaction
+	Round       *common.Round
+	Node        *common.Node
+	Mint        *common.MintDistribution
+	UTXO        *common.UTXO
+	Input       *common.Input
+}
+
+// Observer must be idempotent for the events with the same topology, the
+// same event is retried until the observer returns no error
+type Observer interface {
+	Observe(e *Event) error
+}
+
+type observerMap struct {
+	mutex *sync.Mutex
+	m     map[string]Observer
+}
+
+// RegisterObserver starts to deliver the events to the observer from its
+// persisted cursor, or from the first snapshot if it's a new name
+func (node *Node) RegisterObserver(name string, o Observer) error {
+	node.observers.mutex.Lock()
+	defer node.observers.mutex.Unlock()
+
+	if node.observers.m[name] != nil {
+		return fmt.Errorf("observer %s already registered", name)
+	}
+	var offset uint64
+	_, err := node.persistStore.StateGet(stateKeyObserver+name, &offset)
+	if err != nil {
+		return err
+	}
+	pruned, err := node.persistStore.ReadPrunedTopology()
+	if err != nil {
+		return err
+	}
+	if offset < pruned {
+		return fmt.Errorf("observer %s cursor %d before the pruned topology %d", name, offset, pruned)
+	}
+	node.observers.m[name] = o
+	go node.observeLoop(name, o, offset)
+	return nil
+}
+
+// ObserverCursor returns the topology from which the events will be delivered
+// to the named observer
+func (node *Node) ObserverCursor(name string) (uint64, error) {
+	var offset uint64
+	_, err := node.persistStore.StateGet(stateKeyObserver+name, &offset)
+	return offset, err
+}
+
+func (node *Node) observeLoop(name string, o Observer, offset uint64) {
+	rounds := make(map[crypto.Hash]uint64)
+	for {
+		notify := node.persistStore.SnapshotsNotify()
+		snapshots, transactions, err := node.persistStore.ReadSnapshotWithTransactionsSinceTopology(offset, observerBatchSize)
+		if err != nil {
+			logger.Println("observeLoop ReadSnapshotWithTransactionsSinceTopology", name, err)
+			time.Sleep(observerRetryDelay)
+			continue
+		}
+		for i, s := range snapshots {
+			for {
+				err := node.observeSnapshot(o, rounds, s, transactions[i])
+				if err == nil {
+					break
+				}
+				logger.Println("observeLoop observeSnapshot", name, s.TopologicalOrder, err)
+				time.Sleep(observerRetryDelay)
+			}
+			offset = s.TopologicalOrder + 1
+		}
+		if len(snapshots) > 0 {
+			err := node.persistStore.StateSet(stateKeyObserver+name, offset)
+			if err != nil {
+				logger.Println("observeLoop StateSet", name, err)
+			}
+		}
+		if len(snapshots) < observerBatchSize {
+			<-notify
+		}
+	}
+}
+
+func (node *Node) observeSnapshot(o Observer, rounds map[crypto.Hash]uint64, s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction) error {
+	events := []*Event{{Type: EventSnapshotFinalized, Snapshot: s, Transaction: tx}}
+
+	// a round starts at its first snapshot in the topology, the map is only
+	// a cache of the rounds already checked in the store
+	if number, found := rounds[s.NodeId]; !found || number != s.RoundNumber {
+		snapshots, err := node.persistStore.ReadSnapshotsForNodeRound(s.NodeId, s.RoundNumber)
+		if err != nil {
+			return err
+		}
+		first := true
+		for _, rs := range snapshots {
+			first = first && rs.TopologicalOrder >= s.TopologicalOrder
+		}
+		if first {
+			events = append(events, &Event{Type: EventRoundStarted, Round: &common.Round{
+				NodeId:     s.NodeId,
+				Number:     s.RoundNumber,
+				Timestamp:  s.Timestamp,
+				References: s.References,
+			}})
+		}
+		rounds[s.NodeId] = s.RoundNumber
+	}
+
+	// the transaction body may be pruned
+	if tx != nil {
+		hash := tx.PayloadHash()
+		for _, in := range tx.Inputs {
+			if in.Mint != nil {
+				events = append(events, &Event{Type: EventMintDistributed, Mint: in.Mint.Distribute(hash)})
+			}
+			if in.Hash.HasValue() {
+				events = append(events, &Event{Type: EventUTXOSpent, Input: in, Transaction: tx})
+			}
+		}
+		for _, utxo := range tx.UnspentOutputs() {
+			events = append(events, &Event{Type: EventUTXOCreated, UTXO: utxo, Transaction: tx})
+		}
+		if n := observedNodeState(tx, s.Timestamp); n != nil {
+			events = append(events, &Event{Type: EventNodeStateChanged, Node: n, Transaction: tx})
+		}
+	}
+
+	for _, e := range events {
+		e.Topology = s.TopologicalOrder
+		err := o.Observe(e)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func observedNodeState(tx *common.VersionedTransaction, timestamp uint64) *common.Node {
+	if len(tx.Outputs) == 0 || len(tx.Extra) < 2*len(crypto.Key{}) {
+		return nil
+	}
+	// the output type instead of transaction type to include the genesis nodes
+	var state string
+	switch tx.Outputs[0].Type {
+	case common.OutputTypeNodePledge:
+		state = common.NodeStatePledging
+	case common.OutputTypeNodeCancel:
+		state = common.NodeStateCancelled
+	case common.OutputTypeNodeAccept:
+		state = common.NodeStateAccepted
+	case common.OutputTypeNodeDepart:
+		state = common.NodeStateDeparting
+	case common.OutputTypeNodeRemove:
+		state = common.NodeStateRemoved
+	default:
+		return nil
+	}
+
+	var signer, payee common.Address
+	copy(signer.PublicSpendKey[:], tx.Extra)
+	copy(payee.PublicSpendKey[:], tx.Extra[len(signer.PublicSpendKey):])
+	signer.PrivateViewKey = signer.PublicSpendKey.DeterministicHashDerive()
+	signer.PublicViewKey = signer.PrivateViewKey.Public()
+	payee.PrivateViewKey = payee.PublicSpendKey.DeterministicHashDerive()
+	payee.PublicViewKey = payee.PrivateViewKey.Public()
+	return &common.Node{
+		Signer:      signer,
+		Payee:       payee,
+		State:       state,
+		Transaction: tx.PayloadHash(),
+		Timestamp:   timestamp,
+	}
+}
@@ -0,0 +1,27 @@
+package kernel
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/logger"
+)
+
+func (node *Node) PruneLoop() error {
+	depth := config.Custom.PruneTopologyDepth
+	if depth == 0 {
+		return nil
+	}
+	for {
+		time.Sleep(17 * time.Minute)
+
+		topology := node.TopologicalOrder()
+		if topology <= depth {
+			continue
+		}
+		err := node.persistStore.PruneGraphEntries(topology - depth)
+		if err != nil {
+			logger.Println(node.IdForNetwork, "PruneGraphEntries", err)
+		}
+	}
+}
@@ -1,17 +1,25 @@
 package kernel
 
 import (
+	"time"
+
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
 )
 
 func (node *Node) QueueTransaction(tx *common.VersionedTransaction) (string, error) {
-	err := tx.Validate(node.persistStore)
+	err := tx.Validate(node.validationStore(), uint64(time.Now().UnixNano()))
+	if err != nil {
+		return "", err
+	}
+	err = node.Mempool.Add(tx, time.Now())
 	if err != nil {
 		return "", err
 	}
 	err = node.persistStore.CachePutTransaction(tx)
 	if err != nil {
+		node.Mempool.Remove(tx.PayloadHash())
 		return "", err
 	}
 	err = node.QueueAppendSnapshot(node.IdForNetwork, &common.Snapshot{
@@ -24,6 +32,11 @@ func (node *Node) QueueTransaction(tx *common.VersionedTransaction) (string, err
 
 func (node *Node) LoadCacheToQueue() error {
 	return node.persistStore.CacheListTransactions(func(tx *common.VersionedTransaction) error {
+		err := node.Mempool.Add(tx, time.Now())
+		if err != nil {
+			logger.Println("LoadCacheToQueue", tx.PayloadHash(), err)
+			return nil
+		}
 		return node.QueueAppendSnapshot(node.IdForNetwork, &common.Snapshot{
 			Version:     common.SnapshotVersion,
 			NodeId:      node.IdForNetwork,
@@ -49,7 +62,7 @@ func (node *Node) ConsumeQueue() error {
 		}
 
 		if m.Action == CosiActionExternalAnnouncement {
-			node.cosiActionsChan <- m
+			node.queueValidationAction(m)
 			return nil
 		}
 
@@ -58,7 +71,7 @@ func (node *Node) ConsumeQueue() error {
 			return err
 		}
 		if tx != nil {
-			node.cosiActionsChan <- m
+			node.queueValidationAction(m)
 			return nil
 		}
 
@@ -67,7 +80,7 @@ func (node *Node) ConsumeQueue() error {
 			return err
 		}
 		if tx != nil {
-			node.cosiActionsChan <- m
+			node.queueValidationAction(m)
 			return nil
 		}
 
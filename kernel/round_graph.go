@@ -0,0 +1,248 @@
+package kernel
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+const (
+	RoundStateCache     = "cache"
+	RoundStateFinal     = "final"
+	RoundStateMalformed = "malformed"
+	RoundStateMissing   = "missing"
+	RoundStateExternal  = "external"
+
+	RoundGraphExportLimit = 10000
+)
+
+type RoundGraphVertex struct {
+	NodeId     crypto.Hash       `json:"node"`
+	Number     uint64            `json:"number"`
+	Hash       crypto.Hash       `json:"hash"`
+	Start      uint64            `json:"start"`
+	End        uint64            `json:"end"`
+	Snapshots  int               `json:"snapshots"`
+	References *common.RoundLink `json:"references"`
+	State      string            `json:"state"`
+}
+
+type RoundGraphEdge struct {
+	From     crypto.Hash `json:"from"`
+	To       crypto.Hash `json:"to"`
+	External bool        `json:"external"`
+}
+
+type RoundGraphExport struct {
+	Rounds []*RoundGraphVertex `json:"rounds"`
+	Edges  []*RoundGraphEdge   `json:"edges"`
+}
+
+// ExportRoundGraph loads the rounds of all the nodes in the number range, or
+// the rounds started in the timestamp range if byTime, and the references of
+// them as the edges, the referenced rounds out of the range are external
+func ExportRoundGraph(store storage.Store, networkId crypto.Hash, from, to uint64, byTime bool) (*RoundGraphExport, error) {
+	if from > to {
+		return nil, fmt.Errorf("invalid round graph range %d %d", from, to)
+	}
+	var ids []crypto.Hash
+	for _, n := range store.ReadAllNodes() {
+		if n.State != common.NodeStatePledging {
+			ids = append(ids, n.IdForNetwork(networkId))
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return bytes.Compare(ids[i][:], ids[j][:]) < 0
+	})
+
+	export := &RoundGraphExport{}
+	for _, id := range ids {
+		head, err := store.ReadRound(id)
+		if err != nil {
+			return nil, err
+		}
+		if head == nil {
+			continue
+		}
+		first, last := from, to
+		if byTime {
+			first, last, err = roundNumbersInTime(store, id, head.Number, from, to)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if last > head.Number {
+			last = head.Number
+		}
+		for number := first; number <= last; number++ {
+			if len(export.Rounds) >= RoundGraphExportLimit {
+				return nil, fmt.Errorf("round graph too large, more than %d rounds", RoundGraphExportLimit)
+			}
+			v, err := loadRoundGraphVertex(store, head, number)
+			if err != nil {
+				return nil, err
+			}
+			if v != nil {
+				export.Rounds = append(export.Rounds, v)
+			}
+		}
+	}
+
+	vertices := make(map[crypto.Hash]bool)
+	for _, v := range export.Rounds {
+		vertices[v.Hash] = true
+	}
+	for _, v := range export.Rounds {
+		if v.References == nil {
+			continue
+		}
+		for i, ref := range []crypto.Hash{v.References.Self, v.References.External} {
+			export.Edges = append(export.Edges, &RoundGraphEdge{From: v.Hash, To: ref, External: i > 0})
+			if vertices[ref] {
+				continue
+			}
+			stub, err := loadRoundGraphStub(store, ref)
+			if err != nil {
+				return nil, err
+			}
+			export.Rounds = append(export.Rounds, stub)
+			vertices[ref] = true
+		}
+	}
+	return export, nil
+}
+
+func loadRoundGraphVertex(store storage.Store, head *common.Round, number uint64) (*RoundGraphVertex, error) {
+	snapshots, err := store.ReadSnapshotsForNodeRound(head.NodeId, number)
+	if err != nil {
+		return nil, err
+	}
+	v := &RoundGraphVertex{NodeId: head.NodeId, Number: number, Snapshots: len(snapshots)}
+	if number == head.Number {
+		v.Hash, v.Start, v.End, v.References, v.State = head.NodeId, head.Timestamp, head.Timestamp, head.References, RoundStateCache
+		for _, s := range snapshots {
+			if s.Timestamp > v.End {
+				v.End = s.Timestamp
+			}
+		}
+		return v, nil
+	}
+	if len(snapshots) == 0 {
+		return nil, nil
+	}
+
+	raw := make([]*common.Snapshot, len(snapshots))
+	for i, s := range snapshots {
+		raw[i] = &s.Snapshot
+	}
+	v.Start, v.End, v.Hash = ComputeRoundHash(head.NodeId, number, raw)
+	round, err := store.ReadRound(v.Hash)
+	if err != nil {
+		return nil, err
+	}
+	v.State = RoundStateMalformed
+	if round != nil {
+		v.References = round.References
+		if round.NodeId == head.NodeId && round.Number == number && round.Timestamp == v.Start {
+			v.State = RoundStateFinal
+		}
+	}
+	return v, nil
+}
+
+// the round or its snapshots may be missing, e.g. imported from a checkpoint
+func loadRoundGraphStub(store storage.Store, hash crypto.Hash) (*RoundGraphVertex, error) {
+	v := &RoundGraphVertex{Hash: hash, State: RoundStateMissing}
+	round, err := store.ReadRound(hash)
+	if err != nil || round == nil {
+		return v, err
+	}
+	v.NodeId, v.Number, v.Start, v.References = round.NodeId, round.Number, round.Timestamp, round.References
+	snapshots, err := store.ReadSnapshotsForNodeRound(round.NodeId, round.Number)
+	if err != nil {
+		return nil, err
+	}
+	if v.Snapshots = len(snapshots); v.Snapshots > 0 {
+		v.State = RoundStateExternal
+	}
+	return v, nil
+}
+
+// the rounds of a node start in time order, so the first and last rounds in
+// the timestamp range are found by binary search of the round numbers
+func roundNumbersInTime(store storage.Store, id crypto.Hash, head, from, to uint64) (uint64, uint64, error) {
+	var err error
+	search := func(timestamp uint64) uint64 {
+		return uint64(sort.Search(int(head+1), func(i int) bool {
+			if err != nil {
+				return true
+			}
+			var snapshots []*common.SnapshotWithTopologicalOrder
+			snapshots, err = store.ReadSnapshotsForNodeRound(id, uint64(i))
+			if len(snapshots) == 0 {
+				return uint64(i) == head
+			}
+			start := snapshots[0].Timestamp
+			for _, s := range snapshots {
+				if s.Timestamp < start {
+					start = s.Timestamp
+				}
+			}
+			return start >= timestamp
+		}))
+	}
+	first, end := search(from), head+1
+	if to < ^uint64(0) {
+		end = search(to + 1)
+	}
+	if err != nil || end == 0 {
+		return 1, 0, err
+	}
+	return first, end - 1, nil
+}
+
+// DOT renders the round graph in the Graphviz format, the rounds of each node
+// are in a cluster and colored by their state
+func (g *RoundGraphExport) DOT() string {
+	colors := map[string]string{
+		RoundStateCache:     "gold",
+		RoundStateFinal:     "palegreen",
+		RoundStateMalformed: "tomato",
+		RoundStateMissing:   "gray",
+		RoundStateExternal:  "white",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("digraph rounds {\n")
+	buf.WriteString("  rankdir=LR;\n")
+	buf.WriteString("  node [shape=box, style=filled];\n")
+	clusters := make(map[crypto.Hash][]*RoundGraphVertex)
+	var ids []crypto.Hash
+	for _, v := range g.Rounds {
+		if clusters[v.NodeId] == nil {
+			ids = append(ids, v.NodeId)
+		}
+		clusters[v.NodeId] = append(clusters[v.NodeId], v)
+	}
+	for i, id := range ids {
+		fmt.Fprintf(&buf, "  subgraph cluster_%d {\n", i)
+		fmt.Fprintf(&buf, "    label=\"%s\";\n", id.String()[:8])
+		for _, v := range clusters[id] {
+			fmt.Fprintf(&buf, "    \"%s\" [label=\"%d\\n%s\\n%d snapshots\", fillcolor=%s];\n", v.Hash, v.Number, v.Hash.String()[:8], v.Snapshots, colors[v.State])
+		}
+		buf.WriteString("  }\n")
+	}
+	for _, e := range g.Edges {
+		style := "solid"
+		if e.External {
+			style = "dashed"
+		}
+		fmt.Fprintf(&buf, "  \"%s\" -> \"%s\" [style=%s];\n", e.From, e.To, style)
+	}
+	buf.WriteString("}\n")
+	return buf.String()
+}
@@ -23,7 +23,11 @@ func (node *Node) checkCacheSnapshotTransaction(s *common.Snapshot) (*common.Ver
 		return nil, false, err
 	}
 
-	err = tx.Validate(node.persistStore)
+	timestamp := s.Timestamp
+	if s.Timestamp == 0 && s.NodeId == node.IdForNetwork {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	err = node.validateCacheTransaction(tx, timestamp)
 	if err != nil {
 		return nil, false, err
 	}
@@ -66,6 +70,18 @@ func (node *Node) validateKernelSnapshot(s *common.Snapshot, tx *common.Versione
 			logger.Println("validateNodeAcceptSnapshot", s, tx, err)
 			return err
 		}
+	case common.TransactionTypeNodeDepart:
+		err := node.validateNodeDepartSnapshot(s, tx)
+		if err != nil {
+			logger.Println("validateNodeDepartSnapshot", s, tx, err)
+			return err
+		}
+	case common.TransactionTypeNodeRemove:
+		err := node.validateNodeRemoveSnapshot(s, tx)
+		if err != nil {
+			logger.Println("validateNodeRemoveSnapshot", s, tx, err)
+			return err
+		}
 	}
 	if s.NodeId != node.IdForNetwork && s.RoundNumber == 0 && tx.TransactionType() != common.TransactionTypeNodeAccept {
 		return fmt.Errorf("invalid initial transaction type %d", tx.TransactionType())
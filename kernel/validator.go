@@ -0,0 +1,177 @@
+package kernel
+
+import (
+	"runtime"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/VictoriaMetrics/fastcache"
+)
+
+type validationJob struct {
+	action *CosiAction
+	result *validationResult
+	done   chan struct{}
+}
+
+// validationResult is a script transaction validated by the workers, with the
+// UTXO locks and ghost keys read during the validation, all the other checks
+// are pure or only get easier with a later timestamp
+type validationResult struct {
+	hash      crypto.Hash
+	timestamp uint64
+	locks     map[validationInput]crypto.Hash
+	ghosts    []crypto.Key
+}
+
+type validationInput struct {
+	hash  crypto.Hash
+	index int
+}
+
+// recordingStore records the state reads of a validation
+type recordingStore struct {
+	*validationStore
+	result *validationResult
+}
+
+func (s *recordingStore) ReadUTXO(hash crypto.Hash, index int) (*common.UTXOWithLock, error) {
+	utxo, err := s.validationStore.ReadUTXO(hash, index)
+	if err == nil && utxo != nil {
+		s.result.locks[validationInput{hash, index}] = utxo.LockHash
+	}
+	return utxo, err
+}
+
+func (s *recordingStore) CheckGhost(key crypto.Key) (bool, error) {
+	exist, err := s.validationStore.CheckGhost(key)
+	if err == nil && !exist {
+		s.result.ghosts = append(s.result.ghosts, key)
+	}
+	return exist, err
+}
+
+type validationStore struct {
+	storage.Store
+	cache *fastcache.Cache
+}
+
+func (s *validationStore) ReadSignatureResult(msg crypto.Hash, key crypto.Key, sig crypto.Signature) (bool, bool) {
+	value := s.cache.Get(nil, signatureResultKey(msg, key, sig))
+	if len(value) != 1 {
+		return false, false
+	}
+	return value[0] == byte(1), true
+}
+
+func (s *validationStore) WriteSignatureResult(msg crypto.Hash, key crypto.Key, sig crypto.Signature, valid bool) {
+	if valid {
+		s.cache.Set(signatureResultKey(msg, key, sig), []byte{1})
+	} else {
+		s.cache.Set(signatureResultKey(msg, key, sig), []byte{0})
+	}
+}
+
+func signatureResultKey(msg crypto.Hash, key crypto.Key, sig crypto.Signature) []byte {
+	data := append(msg[:], key[:]...)
+	data = append(data, sig[:]...)
+	return []byte("KERNEL:TXSIGNATURE:" + crypto.NewHash(data).String())
+}
+
+func (node *Node) validationStore() *validationStore {
+	return &validationStore{Store: node.persistStore, cache: node.cacheStore}
+}
+
+// the actions are validated concurrently by the workers, then handed to the
+// cosi loop in the same order as queued, with the results of the validated
+// script transactions, the cosi loop re-reads only the UTXO locks and ghost
+// keys of a result, and validates the transaction again if any of them has
+// changed since, so the results are the same as the sequential validation
+func (node *Node) ValidationLoop() error {
+	for i := 0; i < runtime.NumCPU(); i++ {
+		panicGo(node.validationWorker)
+	}
+	for {
+		select {
+		case job := <-node.validationQueue:
+			<-job.done
+			job.action.validated = job.result
+			node.cosiActionsChan <- job.action
+		}
+	}
+}
+
+func (node *Node) validationWorker() error {
+	for {
+		select {
+		case job := <-node.validationJobs:
+			job.result = node.prevalidateAction(job.action)
+			close(job.done)
+		}
+	}
+}
+
+func (node *Node) queueValidationAction(m *CosiAction) {
+	job := &validationJob{action: m, done: make(chan struct{})}
+	node.validationQueue <- job
+	node.validationJobs <- job
+}
+
+func (node *Node) prevalidateAction(m *CosiAction) *validationResult {
+	if m.Action != CosiActionSelfEmpty && m.Action != CosiActionExternalAnnouncement {
+		return nil
+	}
+	s := m.Snapshot
+	tx, err := node.persistStore.CacheGetTransaction(s.Transaction)
+	if err != nil || tx == nil {
+		return nil
+	}
+	timestamp := s.Timestamp
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().UnixNano())
+	}
+	if tx.TransactionType() != common.TransactionTypeScript {
+		tx.Validate(node.validationStore(), timestamp)
+		return nil
+	}
+	result := &validationResult{
+		hash:      tx.PayloadHash(),
+		timestamp: timestamp,
+		locks:     make(map[validationInput]crypto.Hash),
+	}
+	store := &recordingStore{validationStore: node.validationStore(), result: result}
+	if tx.Validate(store, timestamp) != nil {
+		return nil
+	}
+	return result
+}
+
+// validateCacheTransaction skips the validation of a transaction validated by
+// the workers if none of its reads has changed, it's called by the cosi loop
+func (node *Node) validateCacheTransaction(tx *common.VersionedTransaction, timestamp uint64) error {
+	if node.validated != nil && node.validated.unchanged(node.persistStore, tx.PayloadHash(), timestamp) {
+		return nil
+	}
+	return tx.Validate(node.validationStore(), timestamp)
+}
+
+func (r *validationResult) unchanged(store storage.Store, hash crypto.Hash, timestamp uint64) bool {
+	if r.hash != hash || timestamp < r.timestamp {
+		return false
+	}
+	for in, lock := range r.locks {
+		utxo, err := store.ReadUTXO(in.hash, in.index)
+		if err != nil || utxo == nil || utxo.LockHash != lock {
+			return false
+		}
+	}
+	for _, key := range r.ghosts {
+		exist, err := store.CheckGhost(key)
+		if err != nil || exist {
+			return false
+		}
+	}
+	return true
+}
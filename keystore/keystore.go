@@ -0,0 +1,248 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/MixinNetwork/mixin/common"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	Version = 1
+
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12
+	LightScryptP    = 6
+
+	scryptR      = 8
+	scryptKeyLen = 32
+	cipherName   = "aes-256-gcm"
+	kdfName      = "scrypt"
+)
+
+type KDFParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"`
+}
+
+type Crypto struct {
+	Cipher     string    `json:"cipher"`
+	CipherText string    `json:"ciphertext"`
+	Nonce      string    `json:"nonce"`
+	KDF        string    `json:"kdf"`
+	KDFParams  KDFParams `json:"kdfparams"`
+}
+
+type EncryptedKey struct {
+	Version int    `json:"version"`
+	Address string `json:"address"`
+	Crypto  Crypto `json:"crypto"`
+}
+
+type Keystore struct {
+	Dir     string
+	ScryptN int
+	ScryptP int
+}
+
+func DefaultDirectory() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "keystore"
+	}
+	return filepath.Join(home, ".mixin", "keystore")
+}
+
+func NewKeystore(dir string) (*Keystore, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+	return &Keystore{Dir: dir, ScryptN: StandardScryptN, ScryptP: StandardScryptP}, nil
+}
+
+func (ks *Keystore) Import(account common.Address, passphrase []byte) (*EncryptedKey, error) {
+	key, err := EncryptKey(account, passphrase, ks.ScryptN, ks.ScryptP)
+	if err != nil {
+		return nil, err
+	}
+	path := ks.path(key.Address)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("keystore account already exists %s", key.Address)
+	}
+	data, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return key, ioutil.WriteFile(path, data, 0600)
+}
+
+func (ks *Keystore) List() ([]string, error) {
+	files, err := ioutil.ReadDir(ks.Dir)
+	if err != nil {
+		return nil, err
+	}
+	addresses := make([]string, 0)
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		key, err := ReadEncryptedKey(filepath.Join(ks.Dir, f.Name()))
+		if err != nil {
+			continue
+		}
+		addresses = append(addresses, key.Address)
+	}
+	sort.Strings(addresses)
+	return addresses, nil
+}
+
+// ref is either the path to an encrypted key file or an address in the keystore
+func (ks *Keystore) Read(ref string) (*EncryptedKey, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return ReadEncryptedKey(ref)
+	}
+	return ReadEncryptedKey(ks.path(ref))
+}
+
+func (ks *Keystore) Unlock(ref string, passphrase []byte) (common.Address, error) {
+	key, err := ks.Read(ref)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return key.Decrypt(passphrase)
+}
+
+func (ks *Keystore) path(address string) string {
+	return filepath.Join(ks.Dir, address+".json")
+}
+
+func ReadEncryptedKey(path string) (*EncryptedKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var key EncryptedKey
+	err = json.Unmarshal(data, &key)
+	if err != nil {
+		return nil, err
+	}
+	if key.Version != Version {
+		return nil, fmt.Errorf("invalid keystore version %d", key.Version)
+	}
+	return &key, nil
+}
+
+func EncryptKey(account common.Address, passphrase []byte, n, p int) (*EncryptedKey, error) {
+	if !account.PrivateViewKey.HasValue() || !account.PrivateSpendKey.HasValue() {
+		return nil, fmt.Errorf("invalid keystore account %s", account.String())
+	}
+	account.PublicViewKey = account.PrivateViewKey.Public()
+	account.PublicSpendKey = account.PrivateSpendKey.Public()
+
+	salt := make([]byte, 32)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := scrypt.Key(passphrase, salt, n, scryptR, p, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newAEAD(derived)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	plain := append(append([]byte{}, account.PrivateViewKey[:]...), account.PrivateSpendKey[:]...)
+	address := account.String()
+	sealed := aead.Seal(nil, nonce, plain, []byte(address))
+
+	return &EncryptedKey{
+		Version: Version,
+		Address: address,
+		Crypto: Crypto{
+			Cipher:     cipherName,
+			CipherText: hex.EncodeToString(sealed),
+			Nonce:      hex.EncodeToString(nonce),
+			KDF:        kdfName,
+			KDFParams: KDFParams{
+				N:    n,
+				R:    scryptR,
+				P:    p,
+				Salt: hex.EncodeToString(salt),
+			},
+		},
+	}, nil
+}
+
+func (key *EncryptedKey) Decrypt(passphrase []byte) (common.Address, error) {
+	var account common.Address
+	c := key.Crypto
+	if c.Cipher != cipherName || c.KDF != kdfName {
+		return account, fmt.Errorf("invalid keystore cipher %s %s", c.Cipher, c.KDF)
+	}
+	salt, err := hex.DecodeString(c.KDFParams.Salt)
+	if err != nil {
+		return account, err
+	}
+	nonce, err := hex.DecodeString(c.Nonce)
+	if err != nil {
+		return account, err
+	}
+	sealed, err := hex.DecodeString(c.CipherText)
+	if err != nil {
+		return account, err
+	}
+	derived, err := scrypt.Key(passphrase, salt, c.KDFParams.N, c.KDFParams.R, c.KDFParams.P, scryptKeyLen)
+	if err != nil {
+		return account, err
+	}
+	aead, err := newAEAD(derived)
+	if err != nil {
+		return account, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return account, fmt.Errorf("invalid keystore nonce %s", c.Nonce)
+	}
+	plain, err := aead.Open(nil, nonce, sealed, []byte(key.Address))
+	if err != nil {
+		return account, fmt.Errorf("invalid keystore passphrase for %s", key.Address)
+	}
+	if len(plain) != 64 {
+		return account, fmt.Errorf("invalid keystore key length %d", len(plain))
+	}
+	copy(account.PrivateViewKey[:], plain[:32])
+	copy(account.PrivateSpendKey[:], plain[32:])
+	account.PublicViewKey = account.PrivateViewKey.Public()
+	account.PublicSpendKey = account.PrivateSpendKey.Public()
+	if len(key.Address) < 3 || account.StringForNetwork(key.Address[:3]) != key.Address {
+		return common.Address{}, fmt.Errorf("invalid keystore address %s", key.Address)
+	}
+	return account, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,63 @@
+package keystore
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeystore(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "mixin-keystore-test")
+	assert.Nil(err)
+	defer os.RemoveAll(dir)
+
+	ks, err := NewKeystore(dir)
+	assert.Nil(err)
+	ks.ScryptN, ks.ScryptP = LightScryptN, LightScryptP
+
+	seed := make([]byte, 64)
+	for i := 0; i < len(seed); i++ {
+		seed[i] = byte(i + 1)
+	}
+	account := common.NewAddressFromSeed(seed)
+	passphrase := []byte("correct horse battery staple")
+
+	_, err = ks.Import(common.Address{PublicSpendKey: account.PublicSpendKey}, passphrase)
+	assert.NotNil(err)
+	key, err := ks.Import(account, passphrase)
+	assert.Nil(err)
+	assert.Equal(account.String(), key.Address)
+	_, err = ks.Import(account, passphrase)
+	assert.NotNil(err)
+
+	addresses, err := ks.List()
+	assert.Nil(err)
+	assert.Equal([]string{account.String()}, addresses)
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, account.String()+".json"))
+	assert.Nil(err)
+	assert.NotContains(string(data), account.PrivateSpendKey.String())
+	assert.NotContains(string(data), account.PrivateViewKey.String())
+
+	_, err = ks.Unlock(account.String(), []byte("wrong"))
+	assert.NotNil(err)
+	unlocked, err := ks.Unlock(account.String(), passphrase)
+	assert.Nil(err)
+	assert.Equal(account.PrivateSpendKey, unlocked.PrivateSpendKey)
+	assert.Equal(account.PrivateViewKey, unlocked.PrivateViewKey)
+	assert.Equal(account.String(), unlocked.String())
+
+	unlocked, err = ks.Unlock(filepath.Join(dir, account.String()+".json"), passphrase)
+	assert.Nil(err)
+	assert.Equal(account.PrivateSpendKey, unlocked.PrivateSpendKey)
+
+	key.Address = common.NewAddressFromSeed(make([]byte, 64)).String()
+	_, err = key.Decrypt(passphrase)
+	assert.NotNil(err)
+}
@@ -0,0 +1,181 @@
+package light
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// NodeSet is the history of the kernel nodes, it starts from the trusted genesis
+// nodes and only changes with the finalized node transactions in time order,
+// and the periods are from the trusted network params instead of the config
+type NodeSet struct {
+	networkId crypto.Hash
+	params    *config.NetworkParams
+	genesis   map[crypto.Hash]bool
+	changes   []*common.Node
+}
+
+func NewNodeSet(networkId crypto.Hash, params *config.NetworkParams, genesis []*common.Node) *NodeSet {
+	s := &NodeSet{
+		networkId: networkId,
+		params:    params,
+		genesis:   make(map[crypto.Hash]bool),
+	}
+	for _, n := range genesis {
+		s.genesis[n.IdForNetwork(networkId)] = true
+		s.changes = append(s.changes, &common.Node{
+			Signer:      n.Signer,
+			Payee:       n.Payee,
+			State:       common.NodeStateAccepted,
+			Transaction: n.Transaction,
+			Timestamp:   n.Timestamp,
+		})
+	}
+	return s
+}
+
+// Update applies a node transaction finalized at the timestamp, the caller must
+// make sure it's finalized, or use Apply with the transaction proof instead
+func (s *NodeSet) Update(ver *common.VersionedTransaction, timestamp uint64) error {
+	if l := len(s.changes); l > 0 && s.changes[l-1].Timestamp > timestamp {
+		return fmt.Errorf("node transaction %s out of order %d %d", ver.PayloadHash(), timestamp, s.changes[l-1].Timestamp)
+	}
+	if len(ver.Extra) < 2*len(crypto.Key{}) {
+		return fmt.Errorf("invalid node transaction extra %s", ver.PayloadHash())
+	}
+	var signer, payee crypto.Key
+	copy(signer[:], ver.Extra)
+	copy(payee[:], ver.Extra[len(signer):])
+
+	var state string
+	var previous []string
+	switch ver.TransactionType() {
+	case common.TransactionTypeNodePledge:
+		state, previous = common.NodeStatePledging, []string{"", common.NodeStateCancelled, common.NodeStateRemoved}
+	case common.TransactionTypeNodeCancel:
+		state, previous = common.NodeStateCancelled, []string{common.NodeStatePledging}
+	case common.TransactionTypeNodeAccept:
+		state, previous = common.NodeStateAccepted, []string{common.NodeStatePledging}
+	case common.TransactionTypeNodeDepart:
+		state, previous = common.NodeStateDeparting, []string{common.NodeStateAccepted}
+	case common.TransactionTypeNodeRemove:
+		state, previous = common.NodeStateRemoved, []string{common.NodeStateAccepted, common.NodeStateDeparting}
+	default:
+		return fmt.Errorf("invalid node transaction type %d", ver.TransactionType())
+	}
+
+	var current string
+	for _, n := range s.changes {
+		if n.Signer.PublicSpendKey == signer {
+			current = n.State
+		}
+	}
+	valid := false
+	for _, p := range previous {
+		valid = valid || p == current
+	}
+	if !valid {
+		return fmt.Errorf("invalid node state %s %s %s", signer, current, state)
+	}
+
+	s.changes = append(s.changes, &common.Node{
+		Signer:      nodeAddress(signer),
+		Payee:       nodeAddress(payee),
+		State:       state,
+		Transaction: ver.PayloadHash(),
+		Timestamp:   timestamp,
+	})
+	return nil
+}
+
+// NodesAt returns the pledging, accepted and departing nodes right before the
+// timestamp, in the same order of the kernel active nodes
+func (s *NodeSet) NodesAt(timestamp uint64) []*common.Node {
+	latest := make(map[crypto.Key]*common.Node)
+	for i, n := range s.changes {
+		if i >= len(s.genesis) && n.Timestamp >= timestamp {
+			break
+		}
+		latest[n.Signer.PublicSpendKey] = n
+	}
+	nodes := make([]*common.Node, 0)
+	for _, n := range latest {
+		switch n.State {
+		case common.NodeStatePledging, common.NodeStateAccepted, common.NodeStateDeparting:
+			nodes = append(nodes, n)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		a, b := nodes[i], nodes[j]
+		if a.Timestamp != b.Timestamp {
+			return a.Timestamp < b.Timestamp
+		}
+		ai, bi := a.IdForNetwork(s.networkId), b.IdForNetwork(s.networkId)
+		return bytes.Compare(ai[:], bi[:]) < 0
+	})
+	return nodes
+}
+
+func (s *NodeSet) ConsensusKeys(timestamp uint64) []*crypto.Key {
+	accept := s.params.NodeAcceptPeriodMinimum * uint64(time.Second)
+	var keys []*crypto.Key
+	for _, n := range s.NodesAt(timestamp) {
+		if n.State != common.NodeStateAccepted {
+			continue
+		}
+		if s.genesis[n.IdForNetwork(s.networkId)] || n.Timestamp+accept < timestamp {
+			keys = append(keys, &n.Signer.PublicSpendKey)
+		}
+	}
+	return keys
+}
+
+func (s *NodeSet) ConsensusThreshold(timestamp uint64) int {
+	base := 0
+	accept := s.params.NodeAcceptPeriodMinimum * uint64(time.Second)
+	threshold := config.SnapshotReferenceThreshold * s.params.SnapshotRoundGap * uint64(time.Second)
+	for _, n := range s.NodesAt(timestamp) {
+		switch n.State {
+		case common.NodeStatePledging:
+			if n.Timestamp+accept-threshold*3 < timestamp {
+				base++
+			}
+		case common.NodeStateAccepted:
+			if s.genesis[n.IdForNetwork(s.networkId)] || n.Timestamp+threshold < timestamp {
+				base++
+			}
+		case common.NodeStateDeparting:
+			base++
+		}
+	}
+	return base*2/3 + 1
+}
+
+// SnapshotConsensus returns the keys and threshold to verify the snapshot, the
+// first snapshot of a pledging node is also signed by the node itself
+func (s *NodeSet) SnapshotConsensus(snap *common.Snapshot) ([]*crypto.Key, int) {
+	keys := s.ConsensusKeys(snap.Timestamp)
+	if snap.RoundNumber == 0 && !s.genesis[snap.NodeId] {
+		for _, n := range s.NodesAt(snap.Timestamp) {
+			if n.State == common.NodeStatePledging && n.IdForNetwork(s.networkId) == snap.NodeId {
+				keys = append(keys, &n.Signer.PublicSpendKey)
+			}
+		}
+	}
+	return keys, s.ConsensusThreshold(snap.Timestamp)
+}
+
+func nodeAddress(publicSpend crypto.Key) common.Address {
+	privateView := publicSpend.DeterministicHashDerive()
+	return common.Address{
+		PrivateViewKey: privateView,
+		PublicViewKey:  privateView.Public(),
+		PublicSpendKey: publicSpend,
+	}
+}
@@ -0,0 +1,102 @@
+package light
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+)
+
+// TransactionProof is all a light client needs to check the finality of a
+// transaction, the keys and threshold are only hints from the full node
+type TransactionProof struct {
+	Snapshot    *common.Snapshot `json:"snapshot"`
+	Transaction string           `json:"transaction"`
+	Keys        []*crypto.Key    `json:"keys"`
+	Threshold   int              `json:"threshold"`
+}
+
+func NewTransactionProof(s *common.Snapshot, tx *common.VersionedTransaction) *TransactionProof {
+	return &TransactionProof{
+		Snapshot:    s,
+		Transaction: hex.EncodeToString(tx.Marshal()),
+	}
+}
+
+// VerifyTransaction checks the snapshot signature of the proof with the keys of
+// the node set at the snapshot timestamp, and returns the finalized transaction
+func (s *NodeSet) VerifyTransaction(p *TransactionProof) (*common.VersionedTransaction, error) {
+	snap := p.Snapshot
+	if snap == nil {
+		return nil, fmt.Errorf("invalid proof without snapshot")
+	}
+	raw, err := hex.DecodeString(p.Transaction)
+	if err != nil {
+		return nil, err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(raw)
+	if err != nil {
+		return nil, err
+	}
+	if hash := ver.PayloadHash(); hash != snap.Transaction {
+		return nil, fmt.Errorf("invalid proof transaction %s %s", snap.Transaction, hash)
+	}
+
+	keys, threshold := s.SnapshotConsensus(snap)
+	if len(p.Keys) > 0 && !equalKeys(p.Keys, keys) {
+		return nil, fmt.Errorf("proof keys mismatch with the node set at %d", snap.Timestamp)
+	}
+	if p.Threshold > 0 && p.Threshold != threshold {
+		return nil, fmt.Errorf("proof threshold mismatch with the node set at %d %d %d", snap.Timestamp, p.Threshold, threshold)
+	}
+
+	hash := snap.PayloadHash()
+	switch snap.Version {
+	case 0:
+		if n := legacyValidSigners(snap.Signatures, keys, hash); n < threshold {
+			return nil, fmt.Errorf("invalid legacy snapshot signatures %s %d %d", hash, n, threshold)
+		}
+	case common.SnapshotVersion:
+		if snap.Signature == nil || !snap.Signature.FullVerify(keys, threshold, hash[:]) {
+			return nil, fmt.Errorf("invalid snapshot signature %s", hash)
+		}
+	default:
+		return nil, fmt.Errorf("invalid snapshot version %d", snap.Version)
+	}
+	return ver, nil
+}
+
+// Apply verifies the node transaction proof and updates the node set with it
+func (s *NodeSet) Apply(p *TransactionProof) error {
+	ver, err := s.VerifyTransaction(p)
+	if err != nil {
+		return err
+	}
+	return s.Update(ver, p.Snapshot.Timestamp)
+}
+
+func legacyValidSigners(sigs []*crypto.Signature, keys []*crypto.Key, hash crypto.Hash) int {
+	signers := make(map[int]bool)
+	for _, sig := range sigs {
+		for i, k := range keys {
+			if !signers[i] && k.Verify(hash[:], *sig) {
+				signers[i] = true
+				break
+			}
+		}
+	}
+	return len(signers)
+}
+
+func equalKeys(a, b []*crypto.Key) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if *a[i] != *b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,191 @@
+package light
+
+import (
+	"crypto/rand"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransactionProof(t *testing.T) {
+	assert := assert.New(t)
+
+	networkId := crypto.NewHash([]byte("light-proof-network"))
+	epoch := uint64(time.Date(2019, 2, 28, 0, 0, 0, 0, time.UTC).UnixNano())
+	privs := make([]crypto.Key, 8)
+	genesis := make([]*common.Node, 7)
+	for i := range privs {
+		seed := crypto.NewHash([]byte(fmt.Sprintf("light-proof-node-%d", i)))
+		privs[i] = crypto.NewKeyFromSeed(append(seed[:], seed[:]...))
+		if i < len(genesis) {
+			genesis[i] = &common.Node{
+				Signer:    nodeAddress(privs[i].Public()),
+				Payee:     nodeAddress(privs[i].Public()),
+				State:     common.NodeStateAccepted,
+				Timestamp: epoch,
+			}
+		}
+	}
+	params := config.DefaultNetworkParams()
+	period := params.NodeAcceptPeriodMinimum * uint64(time.Second)
+	set := NewNodeSet(networkId, params, genesis)
+	nodeId := genesis[0].IdForNetwork(networkId)
+
+	timestamp := epoch + uint64(time.Hour)
+	keys, threshold := set.SnapshotConsensus(&common.Snapshot{Timestamp: timestamp})
+	assert.Len(keys, 7)
+	assert.Equal(5, threshold)
+
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.AddInput(crypto.NewHash([]byte("light-proof-input")), 0)
+	tx.AddScriptOutput([]common.Address{genesis[1].Payee}, common.NewThresholdScript(1), common.NewInteger(100), make([]byte, 64))
+	ver := tx.AsLatestVersion()
+	s := newProofSnapshot(nodeId, ver, timestamp)
+	signProofSnapshot(s, privs, keys, []int{0, 1, 2, 3})
+	p := NewTransactionProof(s, ver)
+	_, err := set.VerifyTransaction(p)
+	assert.NotNil(err)
+	signProofSnapshot(s, privs, keys, []int{0, 2, 3, 5, 6})
+	res, err := set.VerifyTransaction(p)
+	assert.Nil(err)
+	assert.Equal(ver.PayloadHash(), res.PayloadHash())
+	p.Keys, p.Threshold = keys, threshold
+	_, err = set.VerifyTransaction(p)
+	assert.Nil(err)
+	p.Keys = keys[1:]
+	_, err = set.VerifyTransaction(p)
+	assert.NotNil(err)
+	p.Keys, p.Threshold = nil, threshold+1
+	_, err = set.VerifyTransaction(p)
+	assert.NotNil(err)
+	p.Threshold = 0
+	p.Snapshot.Timestamp++
+	_, err = set.VerifyTransaction(p)
+	assert.NotNil(err)
+	p.Snapshot.Timestamp--
+	p.Transaction = NewTransactionProof(s, common.NewTransaction(common.XINAssetId).AsLatestVersion()).Transaction
+	_, err = set.VerifyTransaction(p)
+	assert.NotNil(err)
+
+	legacy := newProofSnapshot(nodeId, ver, timestamp)
+	legacy.Version = 0
+	hash := legacy.PayloadHash()
+	for _, i := range []int{0, 1, 2, 3, 3} {
+		sig := privs[i].Sign(hash[:])
+		legacy.Signatures = append(legacy.Signatures, &sig)
+	}
+	_, err = set.VerifyTransaction(NewTransactionProof(legacy, ver))
+	assert.NotNil(err)
+	sig := privs[4].Sign(hash[:])
+	legacy.Signatures = append(legacy.Signatures, &sig)
+	_, err = set.VerifyTransaction(NewTransactionProof(legacy, ver))
+	assert.Nil(err)
+
+	signer, payee := nodeAddress(privs[7].Public()), nodeAddress(privs[7].Public())
+	extra := append(signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
+	pledge := newNodeTransaction(crypto.NewHash([]byte("light-proof-pledge")), common.OutputTypeNodePledge, extra)
+	s = newProofSnapshot(nodeId, pledge, timestamp)
+	signProofSnapshot(s, privs, keys, []int{0, 1, 2, 3, 4})
+	assert.Nil(set.Apply(NewTransactionProof(s, pledge)))
+	assert.NotNil(set.Apply(NewTransactionProof(s, pledge)))
+	assert.Len(set.NodesAt(timestamp+1), 8)
+	assert.Len(set.NodesAt(timestamp), 7)
+
+	timestamp = timestamp + period
+	keys, threshold = set.SnapshotConsensus(&common.Snapshot{Timestamp: timestamp})
+	assert.Len(keys, 7)
+	assert.Equal(6, threshold)
+	pledgeId := signer.Hash().ForNetwork(networkId)
+	initial, _ := set.SnapshotConsensus(&common.Snapshot{NodeId: pledgeId, Timestamp: timestamp})
+	assert.Len(initial, 8)
+	assert.Equal(signer.PublicSpendKey, *initial[7])
+
+	accept := newNodeTransaction(pledge.PayloadHash(), common.OutputTypeNodeAccept, extra)
+	s = newProofSnapshot(pledgeId, accept, timestamp)
+	signProofSnapshot(s, privs, initial, []int{0, 1, 2, 3, 7})
+	assert.NotNil(set.Apply(NewTransactionProof(s, accept)))
+	signProofSnapshot(s, privs, initial, []int{0, 1, 2, 3, 4, 7})
+	assert.Nil(set.Apply(NewTransactionProof(s, accept)))
+
+	keys, threshold = set.SnapshotConsensus(&common.Snapshot{Timestamp: timestamp + 1})
+	assert.Len(keys, 7)
+	assert.Equal(5, threshold)
+	timestamp = timestamp + period + 1
+	keys, threshold = set.SnapshotConsensus(&common.Snapshot{Timestamp: timestamp})
+	assert.Len(keys, 8)
+	assert.Equal(6, threshold)
+	assert.Equal(signer.PublicSpendKey, *keys[7])
+
+	genesisExtra := append(genesis[6].Signer.PublicSpendKey[:], genesis[6].Payee.PublicSpendKey[:]...)
+	remove := newNodeTransaction(crypto.NewHash([]byte("light-proof-genesis")), common.OutputTypeNodeRemove, genesisExtra)
+	s = newProofSnapshot(nodeId, remove, timestamp)
+	signProofSnapshot(s, privs, keys, []int{0, 1, 2, 3, 4, 7})
+	assert.Nil(set.Apply(NewTransactionProof(s, remove)))
+	keys, threshold = set.SnapshotConsensus(&common.Snapshot{Timestamp: timestamp + 1})
+	assert.Len(keys, 7)
+	assert.Equal(5, threshold)
+	assert.NotContains(keys, &genesis[6].Signer.PublicSpendKey)
+
+	assert.NotNil(set.Update(pledge, timestamp-1))
+	assert.NotNil(set.Update(ver, timestamp))
+}
+
+func newNodeTransaction(input crypto.Hash, ot uint8, extra []byte) *common.VersionedTransaction {
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.AddInput(input, 0)
+	tx.AddOutputWithType(ot, nil, common.Script{}, common.NewInteger(10000), []byte{})
+	tx.Extra = extra
+	return tx.AsLatestVersion()
+}
+
+func newProofSnapshot(nodeId crypto.Hash, ver *common.VersionedTransaction, timestamp uint64) *common.Snapshot {
+	return &common.Snapshot{
+		Version:     common.SnapshotVersion,
+		NodeId:      nodeId,
+		Transaction: ver.PayloadHash(),
+		References:  &common.RoundLink{},
+		Timestamp:   timestamp,
+	}
+}
+
+func signProofSnapshot(s *common.Snapshot, privs []crypto.Key, publics []*crypto.Key, signers []int) {
+	hash := s.PayloadHash()
+	randoms := make(map[int]*crypto.Key)
+	commitments := make(map[int]*crypto.Key)
+	for _, i := range signers {
+		for j, k := range publics {
+			if *k == privs[i].Public() {
+				r := crypto.CosiCommit(rand.Reader)
+				R := r.Public()
+				randoms[j], commitments[j] = r, &R
+			}
+		}
+	}
+	cosi, err := crypto.CosiAggregateCommitment(commitments)
+	if err != nil {
+		panic(err)
+	}
+	responses := make(map[int]*[32]byte)
+	for _, i := range signers {
+		priv := privs[i]
+		for j, k := range publics {
+			if *k == priv.Public() {
+				s, err := cosi.Response(&priv, randoms[j], publics, hash[:])
+				if err != nil {
+					panic(err)
+				}
+				responses[j] = &s
+			}
+		}
+	}
+	err = cosi.AggregateResponse(publics, responses, hash[:], true)
+	if err != nil {
+		panic(err)
+	}
+	s.Signature = cosi
+}
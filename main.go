@@ -8,8 +8,10 @@ import (
 	"runtime"
 	"time"
 
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/keystore"
 	"github.com/MixinNetwork/mixin/logger"
 	"github.com/MixinNetwork/mixin/rpc"
 	"github.com/MixinNetwork/mixin/storage"
@@ -27,6 +29,16 @@ func main() {
 	app.Usage = "A free and lightning fast peer-to-peer transactional network for digital assets."
 	app.Version = config.BuildVersion
 	app.EnableBashCompletion = true
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:  "network",
+			Value: common.MainNetworkId,
+			Usage: "the address network prefix, XIN for mainnet and XTN for test networks",
+		},
+	}
+	app.Before = func(c *cli.Context) error {
+		return common.SetAddressNetworkId(c.String("network"))
+	}
 	app.Commands = []cli.Command{
 		{
 			Name:    "kernel",
@@ -49,12 +61,23 @@ func main() {
 			Name:   "setuptestnet",
 			Usage:  "Setup the test nodes and genesis",
 			Action: setupTestNetCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "network",
+					Value: common.TestNetworkId,
+					Usage: "the address network prefix of the test network",
+				},
+			},
 		},
 		{
 			Name:   "createaddress",
 			Usage:  "Create a new Mixin address",
 			Action: createAdressCmd,
 			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "network",
+					Usage: "the address network prefix, XIN for mainnet and XTN for test networks",
+				},
 				cli.BoolFlag{
 					Name:  "public",
 					Usage: "whether mark all my transactions public",
@@ -74,12 +97,68 @@ func main() {
 			Usage:  "Decode an address as public view key and public spend key",
 			Action: decodeAddressCmd,
 			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "network",
+					Usage: "the address network prefix, XIN for mainnet and XTN for test networks",
+				},
 				cli.StringFlag{
 					Name:  "address,a",
 					Usage: "the Mixin Kernel address",
 				},
 			},
 		},
+		{
+			Name:   "importkey",
+			Usage:  "Encrypt a private key into the keystore",
+			Action: importKeyCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Value: keystore.DefaultDirectory(),
+					Usage: "the keystore directory",
+				},
+				cli.StringFlag{
+					Name:  "key",
+					Usage: "the private view and spend key, prompt if not set",
+				},
+				cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the file containing the keystore passphrase, prompt if not set",
+				},
+			},
+		},
+		{
+			Name:   "exportkey",
+			Usage:  "Decrypt a private key from the keystore",
+			Action: exportKeyCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Value: keystore.DefaultDirectory(),
+					Usage: "the keystore directory",
+				},
+				cli.StringFlag{
+					Name:  "keystore",
+					Usage: "the keystore address or encrypted key file",
+				},
+				cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the file containing the keystore passphrase, prompt if not set",
+				},
+			},
+		},
+		{
+			Name:   "listkeys",
+			Usage:  "List the addresses in the keystore",
+			Action: listKeysCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Value: keystore.DefaultDirectory(),
+					Usage: "the keystore directory",
+				},
+			},
+		},
 		{
 			Name:   "updateheadreference",
 			Usage:  "Update the cache round external reference, never use it unless agree by other nodes",
@@ -129,6 +208,74 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "exportcheckpoint",
+			Usage:  "Export the state archive of a finalized checkpoint",
+			Action: exportCheckpoint,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the data directory",
+				},
+				cli.Uint64Flag{
+					Name:  "number",
+					Usage: "the checkpoint `NUMBER`",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "the archive file path",
+				},
+			},
+		},
+		{
+			Name:   "importcheckpoint",
+			Usage:  "Bootstrap an empty data directory from a checkpoint archive",
+			Action: importCheckpoint,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the data directory with the genesis.json",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "the archive file path",
+				},
+				cli.StringFlag{
+					Name:  "hash",
+					Usage: "the trusted checkpoint `HASH`",
+				},
+			},
+		},
+		{
+			Name:   "exportgraph",
+			Usage:  "Export all the snapshots and transactions to a graph archive",
+			Action: exportGraph,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the data directory",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "the archive file path",
+				},
+			},
+		},
+		{
+			Name:   "importgraph",
+			Usage:  "Replay a graph archive to the data directory, resume if interrupted",
+			Action: importGraph,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the data directory with the config.json, genesis.json and nodes.json",
+				},
+				cli.StringFlag{
+					Name:  "file",
+					Usage: "the archive file path",
+				},
+			},
+		},
 		{
 			Name:   "signrawtransaction",
 			Usage:  "Sign a JSON encoded transaction",
@@ -147,12 +294,95 @@ func main() {
 					Name:  "key",
 					Usage: "the private key to sign the raw transaction",
 				},
+				cli.StringFlag{
+					Name:  "keystore",
+					Usage: "the keystore address or encrypted key file instead of the private key",
+				},
+				cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the file containing the keystore passphrase, prompt if not set",
+				},
+				cli.StringFlag{
+					Name:  "dir,d",
+					Value: keystore.DefaultDirectory(),
+					Usage: "the keystore directory",
+				},
 				cli.StringFlag{
 					Name:  "seed",
 					Usage: "the mask seed to hide the recipient public key",
 				},
 			},
 		},
+		{
+			Name:   "createpartialtransaction",
+			Usage:  "Create a partially signed transaction from a JSON encoded transaction",
+			Action: createPartialTransactionCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.StringFlag{
+					Name:  "raw",
+					Usage: "the JSON encoded raw transaction",
+				},
+				cli.StringFlag{
+					Name:  "seed",
+					Usage: "the mask seed to hide the recipient public key",
+				},
+			},
+		},
+		{
+			Name:   "signpartialtransaction",
+			Usage:  "Sign a hex encoded partially signed transaction offline",
+			Action: signPartialTransactionCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "raw",
+					Usage: "the hex encoded partially signed transaction",
+				},
+				cli.StringFlag{
+					Name:  "key",
+					Usage: "the private key to sign the partially signed transaction",
+				},
+				cli.StringFlag{
+					Name:  "keystore",
+					Usage: "the keystore address or encrypted key file instead of the private key",
+				},
+				cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the file containing the keystore passphrase, prompt if not set",
+				},
+				cli.StringFlag{
+					Name:  "dir,d",
+					Value: keystore.DefaultDirectory(),
+					Usage: "the keystore directory",
+				},
+			},
+		},
+		{
+			Name:   "mergepartialtransactions",
+			Usage:  "Merge the signatures of multiple partially signed transactions",
+			Action: mergePartialTransactionsCmd,
+			Flags: []cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "raw",
+					Usage: "the hex encoded partially signed transaction, repeat for each one",
+				},
+			},
+		},
+		{
+			Name:   "finalizepartialtransaction",
+			Usage:  "Finalize a partially signed transaction as a hex encoded signed raw transaction",
+			Action: finalizePartialTransactionCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "raw",
+					Usage: "the hex encoded partially signed transaction",
+				},
+			},
+		},
 		{
 			Name:   "sendrawtransaction",
 			Usage:  "Broadcast a hex encoded signed raw transaction",
@@ -169,6 +399,22 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "validaterawtransaction",
+			Usage:  "Validate a hex encoded signed raw transaction without broadcasting it",
+			Action: validateTransactionCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.StringFlag{
+					Name:  "raw",
+					Usage: "the hex encoded signed raw transaction",
+				},
+			},
+		},
 		{
 			Name:   "decoderawtransaction",
 			Usage:  "Decode a raw transaction as JSON",
@@ -193,6 +439,19 @@ func main() {
 					Name:  "spend",
 					Usage: "the private spend key which signs the pledging transaction",
 				},
+				cli.StringFlag{
+					Name:  "keystore",
+					Usage: "the keystore address or encrypted key file instead of the private key",
+				},
+				cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the file containing the keystore passphrase, prompt if not set",
+				},
+				cli.StringFlag{
+					Name:  "dir,d",
+					Value: keystore.DefaultDirectory(),
+					Usage: "the keystore directory",
+				},
 				cli.StringFlag{
 					Name:  "receiver",
 					Usage: "the address to receive the refund",
@@ -207,6 +466,34 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "buildnodedeparttransaction",
+			Usage:  "Build the transaction to depart an accepted node",
+			Action: departNodeCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "signer",
+					Usage: "the private signer key of the node",
+				},
+				cli.StringFlag{
+					Name:  "keystore",
+					Usage: "the keystore address or encrypted key file instead of the private signer key",
+				},
+				cli.StringFlag{
+					Name:  "passphrase-file",
+					Usage: "the file containing the keystore passphrase, prompt if not set",
+				},
+				cli.StringFlag{
+					Name:  "dir,d",
+					Value: keystore.DefaultDirectory(),
+					Usage: "the keystore directory",
+				},
+				cli.StringFlag{
+					Name:  "accept",
+					Usage: "the hex of raw accept transaction, or the genesis transaction of the node",
+				},
+			},
+		},
 		{
 			Name:   "decodenodepledgetransaction",
 			Usage:  "Decode the extra info of a pledge transaction",
@@ -218,6 +505,75 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "walletscan",
+			Usage:  "Scan the finalized snapshots for outputs owned by the wallet view keys",
+			Action: walletScanCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the wallet data directory",
+				},
+				cli.StringFlag{
+					Name:  "address",
+					Usage: "the address to add to the wallet",
+				},
+				cli.StringFlag{
+					Name:  "view",
+					Usage: "the private view key of the address to add",
+				},
+				cli.Uint64Flag{
+					Name:  "batch",
+					Value: 500,
+					Usage: "the snapshots count to scan in each batch",
+				},
+			},
+		},
+		{
+			Name:   "walletbalance",
+			Usage:  "Get the unspent balance of a wallet address",
+			Action: walletBalanceCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the wallet data directory",
+				},
+				cli.StringFlag{
+					Name:  "address",
+					Usage: "the wallet address",
+				},
+			},
+		},
+		{
+			Name:   "wallethistory",
+			Usage:  "List the transactions history of a wallet address",
+			Action: walletHistoryCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "dir,d",
+					Usage: "the wallet data directory",
+				},
+				cli.StringFlag{
+					Name:  "address",
+					Usage: "the wallet address",
+				},
+				cli.Uint64Flag{
+					Name:  "offset",
+					Value: 0,
+					Usage: "the topology offset",
+				},
+				cli.Uint64Flag{
+					Name:  "count",
+					Value: 10,
+					Usage: "the history entries count",
+				},
+			},
+		},
 		{
 			Name:   "getroundlink",
 			Usage:  "Get the latest link between two nodes",
@@ -358,6 +714,148 @@ func main() {
 				},
 			},
 		},
+		{
+			Name:   "getutxoproof",
+			Usage:  "Get the UTXO merkle proof by hash and index",
+			Action: getUTXOProofCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.StringFlag{
+					Name:  "hash,x",
+					Usage: "the transaction hash",
+				},
+				cli.Uint64Flag{
+					Name:  "index,i",
+					Value: 0,
+					Usage: "the output index",
+				},
+			},
+		},
+		{
+			Name:   "gettransactionproof",
+			Usage:  "Get the finalization proof of a transaction for light clients",
+			Action: getTransactionProofCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.StringFlag{
+					Name:  "hash,x",
+					Usage: "the transaction hash",
+				},
+			},
+		},
+		{
+			Name:   "listnodeproofs",
+			Usage:  "List the genesis nodes and the finalization proofs of all node transactions",
+			Action: listNodeProofsCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+			},
+		},
+		{
+			Name:   "getroundgraph",
+			Usage:  "Export the round graph in a number or time range as Graphviz DOT or JSON",
+			Action: getRoundGraphCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.Uint64Flag{
+					Name:  "from",
+					Value: 0,
+					Usage: "the first round number, or the start timestamp with -time",
+				},
+				cli.Uint64Flag{
+					Name:  "to",
+					Value: 0,
+					Usage: "the last round number, or the end timestamp with -time",
+				},
+				cli.BoolFlag{
+					Name:  "time",
+					Usage: "the range is in nanosecond timestamps",
+				},
+				cli.StringFlag{
+					Name:  "format",
+					Value: "dot",
+					Usage: "the output format, dot or json",
+				},
+			},
+		},
+		{
+			Name:   "listpendingtransactions",
+			Usage:  "List the pending transactions in the node mempool",
+			Action: listPendingTransactionsCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+			},
+		},
+		{
+			Name:   "getmempoolinfo",
+			Usage:  "Get the node mempool summary",
+			Action: getMempoolInfoCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+			},
+		},
+		{
+			Name:   "listevidences",
+			Usage:  "List the equivocation evidences of consensus nodes",
+			Action: listEvidencesCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.StringFlag{
+					Name:  "id",
+					Usage: "the consensus node id, all nodes if not set",
+				},
+			},
+		},
+		{
+			Name:   "listcheckpoints",
+			Usage:  "List the finalized state checkpoints",
+			Action: listCheckpointsCmd,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "node,n",
+					Value: "127.0.0.1:8239",
+					Usage: "the node RPC endpoint",
+				},
+				cli.Uint64Flag{
+					Name:  "since,s",
+					Value: 0,
+					Usage: "the checkpoint number to begin with",
+				},
+				cli.Uint64Flag{
+					Name:  "count,c",
+					Value: 10,
+					Usage: "the up limit of the returned checkpoints",
+				},
+			},
+		},
 		{
 			Name:   "listmintdistributions",
 			Usage:  "List mint distributions",
@@ -410,6 +908,10 @@ func kernelCmd(c *cli.Context) error {
 	if err != nil {
 		return err
 	}
+	err = unlockKernelSigner(c.String("dir"))
+	if err != nil {
+		return err
+	}
 
 	cache := fastcache.New(config.Custom.MaxCacheSize * 1024 * 1024)
 	go func() {
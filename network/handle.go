@@ -25,6 +25,16 @@ const (
 	PeerMessageTypeTransactionChallenge = 12 // leader send bitmask Z and aggragated R to peer
 	PeerMessageTypeSnapshotResponse     = 13 // peer generate A from nodes and Z, send response si = ri + H(R || A || M)ai to leader
 	PeerMessageTypeSnapshotFinalization = 14 // leader generate A, verify si B = ri B + H(R || A || M)ai B = Ri + H(R || A || M)Ai, then finaliz based on threshold
+
+	PeerMessageTypeTransactionChallengeBitmap = 15 // the challenge with a variable length bitmask Z for more than 64 nodes
+
+	PeerMessageTypeCheckpointAnnouncement = 16 // the checkpoint leader send the unsigned checkpoint with its Ri
+	PeerMessageTypeCheckpointCommitment   = 17
+	PeerMessageTypeCheckpointChallenge    = 18
+	PeerMessageTypeCheckpointResponse     = 19
+	PeerMessageTypeCheckpointFinalization = 20
+
+	PeerMessageTypeCheckpointChallengeBitmap = 21 // the checkpoint challenge for more than 64 nodes
 )
 
 type PeerMessage struct {
@@ -39,6 +49,8 @@ type PeerMessage struct {
 	WantTx          bool
 	FinalCache      []*SyncPoint
 	Auth            []byte
+	Checkpoint      *common.Checkpoint
+	CheckpointHash  crypto.Hash
 }
 
 type SyncHandle interface {
@@ -57,6 +69,11 @@ type SyncHandle interface {
 	CosiQueueExternalChallenge(peerId crypto.Hash, snap crypto.Hash, cosi *crypto.CosiSignature, ver *common.VersionedTransaction) error
 	CosiAggregateSelfResponses(peerId crypto.Hash, snap crypto.Hash, response *[32]byte) error
 	VerifyAndQueueAppendSnapshotFinalization(peerId crypto.Hash, s *common.Snapshot) error
+	CheckpointQueueAnnouncement(peerId crypto.Hash, c *common.Checkpoint, R *crypto.Key) error
+	CheckpointAggregateCommitment(peerId crypto.Hash, hash crypto.Hash, R *crypto.Key) error
+	CheckpointQueueChallenge(peerId crypto.Hash, hash crypto.Hash, cosi *crypto.CosiSignature) error
+	CheckpointAggregateResponse(peerId crypto.Hash, hash crypto.Hash, response *[32]byte) error
+	CheckpointVerifyFinalization(peerId crypto.Hash, c *common.Checkpoint) error
 }
 
 func (me *Peer) SendSnapshotAnnouncementMessage(idForNetwork crypto.Hash, s *common.Snapshot, R crypto.Key) error {
@@ -94,6 +111,31 @@ func (me *Peer) SendSnapshotFinalizationMessage(idForNetwork crypto.Hash, s *com
 	return me.sendSnapshotMessagetoPeer(idForNetwork, s.PayloadHash(), PeerMessageTypeSnapshotFinalization, data)
 }
 
+func (me *Peer) SendCheckpointAnnouncementMessage(idForNetwork crypto.Hash, c *common.Checkpoint, R crypto.Key) error {
+	data := buildCheckpointAnnouncementMessage(c, R)
+	return me.sendSnapshotMessagetoPeer(idForNetwork, c.PayloadHash(), PeerMessageTypeCheckpointAnnouncement, data)
+}
+
+func (me *Peer) SendCheckpointCommitmentMessage(idForNetwork crypto.Hash, hash crypto.Hash, R crypto.Key) error {
+	data := buildCheckpointCommitmentMessage(hash, R)
+	return me.sendSnapshotMessagetoPeer(idForNetwork, hash, PeerMessageTypeCheckpointCommitment, data)
+}
+
+func (me *Peer) SendCheckpointChallengeMessage(idForNetwork crypto.Hash, hash crypto.Hash, cosi *crypto.CosiSignature) error {
+	data := buildCheckpointChallengeMessage(hash, cosi)
+	return me.sendSnapshotMessagetoPeer(idForNetwork, hash, PeerMessageTypeCheckpointChallenge, data)
+}
+
+func (me *Peer) SendCheckpointResponseMessage(idForNetwork crypto.Hash, hash crypto.Hash, si [32]byte) error {
+	data := buildCheckpointResponseMessage(hash, si)
+	return me.sendSnapshotMessagetoPeer(idForNetwork, hash, PeerMessageTypeCheckpointResponse, data)
+}
+
+func (me *Peer) SendCheckpointFinalizationMessage(idForNetwork crypto.Hash, c *common.Checkpoint) error {
+	data := buildCheckpointFinalizationMessage(c)
+	return me.sendSnapshotMessagetoPeer(idForNetwork, c.PayloadHash(), PeerMessageTypeCheckpointFinalization, data)
+}
+
 func (me *Peer) SendSnapshotConfirmMessage(idForNetwork crypto.Hash, snap crypto.Hash) error {
 	key := snap.ForNetwork(idForNetwork)
 	key = crypto.NewHash(append(key[:], 'S', 'N', 'A', 'P', PeerMessageTypeSnapshotConfirm))
@@ -144,9 +186,17 @@ func buildSnapshotCommitmentMessage(snap crypto.Hash, R crypto.Key, wantTx bool)
 }
 
 func buildTransactionChallengeMessage(snap crypto.Hash, cosi *crypto.CosiSignature, tx *common.VersionedTransaction) []byte {
-	mask := make([]byte, 8)
-	binary.BigEndian.PutUint64(mask, cosi.Mask)
+	var mask []byte
 	data := []byte{PeerMessageTypeTransactionChallenge}
+	if cosi.MaskVersion() == crypto.CosiMaskVersionLegacy {
+		mask = make([]byte, 8)
+		binary.BigEndian.PutUint64(mask, cosi.LegacyMask())
+	} else {
+		mask = make([]byte, 2)
+		binary.BigEndian.PutUint16(mask, uint16(len(cosi.Bitmap)))
+		mask = append(mask, cosi.Bitmap...)
+		data = []byte{PeerMessageTypeTransactionChallengeBitmap}
+	}
 	data = append(data, snap[:]...)
 	data = append(data, cosi.Signature[:]...)
 	data = append(data, mask...)
@@ -168,6 +218,46 @@ func buildSnapshotFinalizationMessage(s *common.Snapshot) []byte {
 	return append([]byte{PeerMessageTypeSnapshotFinalization}, data...)
 }
 
+func buildCheckpointAnnouncementMessage(c *common.Checkpoint, R crypto.Key) []byte {
+	data := common.MsgpackMarshalPanic(c)
+	data = append(R[:], data...)
+	return append([]byte{PeerMessageTypeCheckpointAnnouncement}, data...)
+}
+
+func buildCheckpointCommitmentMessage(hash crypto.Hash, R crypto.Key) []byte {
+	data := []byte{PeerMessageTypeCheckpointCommitment}
+	data = append(data, hash[:]...)
+	return append(data, R[:]...)
+}
+
+func buildCheckpointChallengeMessage(hash crypto.Hash, cosi *crypto.CosiSignature) []byte {
+	var mask []byte
+	data := []byte{PeerMessageTypeCheckpointChallenge}
+	if cosi.MaskVersion() == crypto.CosiMaskVersionLegacy {
+		mask = make([]byte, 8)
+		binary.BigEndian.PutUint64(mask, cosi.LegacyMask())
+	} else {
+		mask = make([]byte, 2)
+		binary.BigEndian.PutUint16(mask, uint16(len(cosi.Bitmap)))
+		mask = append(mask, cosi.Bitmap...)
+		data = []byte{PeerMessageTypeCheckpointChallengeBitmap}
+	}
+	data = append(data, hash[:]...)
+	data = append(data, cosi.Signature[:]...)
+	return append(data, mask...)
+}
+
+func buildCheckpointResponseMessage(hash crypto.Hash, si [32]byte) []byte {
+	data := []byte{PeerMessageTypeCheckpointResponse}
+	data = append(data, hash[:]...)
+	return append(data, si[:]...)
+}
+
+func buildCheckpointFinalizationMessage(c *common.Checkpoint) []byte {
+	data := common.MsgpackMarshalPanic(c)
+	return append([]byte{PeerMessageTypeCheckpointFinalization}, data...)
+}
+
 func buildSnapshotConfirmMessage(snap crypto.Hash) []byte {
 	return append([]byte{PeerMessageTypeSnapshotConfirm}, snap[:]...)
 }
@@ -232,7 +322,7 @@ func parseNetworkMessage(data []byte) (*PeerMessage, error) {
 		}
 		copy(msg.SnapshotHash[:], data[1:])
 		copy(msg.Cosi.Signature[:], data[33:])
-		msg.Cosi.Mask = binary.BigEndian.Uint64(data[97:105])
+		msg.Cosi.SetLegacyMask(binary.BigEndian.Uint64(data[97:105]))
 		if len(data[1:]) > 104 {
 			ver, err := common.UnmarshalVersionedTransaction(data[105:])
 			if err != nil {
@@ -240,6 +330,28 @@ func parseNetworkMessage(data []byte) (*PeerMessage, error) {
 			}
 			msg.Transaction = ver
 		}
+	case PeerMessageTypeTransactionChallengeBitmap:
+		if len(data[1:]) < 98 {
+			return nil, fmt.Errorf("invalid challenge message size %d", len(data[1:]))
+		}
+		size := int(binary.BigEndian.Uint16(data[97:99]))
+		if len(data[1:]) < 98+size {
+			return nil, fmt.Errorf("invalid challenge message size %d %d", len(data[1:]), size)
+		}
+		copy(msg.SnapshotHash[:], data[1:])
+		copy(msg.Cosi.Signature[:], data[33:])
+		err := msg.Cosi.SetBitmap(data[99 : 99+size])
+		if err != nil {
+			return nil, err
+		}
+		if len(data[1:]) > 98+size {
+			ver, err := common.UnmarshalVersionedTransaction(data[99+size:])
+			if err != nil {
+				return nil, err
+			}
+			msg.Transaction = ver
+		}
+		msg.Type = PeerMessageTypeTransactionChallenge
 	case PeerMessageTypeSnapshotResponse:
 		if len(data[1:]) != 64 {
 			return nil, fmt.Errorf("invalid response message size %d", len(data[1:]))
@@ -251,6 +363,54 @@ func parseNetworkMessage(data []byte) (*PeerMessage, error) {
 		if err != nil {
 			return nil, err
 		}
+	case PeerMessageTypeCheckpointAnnouncement:
+		if len(data[1:]) <= 32 {
+			return nil, fmt.Errorf("invalid checkpoint announcement message size %d", len(data[1:]))
+		}
+		copy(msg.Commitment[:], data[1:])
+		err := common.MsgpackUnmarshal(data[33:], &msg.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
+	case PeerMessageTypeCheckpointCommitment:
+		if len(data[1:]) != 64 {
+			return nil, fmt.Errorf("invalid checkpoint commitment message size %d", len(data[1:]))
+		}
+		copy(msg.CheckpointHash[:], data[1:])
+		copy(msg.Commitment[:], data[33:])
+	case PeerMessageTypeCheckpointChallenge:
+		if len(data[1:]) != 104 {
+			return nil, fmt.Errorf("invalid checkpoint challenge message size %d", len(data[1:]))
+		}
+		copy(msg.CheckpointHash[:], data[1:])
+		copy(msg.Cosi.Signature[:], data[33:])
+		msg.Cosi.SetLegacyMask(binary.BigEndian.Uint64(data[97:105]))
+	case PeerMessageTypeCheckpointChallengeBitmap:
+		if len(data[1:]) < 98 {
+			return nil, fmt.Errorf("invalid checkpoint challenge message size %d", len(data[1:]))
+		}
+		size := int(binary.BigEndian.Uint16(data[97:99]))
+		if len(data[1:]) != 98+size {
+			return nil, fmt.Errorf("invalid checkpoint challenge message size %d %d", len(data[1:]), size)
+		}
+		copy(msg.CheckpointHash[:], data[1:])
+		copy(msg.Cosi.Signature[:], data[33:])
+		err := msg.Cosi.SetBitmap(data[99 : 99+size])
+		if err != nil {
+			return nil, err
+		}
+		msg.Type = PeerMessageTypeCheckpointChallenge
+	case PeerMessageTypeCheckpointResponse:
+		if len(data[1:]) != 64 {
+			return nil, fmt.Errorf("invalid checkpoint response message size %d", len(data[1:]))
+		}
+		copy(msg.CheckpointHash[:], data[1:])
+		copy(msg.Response[:], data[33:])
+	case PeerMessageTypeCheckpointFinalization:
+		err := common.MsgpackUnmarshal(data[1:], &msg.Checkpoint)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return msg, nil
 }
@@ -282,6 +442,16 @@ func (me *Peer) handlePeerMessage(peer *Peer, receive chan *PeerMessage, done ch
 				me.handle.CosiAggregateSelfResponses(peer.IdForNetwork, msg.SnapshotHash, &msg.Response)
 			case PeerMessageTypeSnapshotFinalization:
 				me.handle.VerifyAndQueueAppendSnapshotFinalization(peer.IdForNetwork, msg.Snapshot)
+			case PeerMessageTypeCheckpointAnnouncement:
+				me.handle.CheckpointQueueAnnouncement(peer.IdForNetwork, msg.Checkpoint, &msg.Commitment)
+			case PeerMessageTypeCheckpointCommitment:
+				me.handle.CheckpointAggregateCommitment(peer.IdForNetwork, msg.CheckpointHash, &msg.Commitment)
+			case PeerMessageTypeCheckpointChallenge:
+				me.handle.CheckpointQueueChallenge(peer.IdForNetwork, msg.CheckpointHash, &msg.Cosi)
+			case PeerMessageTypeCheckpointResponse:
+				me.handle.CheckpointAggregateResponse(peer.IdForNetwork, msg.CheckpointHash, &msg.Response)
+			case PeerMessageTypeCheckpointFinalization:
+				me.handle.CheckpointVerifyFinalization(peer.IdForNetwork, msg.Checkpoint)
 			}
 		}
 	}
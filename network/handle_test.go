@@ -0,0 +1,40 @@
+package network
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpointChallengeMessage(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, nodes := range []int{7, 100} {
+		hash := crypto.NewHash([]byte("checkpoint"))
+		cosi := &crypto.CosiSignature{}
+		rand.Read(cosi.Signature[:])
+		for i := 0; i < nodes-1; i += 2 {
+			assert.Nil(cosi.Mark(i))
+		}
+		assert.Nil(cosi.Mark(nodes - 1))
+
+		data := buildCheckpointChallengeMessage(hash, cosi)
+		if nodes > 64 {
+			assert.Equal(uint8(PeerMessageTypeCheckpointChallengeBitmap), data[0])
+		} else {
+			assert.Equal(uint8(PeerMessageTypeCheckpointChallenge), data[0])
+		}
+		msg, err := parseNetworkMessage(data)
+		assert.Nil(err)
+		assert.Equal(uint8(PeerMessageTypeCheckpointChallenge), msg.Type)
+		assert.Equal(hash, msg.CheckpointHash)
+		assert.Equal(cosi.Signature, msg.Cosi.Signature)
+		assert.Equal(cosi.Keys(), msg.Cosi.Keys())
+		assert.Equal(cosi.MaskVersion(), msg.Cosi.MaskVersion())
+
+		_, err = parseNetworkMessage(data[:len(data)-1])
+		assert.NotNil(err)
+	}
+}
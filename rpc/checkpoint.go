@@ -0,0 +1,41 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+func listCheckpoints(store storage.Store, params []interface{}) ([]map[string]interface{}, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	offset, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	checkpoints, err := store.ReadCheckpoints(offset, count)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, len(checkpoints))
+	for i, c := range checkpoints {
+		result[i] = map[string]interface{}{
+			"hash":      c.PayloadHash(),
+			"version":   c.Version,
+			"number":    c.Number,
+			"rounds":    c.Rounds,
+			"state":     c.State,
+			"timestamp": c.Timestamp,
+			"signature": c.Signature,
+		}
+	}
+	return result, nil
+}
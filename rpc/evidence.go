@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/light"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+func listEvidences(store storage.Store, node *kernel.Node, params []interface{}) ([]map[string]interface{}, error) {
+	if len(params) > 1 {
+		return nil, errors.New("invalid params count")
+	}
+	var nodeId crypto.Hash
+	if len(params) == 1 && fmt.Sprint(params[0]) != "" {
+		id, err := crypto.HashFromString(fmt.Sprint(params[0]))
+		if err != nil {
+			return nil, err
+		}
+		nodeId = id
+	}
+	evidences, err := store.ReadEvidences(nodeId)
+	if err != nil {
+		return nil, err
+	}
+	set, _, _, err := readNodeSet(store, node.NetworkId())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]map[string]interface{}, len(evidences))
+	for i, e := range evidences {
+		data := map[string]interface{}{
+			"hash":      e.Hash(),
+			"type":      e.Type,
+			"node":      e.NodeId,
+			"round":     e.Round,
+			"snapshots": e.Snapshots,
+			"timestamp": e.Timestamp,
+			"valid":     true,
+		}
+		if err := verifyEvidence(store, set, node.NetworkId(), e); err != nil {
+			data["valid"] = false
+			data["error"] = err.Error()
+		}
+		result[i] = data
+	}
+	return result, nil
+}
+
+// the evidence is verified with the node set at the snapshot timestamps, not
+// the current consensus nodes, which may have changed since the evidence
+func verifyEvidence(store storage.Store, set *light.NodeSet, networkId crypto.Hash, e *common.Evidence) error {
+	var signer *crypto.Key
+	for _, n := range store.ReadAllNodes() {
+		if n.IdForNetwork(networkId) == e.NodeId {
+			signer = &n.Signer.PublicSpendKey
+		}
+	}
+	if signer == nil {
+		return fmt.Errorf("evidence node not found %s", e.NodeId)
+	}
+	return e.Verify(func(timestamp uint64) ([]*crypto.Key, int) {
+		return set.ConsensusKeys(timestamp), set.ConsensusThreshold(timestamp)
+	}, *signer)
+}
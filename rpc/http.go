@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/kernel"
 	"github.com/MixinNetwork/mixin/storage"
 	"github.com/dimfeld/httptreemux"
@@ -16,6 +17,8 @@ import (
 type R struct {
 	Store storage.Store
 	Node  *kernel.Node
+
+	streams int32
 }
 
 type Call struct {
@@ -27,6 +30,7 @@ func NewRouter(store storage.Store, node *kernel.Node) *httptreemux.TreeMux {
 	router := httptreemux.New()
 	impl := &R{Store: store, Node: node}
 	router.POST("/", impl.handle)
+	router.GET("/stream/snapshots", impl.streamSnapshots)
 	registerHanders(router)
 	return router
 }
@@ -55,78 +59,150 @@ func (impl *R) handle(w http.ResponseWriter, r *http.Request, _ map[string]strin
 	case "getinfo":
 		info, err := getInfo(impl.Store, impl.Node)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": info})
 		}
 	case "sendrawtransaction":
 		id, err := queueTransaction(impl.Node, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": map[string]string{"hash": id}})
 		}
+	case "validaterawtransaction":
+		result, err := validateTransaction(impl.Store, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": result})
+		}
+	case "listpendingtransactions":
+		pending, err := listPendingTransactions(impl.Node, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": pending})
+		}
+	case "getmempoolinfo":
+		info, err := getMempoolInfo(impl.Node, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": info})
+		}
 	case "gettransaction":
 		tx, err := getTransaction(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": tx})
 		}
 	case "getutxo":
 		utxo, err := getUTXO(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": utxo})
 		}
+	case "getutxoproof":
+		proof, err := getUTXOProof(impl.Store, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": proof})
+		}
+	case "gettransactionproof":
+		proof, err := getTransactionProof(impl.Store, impl.Node, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": proof})
+		}
+	case "listnodeproofs":
+		proofs, err := listNodeProofs(impl.Store, impl.Node)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": proofs})
+		}
 	case "getsnapshot":
 		snap, err := getSnapshot(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": snap})
 		}
 	case "listsnapshots":
 		snapshots, err := listSnapshots(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": snapshots})
 		}
 	case "listmintdistributions":
 		distributions, err := listMintDistributions(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": distributions})
 		}
 	case "getroundbynumber":
 		round, err := getRoundByNumber(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": round})
 		}
 	case "getroundbyhash":
 		round, err := getRoundByHash(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": round})
 		}
+	case "getroundgraph":
+		graph, err := getRoundGraph(impl.Store, impl.Node, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": graph})
+		}
 	case "getroundlink":
 		link, err := getRoundLink(impl.Store, call.Params)
 		if err != nil {
-			render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": err.Error()})
+			renderError(w, err)
 		} else {
 			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": map[string]interface{}{"link": link}})
 		}
+	case "listevidences":
+		evidences, err := listEvidences(impl.Store, impl.Node, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": evidences})
+		}
+	case "listcheckpoints":
+		checkpoints, err := listCheckpoints(impl.Store, call.Params)
+		if err != nil {
+			renderError(w, err)
+		} else {
+			render.New().JSON(w, http.StatusOK, map[string]interface{}{"data": checkpoints})
+		}
 	default:
 		render.New().JSON(w, http.StatusOK, map[string]interface{}{"error": "invalid method"})
 	}
 }
 
+func renderError(w http.ResponseWriter, err error) {
+	body := map[string]interface{}{"error": err.Error()}
+	if ve, ok := err.(*common.ValidationError); ok {
+		body["code"] = ve.Code
+		body["details"] = ve
+	}
+	render.New().JSON(w, http.StatusOK, body)
+}
+
 func handleCORS(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		origin := r.Header.Get("Origin")
@@ -13,6 +13,7 @@ func getInfo(store storage.Store, node *kernel.Node) (map[string]interface{}, er
 		"network":   node.NetworkId(),
 		"node":      node.IdForNetwork,
 		"version":   config.BuildVersion,
+		"params":    config.CurrentNetworkParams(),
 		"uptime":    node.Uptime().String(),
 		"timestamp": time.Unix(0, int64(node.Graph.GraphTimestamp)),
 	}
@@ -70,6 +71,14 @@ func getInfo(store storage.Store, node *kernel.Node) (map[string]interface{}, er
 		"final":     finalGraph,
 		"topology":  node.TopologicalOrder(),
 	}
+	pruned, err := store.ReadPrunedTopology()
+	if err != nil {
+		return info, err
+	}
+	info["pruned"] = pruned > 0
+	if pruned > 0 {
+		info["pruned_topology"] = pruned
+	}
 	f, c, err := store.QueueInfo()
 	if err != nil {
 		return info, err
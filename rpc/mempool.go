@@ -0,0 +1,45 @@
+package rpc
+
+import (
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/MixinNetwork/mixin/kernel"
+)
+
+func listPendingTransactions(node *kernel.Node, params []interface{}) ([]map[string]interface{}, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	now := time.Now()
+	pending := node.Mempool.List(now)
+	result := make([]map[string]interface{}, len(pending))
+	for i, p := range pending {
+		data := transactionToMap(p.Transaction)
+		data["hex"] = hex.EncodeToString(p.Transaction.Marshal())
+		data["size"] = p.Size
+		data["timestamp"] = p.Timestamp.UnixNano()
+		data["age"] = now.Sub(p.Timestamp).String()
+		result[i] = data
+	}
+	return result, nil
+}
+
+func getMempoolInfo(node *kernel.Node, params []interface{}) (map[string]interface{}, error) {
+	if len(params) != 0 {
+		return nil, errors.New("invalid params count")
+	}
+	info := node.Mempool.Info(time.Now())
+	data := map[string]interface{}{
+		"count":    info.Count,
+		"inputs":   info.Inputs,
+		"size":     info.Size,
+		"ttl":      info.TTL.String(),
+		"capacity": kernel.MempoolCapacity,
+	}
+	if !info.Oldest.IsZero() {
+		data["oldest"] = info.Oldest.UnixNano()
+	}
+	return data, nil
+}
@@ -0,0 +1,146 @@
+package rpc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/kernel"
+	"github.com/MixinNetwork/mixin/light"
+	"github.com/MixinNetwork/mixin/storage"
+)
+
+func getTransactionProof(store storage.Store, node *kernel.Node, params []interface{}) (*light.TransactionProof, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	proof, _, err := readTransactionProof(store, hash)
+	if err != nil {
+		return nil, err
+	}
+	set, _, _, err := readNodeSet(store, node.NetworkId())
+	if err != nil {
+		return nil, err
+	}
+	proof.Keys, proof.Threshold = set.SnapshotConsensus(proof.Snapshot)
+	return proof, nil
+}
+
+func listNodeProofs(store storage.Store, node *kernel.Node) (map[string]interface{}, error) {
+	set, genesis, proofs, err := readNodeSet(store, node.NetworkId())
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]map[string]interface{}, 0)
+	for _, n := range genesis {
+		nodes = append(nodes, map[string]interface{}{
+			"node":        n.IdForNetwork(node.NetworkId()),
+			"signer":      n.Signer.String(),
+			"payee":       n.Payee.String(),
+			"timestamp":   n.Timestamp,
+			"transaction": n.Transaction.String(),
+		})
+	}
+	for _, p := range proofs {
+		p.Keys, p.Threshold = set.SnapshotConsensus(p.Snapshot)
+	}
+	return map[string]interface{}{
+		"network": node.NetworkId(),
+		"genesis": nodes,
+		"proofs":  proofs,
+	}, nil
+}
+
+// readNodeSet walks back from the latest transaction of each node to its
+// pledge or genesis, the cancelled pledges are not in the node list
+func readNodeSet(store storage.Store, networkId crypto.Hash) (*light.NodeSet, []*common.Node, []*light.TransactionProof, error) {
+	var genesis []*common.Node
+	var proofs []*light.TransactionProof
+	transactions := make(map[crypto.Hash]*common.VersionedTransaction)
+	for _, n := range store.ReadAllNodes() {
+		hash := n.Transaction
+		for transactions[hash] == nil {
+			proof, ver, err := readTransactionProof(store, hash)
+			if ver != nil && len(ver.Inputs[0].Genesis) > 0 {
+				// the genesis snapshots are not kept by the checkpoint bootstrap
+				var signer, payee crypto.Key
+				copy(signer[:], ver.Extra)
+				copy(payee[:], ver.Extra[len(signer):])
+				gn := &common.Node{
+					Signer:      nodeAddress(signer),
+					Payee:       nodeAddress(payee),
+					State:       common.NodeStateAccepted,
+					Transaction: hash,
+				}
+				if proof != nil {
+					gn.Timestamp = proof.Snapshot.Timestamp
+				}
+				transactions[hash] = ver
+				genesis = append(genesis, gn)
+				break
+			}
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			transactions[hash] = ver
+			proofs = append(proofs, proof)
+			if ver.TransactionType() == common.TransactionTypeNodePledge {
+				break
+			}
+			hash = ver.Inputs[0].Hash
+		}
+	}
+	sort.SliceStable(proofs, func(i, j int) bool {
+		return proofs[i].Snapshot.Timestamp < proofs[j].Snapshot.Timestamp
+	})
+
+	set := light.NewNodeSet(networkId, config.CurrentNetworkParams(), genesis)
+	for _, p := range proofs {
+		err := set.Update(transactions[p.Snapshot.Transaction], p.Snapshot.Timestamp)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return set, genesis, proofs, nil
+}
+
+func readTransactionProof(store storage.Store, hash crypto.Hash) (*light.TransactionProof, *common.VersionedTransaction, error) {
+	tx, final, err := store.ReadTransaction(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if tx == nil || len(final) == 0 {
+		return nil, tx, fmt.Errorf("transaction %s not finalized", hash)
+	}
+	if final == "MISSING" {
+		return nil, tx, fmt.Errorf("transaction %s snapshot not found", hash)
+	}
+	sh, err := crypto.HashFromString(final)
+	if err != nil {
+		return nil, nil, err
+	}
+	snap, err := store.ReadSnapshot(sh)
+	if err != nil {
+		return nil, tx, err
+	}
+	if snap == nil {
+		return nil, tx, fmt.Errorf("transaction %s snapshot %s not found", hash, final)
+	}
+	return light.NewTransactionProof(&snap.Snapshot, tx), tx, nil
+}
+
+func nodeAddress(publicSpend crypto.Key) common.Address {
+	privateView := publicSpend.DeterministicHashDerive()
+	return common.Address{
+		PrivateViewKey: privateView,
+		PublicViewKey:  privateView.Public(),
+		PublicSpendKey: publicSpend,
+	}
+}
@@ -46,6 +46,7 @@ func getRoundByNumber(store storage.Store, params []interface{}) (map[string]int
 	start := head.Timestamp
 	end := head.Timestamp
 	references := head.References
+	var root crypto.Hash
 
 	snapshots, err := store.ReadSnapshotsForNodeRound(node, number)
 	if err != nil {
@@ -69,6 +70,7 @@ func getRoundByNumber(store storage.Store, params []interface{}) (map[string]int
 			return nil, fmt.Errorf("round malformed %s:%d:%d %s:%d:%d", node, number, start, round.NodeId, round.Number, round.Timestamp)
 		}
 		references = round.References
+		root = round.UTXORoot
 	} else {
 		return nil, fmt.Errorf("round not found")
 	}
@@ -79,6 +81,7 @@ func getRoundByNumber(store storage.Store, params []interface{}) (map[string]int
 		"end":        end,
 		"number":     number,
 		"references": references,
+		"utxo_root":  root,
 		"snapshots":  snapshotsToMap(snapshots, nil, false),
 	}, nil
 }
@@ -126,6 +129,37 @@ func getRoundByHash(store storage.Store, params []interface{}) (map[string]inter
 		"end":        end,
 		"number":     round.Number,
 		"references": round.References,
+		"utxo_root":  round.UTXORoot,
 		"snapshots":  snapshotsToMap(snapshots, nil, false),
 	}, nil
 }
+
+func getRoundGraph(store storage.Store, node *kernel.Node, params []interface{}) (interface{}, error) {
+	if len(params) != 4 {
+		return nil, errors.New("invalid params count")
+	}
+	from, err := strconv.ParseUint(fmt.Sprint(params[0]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	to, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	byTime, err := strconv.ParseBool(fmt.Sprint(params[2]))
+	if err != nil {
+		return nil, err
+	}
+	graph, err := kernel.ExportRoundGraph(store, node.NetworkId(), from, to, byTime)
+	if err != nil {
+		return nil, err
+	}
+	switch format := fmt.Sprint(params[3]); format {
+	case "json":
+		return graph, nil
+	case "dot":
+		return map[string]interface{}{"dot": graph.DOT()}, nil
+	default:
+		return nil, fmt.Errorf("invalid round graph format %s", format)
+	}
+}
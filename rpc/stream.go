@@ -0,0 +1,166 @@
+package rpc
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/storage"
+	"github.com/unrolled/render"
+)
+
+const (
+	streamBatchSize    = 100
+	streamSubscribers  = 64
+	streamWriteTimeout = 10 * time.Second
+	streamHeartbeat    = 30 * time.Second
+)
+
+type snapshotFilter struct {
+	asset *crypto.Hash
+	types map[uint8]bool
+}
+
+// streamSnapshots writes the finalized snapshots with their transactions as
+// newline delimited JSON in a chunked response, each subscriber reads from
+// the store at its own pace, so a slow one never blocks the kernel or others
+func (impl *R) streamSnapshots(w http.ResponseWriter, r *http.Request, _ map[string]string) {
+	offset, filter, err := parseStreamQuery(r, impl.Store.TopologySequence())
+	if err != nil {
+		render.New().JSON(w, http.StatusBadRequest, map[string]interface{}{"error": err.Error()})
+		return
+	}
+	if atomic.AddInt32(&impl.streams, 1) > streamSubscribers {
+		atomic.AddInt32(&impl.streams, -1)
+		render.New().JSON(w, http.StatusServiceUnavailable, map[string]interface{}{"error": "too many subscribers"})
+		return
+	}
+	defer atomic.AddInt32(&impl.streams, -1)
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		render.New().JSON(w, http.StatusInternalServerError, map[string]interface{}{"error": "streaming unsupported"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Transfer-Encoding", "chunked")
+	w.Header().Set("Connection", "close")
+	header := w.Header()
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	// the server write timeout is for the short calls, so it's reset for
+	// every write here and a subscriber too slow to read is disconnected
+	conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+	buf.WriteString("HTTP/1.1 200 OK\r\n")
+	header.Write(buf)
+	buf.WriteString("\r\n")
+	cw := httputil.NewChunkedWriter(buf)
+	err = streamSnapshotsSince(impl.Store, conn, buf, cw, offset, filter)
+	if err == nil {
+		cw.Close()
+		buf.WriteString("\r\n")
+		buf.Flush()
+	}
+}
+
+func streamSnapshotsSince(store storage.Store, conn net.Conn, buf *bufio.ReadWriter, cw io.Writer, offset uint64, filter *snapshotFilter) error {
+	enc := json.NewEncoder(cw)
+	for {
+		notify := store.SnapshotsNotify()
+		snapshots, transactions, err := store.ReadSnapshotWithTransactionsSinceTopology(offset, streamBatchSize)
+		if err != nil {
+			return enc.Encode(map[string]interface{}{"error": err.Error()})
+		}
+		conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		for i, s := range snapshots {
+			offset = s.TopologicalOrder + 1
+			if !filter.match(transactions[i]) {
+				continue
+			}
+			err := enc.Encode(snapshotToMap(s, transactions[i], true))
+			if err != nil {
+				return err
+			}
+		}
+		err = buf.Flush()
+		if err != nil {
+			return err
+		}
+		if len(snapshots) == streamBatchSize {
+			continue
+		}
+
+		select {
+		case <-notify:
+		case <-time.After(streamHeartbeat):
+			conn.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+			_, err := cw.Write([]byte("\n"))
+			if err != nil {
+				return err
+			}
+			err = buf.Flush()
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func parseStreamQuery(r *http.Request, sequence uint64) (uint64, *snapshotFilter, error) {
+	query := r.URL.Query()
+	offset := sequence
+	if since := query.Get("since"); since != "" {
+		o, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return 0, nil, err
+		}
+		offset = o
+	}
+
+	filter := &snapshotFilter{types: make(map[uint8]bool)}
+	if asset := query.Get("asset"); asset != "" {
+		a, err := crypto.HashFromString(asset)
+		if err != nil {
+			return 0, nil, err
+		}
+		filter.asset = &a
+	}
+	if types := query.Get("type"); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			n, err := strconv.ParseUint(strings.TrimSpace(t), 10, 8)
+			if err != nil {
+				return 0, nil, fmt.Errorf("invalid transaction type %s", t)
+			}
+			filter.types[uint8(n)] = true
+		}
+	}
+	return offset, filter, nil
+}
+
+func (f *snapshotFilter) match(tx *common.VersionedTransaction) bool {
+	if f.asset == nil && len(f.types) == 0 {
+		return true
+	}
+	if tx == nil {
+		return false
+	}
+	if f.asset != nil && tx.Asset != *f.asset {
+		return false
+	}
+	return len(f.types) == 0 || f.types[tx.TransactionType()]
+}
@@ -0,0 +1,86 @@
+package rpc
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseStreamQuery(t *testing.T) {
+	assert := assert.New(t)
+
+	offset, filter, err := parseStreamQuery(httptest.NewRequest("GET", "/stream", nil), 100)
+	assert.Nil(err)
+	assert.Equal(uint64(100), offset)
+	assert.Nil(filter.asset)
+	assert.Len(filter.types, 0)
+
+	offset, _, err = parseStreamQuery(httptest.NewRequest("GET", "/stream?since=0", nil), 100)
+	assert.Nil(err)
+	assert.Equal(uint64(0), offset)
+	offset, _, err = parseStreamQuery(httptest.NewRequest("GET", "/stream?since=12", nil), 100)
+	assert.Nil(err)
+	assert.Equal(uint64(12), offset)
+	offset, _, err = parseStreamQuery(httptest.NewRequest("GET", "/stream?since=120", nil), 100)
+	assert.Nil(err)
+	assert.Equal(uint64(120), offset)
+	_, _, err = parseStreamQuery(httptest.NewRequest("GET", "/stream?since=-1", nil), 100)
+	assert.NotNil(err)
+
+	url := "/stream?asset=" + common.XINAssetId.String() + "&type=0,%2012"
+	offset, filter, err = parseStreamQuery(httptest.NewRequest("GET", url, nil), 100)
+	assert.Nil(err)
+	assert.Equal(uint64(100), offset)
+	assert.Equal(common.XINAssetId, *filter.asset)
+	assert.Len(filter.types, 2)
+	assert.True(filter.types[common.TransactionTypeScript])
+	assert.True(filter.types[12])
+
+	_, _, err = parseStreamQuery(httptest.NewRequest("GET", "/stream?asset=xin", nil), 100)
+	assert.NotNil(err)
+	_, _, err = parseStreamQuery(httptest.NewRequest("GET", "/stream?type=256", nil), 100)
+	assert.NotNil(err)
+	_, _, err = parseStreamQuery(httptest.NewRequest("GET", "/stream?type=script", nil), 100)
+	assert.NotNil(err)
+}
+
+func TestSnapshotFilterMatch(t *testing.T) {
+	assert := assert.New(t)
+
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.AddInput(crypto.NewHash([]byte("input")), 0)
+	script := tx.AsLatestVersion()
+	other := crypto.NewHash([]byte("other"))
+	tx = common.NewTransaction(other)
+	tx.AddInput(crypto.NewHash([]byte("input")), 0)
+	asset := tx.AsLatestVersion()
+	tx = common.NewTransaction(common.XINAssetId)
+	tx.AddDepositInput(&common.DepositData{Chain: common.XINAssetId})
+	deposit := tx.AsLatestVersion()
+
+	filter := &snapshotFilter{types: make(map[uint8]bool)}
+	assert.True(filter.match(script))
+	assert.True(filter.match(asset))
+	assert.True(filter.match(nil))
+
+	filter.asset = &common.XINAssetId
+	assert.True(filter.match(script))
+	assert.True(filter.match(deposit))
+	assert.False(filter.match(asset))
+	assert.False(filter.match(nil))
+
+	filter.types[common.TransactionTypeDeposit] = true
+	assert.False(filter.match(script))
+	assert.True(filter.match(deposit))
+	assert.False(filter.match(asset))
+
+	filter.asset = nil
+	filter.types[common.TransactionTypeScript] = true
+	assert.True(filter.match(script))
+	assert.True(filter.match(deposit))
+	assert.True(filter.match(asset))
+	assert.False(filter.match(nil))
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"time"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -27,6 +28,81 @@ func queueTransaction(node *kernel.Node, params []interface{}) (string, error) {
 	return node.QueueTransaction(ver)
 }
 
+func validateTransaction(store storage.Store, params []interface{}) (map[string]interface{}, error) {
+	if len(params) != 1 {
+		return nil, errors.New("invalid params count")
+	}
+	raw, err := hex.DecodeString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	ver, err := common.UnmarshalVersionedTransaction(raw)
+	if err != nil {
+		return nil, err
+	}
+	hash := ver.PayloadHash()
+
+	var inputs, outputs []common.Integer
+	conflicts := make([]map[string]interface{}, 0)
+	for _, in := range ver.Inputs {
+		if in.Deposit != nil {
+			inputs = append(inputs, in.Deposit.Amount)
+			continue
+		}
+		if in.Mint != nil {
+			inputs = append(inputs, in.Mint.Amount)
+			continue
+		}
+		utxo, err := store.ReadUTXO(in.Hash, in.Index)
+		if err != nil {
+			return nil, err
+		}
+		if utxo == nil {
+			continue
+		}
+		inputs = append(inputs, utxo.Amount)
+		if utxo.LockHash.HasValue() && utxo.LockHash != hash {
+			conflicts = append(conflicts, map[string]interface{}{
+				"hash":  in.Hash,
+				"index": in.Index,
+				"lock":  utxo.LockHash,
+			})
+		}
+	}
+	for _, out := range ver.Outputs {
+		outputs = append(outputs, out.Amount)
+	}
+
+	result := map[string]interface{}{
+		"hash":      hash,
+		"type":      ver.TransactionType(),
+		"inputs":    sumAmounts(inputs),
+		"outputs":   sumAmounts(outputs),
+		"conflicts": conflicts,
+		"valid":     true,
+	}
+	err = ver.Validate(store, uint64(time.Now().UnixNano()))
+	if err != nil {
+		result["valid"] = false
+		result["error"] = err.Error()
+		if ve, ok := err.(*common.ValidationError); ok {
+			result["code"] = ve.Code
+			result["details"] = ve
+		}
+	}
+	return result, nil
+}
+
+func sumAmounts(amounts []common.Integer) common.Integer {
+	sum := common.NewInteger(0)
+	for _, a := range amounts {
+		if a.Sign() > 0 {
+			sum = sum.Add(a)
+		}
+	}
+	return sum
+}
+
 func getTransaction(store storage.Store, params []interface{}) (map[string]interface{}, error) {
 	if len(params) != 1 {
 		return nil, errors.New("invalid params count")
@@ -63,11 +139,14 @@ func getUTXO(store storage.Store, params []interface{}) (map[string]interface{},
 	if err != nil || utxo == nil {
 		return nil, err
 	}
+	return utxoToMap(utxo), nil
+}
 
+func utxoToMap(utxo *common.UTXOWithLock) map[string]interface{} {
 	output := map[string]interface{}{
 		"type":   utxo.Type,
-		"hash":   hash,
-		"index":  index,
+		"hash":   utxo.Hash,
+		"index":  utxo.Index,
 		"amount": utxo.Amount,
 	}
 	if len(utxo.Keys) > 0 {
@@ -82,6 +161,47 @@ func getUTXO(store storage.Store, params []interface{}) (map[string]interface{},
 	if utxo.LockHash.HasValue() {
 		output["lock"] = utxo.LockHash
 	}
+	return output
+}
+
+func getUTXOProof(store storage.Store, params []interface{}) (map[string]interface{}, error) {
+	if len(params) != 2 {
+		return nil, errors.New("invalid params count")
+	}
+	hash, err := crypto.HashFromString(fmt.Sprint(params[0]))
+	if err != nil {
+		return nil, err
+	}
+	index, err := strconv.ParseUint(fmt.Sprint(params[1]), 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	proof, checkpoint, err := store.ReadUTXOProof(hash, int(index))
+	if err != nil {
+		return nil, err
+	}
+	if checkpoint == nil {
+		return nil, errors.New("utxo proof checkpoint not ready")
+	}
+
+	included := proof.Leaf != nil && proof.Leaf.Key == proof.Key
+	output := map[string]interface{}{
+		"checkpoint": checkpoint.Number,
+		"root":       checkpoint.State.Root,
+		"key":        proof.Key,
+		"included":   included,
+		"siblings":   proof.Siblings,
+	}
+	if proof.Leaf != nil {
+		output["leaf"] = proof.Leaf
+	}
+	if included {
+		utxo, err := store.ReadUTXO(hash, int(index))
+		if err != nil {
+			return nil, err
+		}
+		output["utxo"] = utxoToMap(utxo)
+	}
 	return output, nil
 }
 
@@ -157,7 +277,9 @@ func snapshotToMap(s *common.SnapshotWithTopologicalOrder, tx *common.VersionedT
 		"topology":   s.TopologicalOrder,
 	}
 	if tx != nil {
-		item["transaction"] = transactionToMap(tx)
+		data := transactionToMap(tx)
+		data["hex"] = hex.EncodeToString(tx.Marshal())
+		item["transaction"] = data
 	} else {
 		item["transaction"] = s.Transaction
 	}
@@ -174,10 +296,14 @@ func transactionToMap(tx *common.VersionedTransaction) map[string]interface{} {
 	var inputs []map[string]interface{}
 	for _, in := range tx.Inputs {
 		if in.Hash.HasValue() {
-			inputs = append(inputs, map[string]interface{}{
+			input := map[string]interface{}{
 				"hash":  in.Hash,
 				"index": in.Index,
-			})
+			}
+			if len(in.Preimage) > 0 {
+				input["preimage"] = hex.EncodeToString(in.Preimage)
+			}
+			inputs = append(inputs, input)
 		} else if len(in.Genesis) > 0 {
 			inputs = append(inputs, map[string]interface{}{
 				"genesis": hex.EncodeToString(in.Genesis),
@@ -205,6 +331,15 @@ func transactionToMap(tx *common.VersionedTransaction) map[string]interface{} {
 		if len(out.Script) > 0 {
 			output["script"] = out.Script
 		}
+		if lock := out.Script.Locktime(); lock > 0 {
+			output["locktime"] = lock
+		}
+		if out.Script.IsHashLock() {
+			lock, refund, recipients := out.Script.HashLock()
+			output["hashlock"] = lock
+			output["locktime"] = refund
+			output["recipients"] = recipients
+		}
 		if out.Mask.HasValue() {
 			output["mask"] = out.Mask
 		}
@@ -1,6 +1,7 @@
 package storage
 
 import (
+	"sync"
 	"time"
 
 	"github.com/dgraph-io/badger"
@@ -12,6 +13,9 @@ type BadgerStore struct {
 	stateDB     *badger.DB
 	queue       *Queue
 	closing     bool
+
+	notifyMutex     sync.Mutex
+	snapshotsNotify chan struct{}
 }
 
 func NewBadgerStore(dir string) (*BadgerStore, error) {
@@ -27,13 +31,16 @@ func NewBadgerStore(dir string) (*BadgerStore, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &BadgerStore{
+	store := &BadgerStore{
 		snapshotsDB: snapshotsDB,
 		cacheDB:     cacheDB,
 		stateDB:     stateDB,
 		queue:       NewQueue(),
 		closing:     false,
-	}, nil
+
+		snapshotsNotify: make(chan struct{}),
+	}
+	return store, store.loadUTXOTree()
 }
 
 func (store *BadgerStore) Close() error {
@@ -50,6 +57,50 @@ func (store *BadgerStore) Close() error {
 	return store.cacheDB.Close()
 }
 
+// batchWriter commits the transaction and continues with a new one when it's
+// too big, so it's only for the large writes which need no atomicity
+type batchWriter struct {
+	db  *badger.DB
+	txn *badger.Txn
+}
+
+func (w *batchWriter) Get(key []byte) (*badger.Item, error) {
+	return w.txn.Get(key)
+}
+
+func (w *batchWriter) Set(key, val []byte) error {
+	err := w.txn.Set(key, val)
+	if err != badger.ErrTxnTooBig {
+		return err
+	}
+	err = w.Commit()
+	if err != nil {
+		return err
+	}
+	return w.txn.Set(key, val)
+}
+
+func (w *batchWriter) Delete(key []byte) error {
+	err := w.txn.Delete(key)
+	if err != badger.ErrTxnTooBig {
+		return err
+	}
+	err = w.Commit()
+	if err != nil {
+		return err
+	}
+	return w.txn.Delete(key)
+}
+
+func (w *batchWriter) Commit() error {
+	err := w.txn.Commit()
+	if err != nil {
+		return err
+	}
+	w.txn = w.db.NewTransaction(true)
+	return nil
+}
+
 func openDB(dir string, sync bool) (*badger.DB, error) {
 	opts := badger.DefaultOptions(dir)
 	opts.SyncWrites = sync
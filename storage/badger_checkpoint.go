@@ -0,0 +1,937 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/config"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	graphPrefixCheckpoint = "CHECKPOINT"
+)
+
+type checkpointNode struct {
+	Signer      crypto.Key
+	Payee       crypto.Key
+	State       string
+	Transaction crypto.Hash
+	Timestamp   uint64
+}
+
+type checkpointDomain struct {
+	Signer      crypto.Key
+	Transaction crypto.Hash
+	Timestamp   uint64
+}
+
+type checkpointDeposit struct {
+	Key         crypto.Hash
+	Transaction crypto.Hash
+}
+
+// the checkpoint archive is a sequence of entries with only one field set, the
+// state entries are in the same order as the digests, then the recent rounds
+type checkpointEntry struct {
+	Network     *crypto.Hash                         `msgpack:",omitempty"`
+	Checkpoint  *common.Checkpoint                   `msgpack:",omitempty"`
+	Output      *common.UTXOWithLock                 `msgpack:",omitempty"`
+	Deposit     *checkpointDeposit                   `msgpack:",omitempty"`
+	Node        *checkpointNode                      `msgpack:",omitempty"`
+	Domain      *checkpointDomain                    `msgpack:",omitempty"`
+	Mint        *common.MintDistribution             `msgpack:",omitempty"`
+	Transaction []byte                               `msgpack:",omitempty"`
+	Snapshot    *common.SnapshotWithTopologicalOrder `msgpack:",omitempty"`
+}
+
+func (s *BadgerStore) WriteCheckpoint(c *common.Checkpoint) error {
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	old, err := readCheckpoint(txn, c.Number)
+	if err != nil || old != nil {
+		return err
+	}
+	err = txn.Set(graphCheckpointKey(c.Number), common.MsgpackMarshalPanic(c))
+	if err != nil {
+		return err
+	}
+	err = txn.Commit()
+	if err != nil {
+		return err
+	}
+
+	// the proofs stay with the previous checkpoint if the rounds are not final
+	err = s.writeCheckpointUTXOTree(c)
+	if err != nil {
+		logger.Println("writeCheckpointUTXOTree", c.Number, err)
+	}
+	return nil
+}
+
+func (s *BadgerStore) ReadCheckpoint(number uint64) (*common.Checkpoint, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	return readCheckpoint(txn, number)
+}
+
+func (s *BadgerStore) ReadLastCheckpoint() (*common.Checkpoint, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	opts := badger.DefaultIteratorOptions
+	opts.Reverse = true
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	it.Seek(graphCheckpointKey(^uint64(0)))
+	if !it.ValidForPrefix([]byte(graphPrefixCheckpoint)) {
+		return nil, nil
+	}
+	val, err := it.Item().ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var c common.Checkpoint
+	err = common.MsgpackUnmarshal(val, &c)
+	return &c, err
+}
+
+func (s *BadgerStore) ReadCheckpoints(offset, count uint64) ([]*common.Checkpoint, error) {
+	checkpoints := make([]*common.Checkpoint, 0)
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte(graphPrefixCheckpoint)
+	for it.Seek(graphCheckpointKey(offset)); it.ValidForPrefix(prefix) && uint64(len(checkpoints)) < count; it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return checkpoints, err
+		}
+		var c common.Checkpoint
+		err = common.MsgpackUnmarshal(val, &c)
+		if err != nil {
+			return checkpoints, err
+		}
+		checkpoints = append(checkpoints, &c)
+	}
+	return checkpoints, nil
+}
+
+func (s *BadgerStore) ComputeCheckpointState(networkId crypto.Hash, c *common.Checkpoint) (*common.CheckpointState, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	state, err := walkCheckpointState(txn, networkId, c, func(e *checkpointEntry) error {
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	tree, err := rewindUTXOTree(txn, c)
+	if err != nil {
+		return nil, err
+	}
+	state.Root, err = readUTXOTreeRoot(tree)
+	return state, err
+}
+
+// ExportCheckpoint writes the archive of a finalized checkpoint, the archive is
+// computed by rewinding the current graph to the checkpoint rounds, so it fails
+// if the graph is pruned after the last rounds to the checkpoint
+func (s *BadgerStore) ExportCheckpoint(networkId crypto.Hash, number uint64, w io.Writer) error {
+	pruned, err := s.ReadPrunedTopology()
+	if err != nil {
+		return err
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	c, err := readCheckpoint(txn, number)
+	if err != nil {
+		return err
+	}
+	if c == nil {
+		return fmt.Errorf("checkpoint %d not found", number)
+	}
+	err = checkCheckpointPruned(txn, c, pruned)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	err = writeCheckpointEntry(bw, &checkpointEntry{Network: &networkId})
+	if err != nil {
+		return err
+	}
+	err = writeCheckpointEntry(bw, &checkpointEntry{Checkpoint: c})
+	if err != nil {
+		return err
+	}
+
+	writeTx := func(hash crypto.Hash) error {
+		ver, err := readTransaction(txn, hash)
+		if err != nil {
+			return err
+		}
+		if ver == nil && pruned > 0 {
+			return fmt.Errorf("checkpoint transaction %s pruned at topology %d", hash, pruned)
+		}
+		if ver == nil {
+			return fmt.Errorf("checkpoint transaction %s not found", hash)
+		}
+		return writeCheckpointEntry(bw, &checkpointEntry{Transaction: ver.Marshal()})
+	}
+	state, err := walkCheckpointState(txn, networkId, c, func(e *checkpointEntry) error {
+		err := writeCheckpointEntry(bw, e)
+		if err != nil {
+			return err
+		}
+		if e.Node != nil {
+			return writeTx(e.Node.Transaction)
+		}
+		if e.Domain != nil {
+			return writeTx(e.Domain.Transaction)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	state.Root = c.State.Root
+	if *state != c.State {
+		return fmt.Errorf("checkpoint %d state not match", number)
+	}
+
+	for _, r := range c.Rounds {
+		start := uint64(0)
+		if r.Number > config.SnapshotReferenceThreshold {
+			start = r.Number - config.SnapshotReferenceThreshold
+		}
+		for n := start; n <= r.Number; n++ {
+			snapshots, err := readSnapshotsForNodeRound(txn, r.NodeId, n)
+			if err != nil {
+				return err
+			}
+			for _, s := range snapshots {
+				err = writeTx(s.Transaction)
+				if err != nil {
+					return err
+				}
+				err = writeCheckpointEntry(bw, &checkpointEntry{Snapshot: s})
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// the state is rewound from the snapshots after the checkpoint rounds, since
+// the topological order of a node is increasing with the rounds, only the next
+// round to the checkpoint round is checked
+func checkCheckpointPruned(txn *badger.Txn, c *common.Checkpoint, pruned uint64) error {
+	if pruned == 0 {
+		return nil
+	}
+	for _, r := range c.Rounds {
+		snapshots, err := readSnapshotsForNodeRound(txn, r.NodeId, r.Number+1)
+		if err != nil {
+			return err
+		}
+		for _, s := range snapshots {
+			if s.TopologicalOrder < pruned {
+				return fmt.Errorf("checkpoint %d rounds pruned at topology %d", c.Number, pruned)
+			}
+		}
+	}
+	return nil
+}
+
+// ImportCheckpoint verifies the archive with the checkpoint state and rounds,
+// then verify checks the checkpoint itself, and the archive is written to the
+// empty store only after all the checks pass, except the UTXO root which is
+// verified with the written tree before the checkpoint itself is written
+func (s *BadgerStore) ImportCheckpoint(path string, verify func(networkId crypto.Hash, c *common.Checkpoint, nodes []*common.Node) error) (*common.Checkpoint, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	loaded := checkGenesisLoad(txn)
+	txn.Discard()
+	if loaded {
+		return nil, fmt.Errorf("checkpoint import to a non empty store")
+	}
+
+	networkId, c, err := verifyCheckpointArchive(path, verify)
+	if err != nil {
+		return nil, err
+	}
+	err = s.writeCheckpointArchive(path, networkId, c)
+	if err != nil {
+		return nil, err
+	}
+	return c, s.writeCheckpointUTXOTree(c)
+}
+
+func verifyCheckpointArchive(path string, verify func(networkId crypto.Hash, c *common.Checkpoint, nodes []*common.Node) error) (crypto.Hash, *common.Checkpoint, error) {
+	var networkId crypto.Hash
+	f, err := os.Open(path)
+	if err != nil {
+		return networkId, nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	network, c, err := readCheckpointHeader(r)
+	if err != nil {
+		return networkId, nil, err
+	}
+	networkId = *network
+
+	var state common.CheckpointState
+	var nodes []*common.Node
+	required := make(map[crypto.Hash]bool)
+	transactions := make(map[crypto.Hash]*common.VersionedTransaction)
+	snapshots := make(map[crypto.Hash]map[uint64][]*common.SnapshotWithTopologicalOrder)
+	for {
+		e, err := readCheckpointEntry(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return networkId, nil, err
+		}
+		switch {
+		case e.Output != nil:
+			state.UTXO = checkpointDigest(state.UTXO, e.Output)
+		case e.Deposit != nil:
+			state.UTXO = checkpointDigest(state.UTXO, e.Deposit)
+		case e.Node != nil:
+			state.Nodes = checkpointDigest(state.Nodes, e.Node)
+			required[e.Node.Transaction] = true
+			nodes = append(nodes, &common.Node{
+				Signer:      checkpointAddress(e.Node.Signer),
+				Payee:       checkpointAddress(e.Node.Payee),
+				State:       e.Node.State,
+				Transaction: e.Node.Transaction,
+				Timestamp:   e.Node.Timestamp,
+			})
+		case e.Domain != nil:
+			state.Domains = checkpointDigest(state.Domains, e.Domain)
+			required[e.Domain.Transaction] = true
+		case e.Mint != nil:
+			state.Mint = checkpointDigest(state.Mint, e.Mint)
+		case e.Transaction != nil:
+			ver, err := common.UnmarshalVersionedTransaction(e.Transaction)
+			if err != nil {
+				return networkId, nil, err
+			}
+			transactions[ver.PayloadHash()] = ver
+		case e.Snapshot != nil:
+			s := e.Snapshot
+			s.Hash = s.PayloadHash()
+			required[s.Transaction] = true
+			if snapshots[s.NodeId] == nil {
+				snapshots[s.NodeId] = make(map[uint64][]*common.SnapshotWithTopologicalOrder)
+			}
+			snapshots[s.NodeId][s.RoundNumber] = append(snapshots[s.NodeId][s.RoundNumber], s)
+		default:
+			return networkId, nil, fmt.Errorf("invalid checkpoint entry")
+		}
+	}
+
+	state.Root = c.State.Root
+	if state != c.State {
+		return networkId, nil, fmt.Errorf("checkpoint state not match")
+	}
+	for hash := range required {
+		if transactions[hash] == nil {
+			return networkId, nil, fmt.Errorf("checkpoint transaction %s not found", hash)
+		}
+	}
+	for _, cr := range c.Rounds {
+		err := verifyCheckpointRounds(cr, snapshots[cr.NodeId])
+		if err != nil {
+			return networkId, nil, err
+		}
+		delete(snapshots, cr.NodeId)
+	}
+	if len(snapshots) > 0 {
+		return networkId, nil, fmt.Errorf("checkpoint snapshots without rounds %d", len(snapshots))
+	}
+	return networkId, c, verify(networkId, c, nodes)
+}
+
+// all the recent rounds must be chained to the checkpoint round hash
+func verifyCheckpointRounds(cr *common.CheckpointRound, rounds map[uint64][]*common.SnapshotWithTopologicalOrder) error {
+	if len(rounds[cr.Number]) == 0 || len(rounds[cr.Number-1]) == 0 {
+		return fmt.Errorf("checkpoint rounds not found %s %d", cr.NodeId, cr.Number)
+	}
+	hash, verified := cr.Hash, 0
+	for n := cr.Number; len(rounds[n]) > 0; n-- {
+		snapshots := rounds[n]
+		sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Timestamp < snapshots[j].Timestamp })
+		start, end := snapshots[0].Timestamp, snapshots[len(snapshots)-1].Timestamp
+		if end >= start+config.SnapshotRoundGap {
+			return fmt.Errorf("checkpoint round %s %d gap %d %d", cr.NodeId, n, start, end)
+		}
+		if _, _, h := computeRoundHash(cr.NodeId, n, snapshots); h != hash {
+			return fmt.Errorf("checkpoint round %s %d hash %s %s", cr.NodeId, n, h, hash)
+		}
+		verified++
+		if n == 0 {
+			break
+		}
+		references := snapshots[0].References
+		for _, s := range snapshots {
+			if s.References == nil || !s.References.Equal(references) {
+				return fmt.Errorf("checkpoint round %s %d references", cr.NodeId, n)
+			}
+		}
+		hash = references.Self
+	}
+	if verified != len(rounds) {
+		return fmt.Errorf("checkpoint rounds %s %d not chained %d %d", cr.NodeId, cr.Number, verified, len(rounds))
+	}
+	return nil
+}
+
+func (s *BadgerStore) writeCheckpointArchive(path string, networkId crypto.Hash, c *common.Checkpoint) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	_, _, err = readCheckpointHeader(r)
+	if err != nil {
+		return err
+	}
+
+	w := &batchWriter{db: s.snapshotsDB, txn: s.snapshotsDB.NewTransaction(true)}
+	defer func() { w.txn.Discard() }()
+
+	var snapshots []*common.SnapshotWithTopologicalOrder
+	for {
+		e, err := readCheckpointEntry(r)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		switch {
+		case e.Output != nil:
+			err = writeCheckpointOutput(w, e.Output)
+		case e.Deposit != nil:
+			err = w.Set(append([]byte(graphPrefixDeposit), e.Deposit.Key[:]...), e.Deposit.Transaction[:])
+		case e.Node != nil:
+			err = writeCheckpointNode(w, e.Node)
+		case e.Domain != nil:
+			err = w.Set(graphDomainAcceptKey(e.Domain.Signer), checkpointNodeValue(e.Domain.Transaction, e.Domain.Timestamp))
+		case e.Mint != nil:
+			err = w.Set(graphMintKey(e.Mint.Group, e.Mint.Batch), common.MsgpackMarshalPanic(e.Mint))
+			if err == nil {
+				err = w.Set(graphFinalizationKey(e.Mint.Transaction), []byte{})
+			}
+		case e.Transaction != nil:
+			ver, err := common.UnmarshalVersionedTransaction(e.Transaction)
+			if err != nil {
+				return err
+			}
+			err = w.Set(graphTransactionKey(ver.PayloadHash()), ver.CompressMarshal())
+		case e.Snapshot != nil:
+			snapshots = append(snapshots, e.Snapshot)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	err = w.Commit()
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool { return snapshots[i].Timestamp < snapshots[j].Timestamp })
+	rounds := make(map[crypto.Hash]*common.Round)
+	for i, snap := range snapshots {
+		snap.Hash = snap.PayloadHash()
+		snap.TopologicalOrder = uint64(i)
+		err = w.txn.Set(graphFinalizationKey(snap.Transaction), snap.Hash[:])
+		if err != nil {
+			return err
+		}
+		ver, err := readTransaction(w.txn, snap.Transaction)
+		if err != nil {
+			return err
+		}
+		err = writeSnapshot(w.txn, snap, ver)
+		if err != nil {
+			return err
+		}
+		key := fmt.Sprintf("%s:%d", snap.NodeId, snap.RoundNumber)
+		round := rounds[crypto.NewHash([]byte(key))]
+		if round == nil {
+			round = &common.Round{NodeId: snap.NodeId, Number: snap.RoundNumber, Timestamp: snap.Timestamp, References: snap.References}
+			rounds[crypto.NewHash([]byte(key))] = round
+		}
+		if snap.Timestamp < round.Timestamp {
+			round.Timestamp = snap.Timestamp
+		}
+	}
+
+	finals := make(map[crypto.Hash]*common.Round)
+	heads := c.RoundsMap()
+	for _, round := range rounds {
+		if head := heads[round.NodeId]; head.Number == round.Number {
+			round.Hash = head.Hash
+		} else {
+			snapshots, err := readSnapshotsForNodeRound(w.txn, round.NodeId, round.Number)
+			if err != nil {
+				return err
+			}
+			_, _, round.Hash = computeRoundHash(round.NodeId, round.Number, snapshots)
+		}
+		finals[round.Hash] = round
+	}
+	for _, round := range finals {
+		if head := heads[round.NodeId]; head.Number == round.Number {
+			err = writeRound(w.txn, round.NodeId, &common.Round{
+				NodeId:     round.NodeId,
+				Number:     round.Number,
+				References: round.References,
+			})
+		} else {
+			err = writeRound(w.txn, round.Hash, &common.Round{
+				NodeId:     round.NodeId,
+				Number:     round.Number,
+				Timestamp:  round.Timestamp,
+				References: round.References,
+			})
+		}
+		if err != nil {
+			return err
+		}
+		if round.References == nil {
+			continue
+		}
+		external := finals[round.References.External]
+		if external == nil {
+			continue
+		}
+		link, err := readLink(w.txn, round.NodeId, external.NodeId)
+		if err != nil {
+			return err
+		}
+		if external.Number > link {
+			err = writeLink(w.txn, round.NodeId, external.NodeId, external.Number)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// the tree is built from the archive outputs, so it's the root to verify
+	root, err := readUTXOTreeRoot(newUTXOTree(w.txn))
+	if err != nil {
+		return err
+	}
+	if root != c.State.Root {
+		return fmt.Errorf("checkpoint utxo root not match %s %s", root, c.State.Root)
+	}
+	err = w.txn.Set(graphCheckpointKey(c.Number), common.MsgpackMarshalPanic(c))
+	if err != nil {
+		return err
+	}
+	return w.txn.Commit()
+}
+
+func walkCheckpointState(txn *badger.Txn, networkId crypto.Hash, c *common.Checkpoint, hook func(e *checkpointEntry) error) (*common.CheckpointState, error) {
+	err := c.Validate()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range c.Rounds {
+		round, err := readRound(txn, r.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if round == nil || round.NodeId != r.NodeId || round.Number != r.Number {
+			return nil, fmt.Errorf("checkpoint round %s %d %s not final", r.NodeId, r.Number, r.Hash)
+		}
+	}
+
+	var state common.CheckpointState
+	cut := &checkpointCut{txn: txn, rounds: c.RoundsMap()}
+	nodes := make(map[crypto.Key]*checkpointNode)
+	domains := make([]*checkpointDomain, 0)
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte(graphPrefixUTXO)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var out common.UTXOWithLock
+		err = common.DecompressMsgpackUnmarshal(val, &out)
+		if err != nil {
+			return nil, err
+		}
+		created, timestamp, err := cut.finalized(out.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if !created {
+			continue
+		}
+		if out.LockHash.HasValue() {
+			spent, _, err := cut.finalized(out.LockHash)
+			if err != nil {
+				return nil, err
+			}
+			if !spent {
+				out.LockHash = crypto.Hash{}
+			} else if out.Type == common.OutputTypeScript {
+				continue
+			}
+		}
+		state.UTXO = checkpointDigest(state.UTXO, &out)
+		err = hook(&checkpointEntry{Output: &out})
+		if err != nil {
+			return nil, err
+		}
+
+		prefix, nodeState := checkpointNodeState(out.Type)
+		if prefix == "" && out.Type != common.OutputTypeDomainAccept {
+			continue
+		}
+		ver, err := readTransaction(txn, out.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if ver == nil || len(ver.Extra) < 64 {
+			continue
+		}
+		var signer, payee crypto.Key
+		copy(signer[:], ver.Extra)
+		copy(payee[:], ver.Extra[len(signer):])
+		if out.Type == common.OutputTypeDomainAccept {
+			prefix = graphPrefixDomainAccept
+		}
+		if timestamp == 0 {
+			timestamp, err = checkpointStateTimestamp(txn, prefix, signer, out.Hash)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if out.Type == common.OutputTypeDomainAccept {
+			domains = append(domains, &checkpointDomain{Signer: signer, Transaction: out.Hash, Timestamp: timestamp})
+			continue
+		}
+		old := nodes[signer]
+		if old != nil && old.Timestamp > timestamp {
+			continue
+		}
+		if old != nil && old.Timestamp == timestamp && out.LockHash.HasValue() {
+			continue
+		}
+		nodes[signer] = &checkpointNode{
+			Signer:      signer,
+			Payee:       payee,
+			State:       nodeState,
+			Transaction: out.Hash,
+			Timestamp:   timestamp,
+		}
+	}
+
+	prefix = []byte(graphPrefixDeposit)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var d checkpointDeposit
+		copy(d.Key[:], item.Key()[len(prefix):])
+		copy(d.Transaction[:], val)
+		finalized, _, err := cut.finalized(d.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		if !finalized {
+			continue
+		}
+		state.UTXO = checkpointDigest(state.UTXO, &d)
+		err = hook(&checkpointEntry{Deposit: &d})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sorted := make([]*checkpointNode, 0)
+	for _, n := range nodes {
+		sorted = append(sorted, n)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].Signer[:], sorted[j].Signer[:]) < 0
+	})
+	for _, n := range sorted {
+		if n.State == common.NodeStateAccepted || n.State == common.NodeStateDeparting {
+			id := checkpointAddress(n.Signer).Hash().ForNetwork(networkId)
+			if cut.rounds[id] == nil {
+				return nil, fmt.Errorf("checkpoint round for node %s not found", id)
+			}
+		}
+		state.Nodes = checkpointDigest(state.Nodes, n)
+		err = hook(&checkpointEntry{Node: n})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Slice(domains, func(i, j int) bool {
+		return bytes.Compare(domains[i].Signer[:], domains[j].Signer[:]) < 0
+	})
+	for _, d := range domains {
+		state.Domains = checkpointDigest(state.Domains, d)
+		err = hook(&checkpointEntry{Domain: d})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	prefix = []byte(graphPrefixMint)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var dist common.MintDistribution
+		err = common.MsgpackUnmarshal(val, &dist)
+		if err != nil {
+			return nil, err
+		}
+		finalized, _, err := cut.finalized(dist.Transaction)
+		if err != nil {
+			return nil, err
+		}
+		if !finalized {
+			continue
+		}
+		state.Mint = checkpointDigest(state.Mint, &dist)
+		err = hook(&checkpointEntry{Mint: &dist})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &state, nil
+}
+
+type checkpointCut struct {
+	txn    *badger.Txn
+	rounds map[crypto.Hash]*common.CheckpointRound
+}
+
+// the transaction is in the cut if its first finalization snapshot is in the
+// checkpoint rounds, or it's imported from an earlier checkpoint without snapshot
+func (cut *checkpointCut) finalized(hash crypto.Hash) (bool, uint64, error) {
+	item, err := cut.txn.Get(graphFinalizationKey(hash))
+	if err == badger.ErrKeyNotFound {
+		return false, 0, nil
+	} else if err != nil {
+		return false, 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return false, 0, err
+	}
+	if len(val) == 0 {
+		return true, 0, nil
+	}
+	var snap crypto.Hash
+	copy(snap[:], val)
+	s, err := readSnapshotWithTopo(cut.txn, snap)
+	if err != nil {
+		return false, 0, err
+	}
+	if r := cut.rounds[s.NodeId]; r != nil && s.RoundNumber > r.Number {
+		return false, 0, nil
+	}
+	return true, s.Timestamp, nil
+}
+
+func checkpointStateTimestamp(txn *badger.Txn, prefix string, signer crypto.Key, tx crypto.Hash) (uint64, error) {
+	item, err := txn.Get(append([]byte(prefix), signer[:]...))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	if prefix == graphPrefixDomainAccept {
+		val = append(make([]byte, len(signer)), val...)
+	}
+	if nodeTransaction(val) != tx {
+		return 0, nil
+	}
+	return nodeTimestamp(val), nil
+}
+
+func checkpointNodeState(typ uint8) (string, string) {
+	switch typ {
+	case common.OutputTypeNodePledge:
+		return graphPrefixNodePledge, common.NodeStatePledging
+	case common.OutputTypeNodeAccept:
+		return graphPrefixNodeAccept, common.NodeStateAccepted
+	case common.OutputTypeNodeDepart:
+		return graphPrefixNodeDepart, common.NodeStateDeparting
+	case common.OutputTypeNodeRemove:
+		return graphPrefixNodeRemove, common.NodeStateRemoved
+	case common.OutputTypeNodeCancel:
+		return graphPrefixNodeCancel, common.NodeStateCancelled
+	}
+	return "", ""
+}
+
+func checkpointNodeValue(tx crypto.Hash, timestamp uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, timestamp)
+	return append(tx[:], buf...)
+}
+
+func checkpointAddress(publicSpend crypto.Key) common.Address {
+	privateView := publicSpend.DeterministicHashDerive()
+	return common.Address{
+		PrivateViewKey: privateView,
+		PublicViewKey:  privateView.Public(),
+		PublicSpendKey: publicSpend,
+	}
+}
+
+func checkpointDigest(hash crypto.Hash, val interface{}) crypto.Hash {
+	return crypto.NewHash(append(hash[:], common.MsgpackMarshalPanic(val)...))
+}
+
+func writeCheckpointOutput(w *batchWriter, out *common.UTXOWithLock) error {
+	for _, k := range out.Keys {
+		err := w.Set(graphGhostKey(k), []byte{0})
+		if err != nil {
+			return err
+		}
+	}
+	err := w.Set(graphUtxoKey(out.Hash, out.Index), common.CompressMsgpackMarshalPanic(out))
+	if err != nil {
+		return err
+	}
+	err = w.Set(graphFinalizationKey(out.Hash), []byte{})
+	if err != nil {
+		return err
+	}
+	if !out.LockHash.HasValue() {
+		return writeUTXOTreeLeaf(newUTXOTree(w), &out.UTXO)
+	}
+	return w.Set(graphFinalizationKey(out.LockHash), []byte{})
+}
+
+func writeCheckpointNode(w *batchWriter, n *checkpointNode) error {
+	var prefix string
+	switch n.State {
+	case common.NodeStatePledging:
+		prefix = graphPrefixNodePledge
+	case common.NodeStateAccepted:
+		prefix = graphPrefixNodeAccept
+	case common.NodeStateDeparting:
+		prefix = graphPrefixNodeDepart
+	case common.NodeStateRemoved:
+		prefix = graphPrefixNodeRemove
+	case common.NodeStateCancelled:
+		prefix = graphPrefixNodeCancel
+	default:
+		return fmt.Errorf("invalid checkpoint node state %s", n.State)
+	}
+	val := append(n.Payee[:], checkpointNodeValue(n.Transaction, n.Timestamp)...)
+	return w.Set(append([]byte(prefix), n.Signer[:]...), val)
+}
+
+func readCheckpointHeader(r io.Reader) (*crypto.Hash, *common.Checkpoint, error) {
+	e, err := readCheckpointEntry(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Network == nil {
+		return nil, nil, fmt.Errorf("invalid checkpoint archive without network")
+	}
+	network := e.Network
+	e, err = readCheckpointEntry(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	if e.Checkpoint == nil {
+		return nil, nil, fmt.Errorf("invalid checkpoint archive without checkpoint")
+	}
+	return network, e.Checkpoint, e.Checkpoint.Validate()
+}
+
+func readCheckpointEntry(r io.Reader) (*checkpointEntry, error) {
+	var size [4]byte
+	_, err := io.ReadFull(r, size[:])
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, binary.BigEndian.Uint32(size[:]))
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	var e checkpointEntry
+	err = common.DecompressMsgpackUnmarshal(data, &e)
+	return &e, err
+}
+
+func writeCheckpointEntry(w io.Writer, e *checkpointEntry) error {
+	data := common.CompressMsgpackMarshalPanic(e)
+	size := make([]byte, 4)
+	binary.BigEndian.PutUint32(size, uint32(len(data)))
+	_, err := w.Write(append(size, data...))
+	return err
+}
+
+func readCheckpoint(txn *badger.Txn, number uint64) (*common.Checkpoint, error) {
+	item, err := txn.Get(graphCheckpointKey(number))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var c common.Checkpoint
+	err = common.MsgpackUnmarshal(val, &c)
+	return &c, err
+}
+
+func graphCheckpointKey(number uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, number)
+	return append([]byte(graphPrefixCheckpoint), buf...)
+}
@@ -0,0 +1,353 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-checkpoint-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	last, err := store.ReadLastCheckpoint()
+	assert.Nil(err)
+	assert.Nil(last)
+
+	nodeId := crypto.NewHash([]byte("node"))
+	for _, n := range []uint64{2, 1, 300} {
+		c := &common.Checkpoint{
+			Version: common.CheckpointVersion,
+			Number:  n,
+			Rounds:  []*common.CheckpointRound{{NodeId: nodeId, Number: n * 10}},
+		}
+		assert.Nil(store.WriteCheckpoint(c))
+	}
+	c := &common.Checkpoint{
+		Version: common.CheckpointVersion,
+		Number:  2,
+		Rounds:  []*common.CheckpointRound{{NodeId: nodeId, Number: 1}},
+	}
+	assert.Nil(store.WriteCheckpoint(c))
+	c, err = store.ReadCheckpoint(2)
+	assert.Nil(err)
+	assert.Equal(uint64(20), c.Rounds[0].Number)
+	c, err = store.ReadCheckpoint(3)
+	assert.Nil(err)
+	assert.Nil(c)
+
+	last, err = store.ReadLastCheckpoint()
+	assert.Nil(err)
+	assert.Equal(uint64(300), last.Number)
+	checkpoints, err := store.ReadCheckpoints(2, 10)
+	assert.Nil(err)
+	assert.Len(checkpoints, 2)
+	assert.Equal(uint64(2), checkpoints[0].Number)
+	assert.Equal(uint64(300), checkpoints[1].Number)
+	checkpoints, err = store.ReadCheckpoints(0, 1)
+	assert.Nil(err)
+	assert.Len(checkpoints, 1)
+	assert.Equal(uint64(1), checkpoints[0].Number)
+
+	var buf bytes.Buffer
+	assert.Nil(writeCheckpointEntry(&buf, &checkpointEntry{Network: &nodeId}))
+	assert.Nil(writeCheckpointEntry(&buf, &checkpointEntry{Checkpoint: last}))
+	network, header, err := readCheckpointHeader(&buf)
+	assert.Nil(err)
+	assert.Equal(nodeId, *network)
+	assert.Equal(last.PayloadHash(), header.PayloadHash())
+	_, err = readCheckpointEntry(&buf)
+	assert.Equal(io.EOF, err)
+}
+
+func TestCheckpointArchive(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-checkpoint-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+	for _, dir := range []string{"source", "rejected", "imported"} {
+		assert.Nil(os.Mkdir(root+"/"+dir, 0700))
+	}
+
+	store, err := NewBadgerStore(root + "/source")
+	assert.Nil(err)
+	defer store.Close()
+
+	networkId := crypto.NewHash([]byte("network"))
+	epoch := uint64(1600000000000000000)
+	signers := []common.Address{checkpointTestAccount("signer0"), checkpointTestAccount("signer1")}
+	ids := make([]crypto.Hash, len(signers))
+	snapshots := make([]*common.SnapshotWithTopologicalOrder, len(signers))
+	transactions := make([]*common.VersionedTransaction, len(signers))
+	for i, signer := range signers {
+		ids[i] = signer.Hash().ForNetwork(networkId)
+		payee := checkpointTestAccount(fmt.Sprintf("payee%d", i))
+		tx := common.NewTransaction(common.XINAssetId)
+		tx.Inputs = []*common.Input{{Genesis: networkId[:]}}
+		tx.AddOutputWithType(common.OutputTypeNodeAccept, signers, common.NewThresholdScript(1), common.NewInteger(10000), checkpointTestSeed(fmt.Sprint(i)))
+		tx.Extra = append(signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
+		transactions[i] = tx.AsLatestVersion()
+		snapshots[i] = &common.SnapshotWithTopologicalOrder{
+			Snapshot: common.Snapshot{
+				Version:     common.SnapshotVersion,
+				NodeId:      ids[i],
+				Transaction: transactions[i].PayloadHash(),
+				Timestamp:   epoch,
+			},
+			TopologicalOrder: uint64(i),
+		}
+		snapshots[i].Hash = snapshots[i].PayloadHash()
+	}
+	hashes := make(map[crypto.Hash][]crypto.Hash)
+	var rounds []*common.Round
+	for i, id := range ids {
+		_, _, hash := computeRoundHash(id, 0, snapshots[i:i+1])
+		hashes[id] = append(hashes[id], hash)
+		rounds = append(rounds, &common.Round{Hash: hash, NodeId: id, Timestamp: epoch})
+	}
+	for i, id := range ids {
+		rounds = append(rounds, &common.Round{Hash: id, NodeId: id, Number: 1, References: &common.RoundLink{
+			Self:     hashes[id][0],
+			External: hashes[ids[1-i]][0],
+		}})
+	}
+	assert.Nil(store.LoadGenesis(rounds, snapshots, transactions))
+
+	topology := uint64(len(ids))
+	write := func(i int, number uint64, name string, input crypto.Hash) *common.VersionedTransaction {
+		tx := common.NewTransaction(common.XINAssetId)
+		if input.HasValue() {
+			tx.AddInput(input, 0)
+		} else {
+			tx.Inputs = []*common.Input{{Genesis: networkId[:]}}
+		}
+		tx.AddOutputWithType(common.OutputTypeScript, signers[i:i+1], common.NewThresholdScript(1), common.NewInteger(100), checkpointTestSeed(name))
+		ver := tx.AsLatestVersion()
+		if input.HasValue() {
+			assert.Nil(store.LockUTXO(input, 0, ver.PayloadHash(), false))
+		}
+		assert.Nil(store.WriteTransaction(ver))
+		head, err := store.ReadRound(ids[i])
+		assert.Nil(err)
+		assert.Equal(number, head.Number)
+		s := &common.SnapshotWithTopologicalOrder{
+			Snapshot: common.Snapshot{
+				Version:     common.SnapshotVersion,
+				NodeId:      ids[i],
+				Transaction: ver.PayloadHash(),
+				References:  head.References,
+				RoundNumber: number,
+				Timestamp:   epoch + number*uint64(time.Minute),
+			},
+			TopologicalOrder: topology,
+		}
+		topology++
+		assert.Nil(store.WriteSnapshot(s))
+		return ver
+	}
+	start := func(i int, number uint64, external crypto.Hash) {
+		snapshots, err := store.ReadSnapshotsForNodeRound(ids[i], number-1)
+		assert.Nil(err)
+		_, _, hash := computeRoundHash(ids[i], number-1, snapshots)
+		hashes[ids[i]] = append(hashes[ids[i]], hash)
+		references := &common.RoundLink{Self: hash, External: external}
+		assert.Nil(store.StartNewRound(ids[i], number, references, snapshots[0].Timestamp))
+	}
+
+	a1 := write(0, 1, "a1", crypto.Hash{})
+	b1 := write(1, 1, "b1", crypto.Hash{})
+	start(0, 2, hashes[ids[1]][0])
+	start(1, 2, hashes[ids[0]][1])
+	a2 := write(0, 2, "a2", a1.PayloadHash())
+	write(1, 2, "b2", crypto.Hash{})
+	start(0, 3, hashes[ids[1]][1])
+	start(1, 3, hashes[ids[0]][2])
+	a3 := write(0, 3, "a3", b1.PayloadHash())
+
+	c := &common.Checkpoint{
+		Version:   common.CheckpointVersion,
+		Number:    1,
+		Timestamp: epoch + uint64(time.Hour),
+	}
+	for _, id := range ids {
+		c.Rounds = append(c.Rounds, &common.CheckpointRound{NodeId: id, Number: 2, Hash: hashes[id][2]})
+	}
+	sort.Slice(c.Rounds, func(i, j int) bool {
+		a, b := c.Rounds[i].NodeId, c.Rounds[j].NodeId
+		return bytes.Compare(a[:], b[:]) < 0
+	})
+	state, err := store.ComputeCheckpointState(networkId, c)
+	assert.Nil(err)
+	c.State = *state
+	assert.Nil(store.WriteCheckpoint(c))
+
+	// a3 is after the checkpoint rounds, so b1 is still unspent at the root
+	assert.False(c.State.Root == crypto.Hash{})
+	txn := store.snapshotsDB.NewTransaction(false)
+	live, err := readUTXOTreeRoot(newUTXOTree(txn))
+	txn.Discard()
+	assert.Nil(err)
+	assert.NotEqual(c.State.Root, live)
+	for _, id := range ids {
+		round, err := store.ReadRound(hashes[id][2])
+		assert.Nil(err)
+		assert.Equal(c.State.Root, round.UTXORoot)
+	}
+	for _, ver := range []*common.VersionedTransaction{a2, b1, a3} {
+		proof, cp, err := store.ReadUTXOProof(ver.PayloadHash(), 0)
+		assert.Nil(err)
+		assert.Equal(c.Number, cp.Number)
+		if ver == a3 {
+			assert.Nil(proof.VerifyExclusion(c.State.Root))
+		} else {
+			assert.Nil(proof.VerifyInclusion(c.State.Root, ver.UnspentOutputs()[0]))
+		}
+	}
+
+	path := root + "/checkpoint.bin"
+	f, err := os.Create(path)
+	assert.Nil(err)
+	assert.Nil(store.ExportCheckpoint(networkId, c.Number, f))
+	assert.Nil(f.Close())
+
+	rejected, err := NewBadgerStore(root + "/rejected")
+	assert.Nil(err)
+	defer rejected.Close()
+	_, err = rejected.ImportCheckpoint(path, func(crypto.Hash, *common.Checkpoint, []*common.Node) error {
+		return fmt.Errorf("rejected")
+	})
+	assert.NotNil(err)
+	loaded, err := rejected.CheckGenesisLoad()
+	assert.Nil(err)
+	assert.False(loaded)
+
+	imported, err := NewBadgerStore(root + "/imported")
+	assert.Nil(err)
+	defer imported.Close()
+	result, err := imported.ImportCheckpoint(path, func(id crypto.Hash, cp *common.Checkpoint, nodes []*common.Node) error {
+		assert.Equal(networkId, id)
+		assert.Len(nodes, 2)
+		return nil
+	})
+	assert.Nil(err)
+	assert.Equal(c.PayloadHash(), result.PayloadHash())
+	proof, cp, err := imported.ReadUTXOProof(b1.PayloadHash(), 0)
+	assert.Nil(err)
+	assert.Equal(c.Number, cp.Number)
+	assert.Nil(proof.VerifyInclusion(c.State.Root, b1.UnspentOutputs()[0]))
+	_, err = imported.ImportCheckpoint(path, func(crypto.Hash, *common.Checkpoint, []*common.Node) error {
+		return nil
+	})
+	assert.NotNil(err)
+
+	utxo, err := imported.ReadUTXO(a1.PayloadHash(), 0)
+	assert.Nil(err)
+	assert.Nil(utxo)
+	utxo, err = imported.ReadUTXO(b1.PayloadHash(), 0)
+	assert.Nil(err)
+	assert.False(utxo.LockHash.HasValue())
+	utxo, err = imported.ReadUTXO(a3.PayloadHash(), 0)
+	assert.Nil(err)
+	assert.Nil(utxo)
+	assert.Len(imported.ReadConsensusNodes(), 2)
+	total, invalid, err := imported.ValidateGraphEntries(networkId)
+	assert.Nil(err)
+	assert.Equal(0, invalid)
+	assert.Equal(4, total)
+
+	last, err := imported.ReadLastCheckpoint()
+	assert.Nil(err)
+	assert.Equal(c.PayloadHash(), last.PayloadHash())
+	head, err := imported.ReadRound(ids[0])
+	assert.Nil(err)
+	assert.Equal(uint64(2), head.Number)
+	round, err := imported.ReadRound(hashes[ids[0]][1])
+	assert.Nil(err)
+	assert.Equal(uint64(1), round.Number)
+	round, err = imported.ReadRound(hashes[ids[0]][2])
+	assert.Nil(err)
+	assert.Nil(round)
+	references := &common.RoundLink{Self: hashes[ids[0]][2], External: hashes[ids[1]][1]}
+	assert.Nil(imported.StartNewRound(ids[0], 3, references, epoch+2*uint64(time.Minute)))
+	round, err = imported.ReadRound(hashes[ids[0]][2])
+	assert.Nil(err)
+	assert.Equal(uint64(2), round.Number)
+
+	pruned, err := store.ReadPrunedTopology()
+	assert.Nil(err)
+	assert.Equal(uint64(0), pruned)
+	assert.Nil(store.PruneGraphEntries(5))
+	pruned, err = store.ReadPrunedTopology()
+	assert.Nil(err)
+	assert.Equal(uint64(5), pruned)
+	utxo, err = store.ReadUTXO(a1.PayloadHash(), 0)
+	assert.Nil(err)
+	assert.Nil(utxo)
+	utxo, err = store.ReadUTXO(a2.PayloadHash(), 0)
+	assert.Nil(err)
+	assert.NotNil(utxo)
+	utxo, err = store.ReadUTXO(b1.PayloadHash(), 0)
+	assert.Nil(err)
+	assert.Equal(a3.PayloadHash(), utxo.LockHash)
+	// a2 has an unspent output, so its body is kept
+	ver, final, err := store.ReadTransaction(a2.PayloadHash())
+	assert.Nil(err)
+	assert.NotNil(ver)
+	assert.NotEqual("", final)
+	_, _, err = store.ReadSnapshotWithTransactionsSinceTopology(4, 10)
+	assert.NotNil(err)
+	snapshots, _, err = store.ReadSnapshotWithTransactionsSinceTopology(5, 10)
+	assert.Nil(err)
+	assert.Len(snapshots, 2)
+	ver, _, err = store.ReadTransaction(a1.PayloadHash())
+	assert.Nil(err)
+	assert.NotNil(ver)
+	ver, _, err = store.ReadTransaction(transactions[0].PayloadHash())
+	assert.Nil(err)
+	assert.NotNil(ver)
+	state, err = store.ComputeCheckpointState(networkId, c)
+	assert.Nil(err)
+	assert.Equal(c.State, *state)
+	total, invalid, err = store.ValidateGraphEntries(networkId)
+	assert.Nil(err)
+	assert.Equal(0, invalid)
+	assert.Nil(store.PruneGraphEntries(3))
+	pruned, err = store.ReadPrunedTopology()
+	assert.Nil(err)
+	assert.Equal(uint64(5), pruned)
+	assert.Nil(store.ExportCheckpoint(networkId, c.Number, ioutil.Discard))
+	assert.Nil(store.PruneGraphEntries(7))
+	err = store.ExportCheckpoint(networkId, c.Number, ioutil.Discard)
+	assert.Equal("checkpoint 1 rounds pruned at topology 7", err.Error())
+}
+
+func checkpointTestAccount(seed string) common.Address {
+	hash := crypto.NewHash([]byte(seed))
+	priv := crypto.NewKeyFromSeed(append(hash[:], hash[:]...))
+	addr := common.Address{PrivateSpendKey: priv, PublicSpendKey: priv.Public()}
+	addr.PrivateViewKey = addr.PublicSpendKey.DeterministicHashDerive()
+	addr.PublicViewKey = addr.PrivateViewKey.Public()
+	return addr
+}
+
+func checkpointTestSeed(seed string) []byte {
+	hash := crypto.NewHash([]byte(seed))
+	return append(hash[:], hash[:]...)
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	graphPrefixEvidence = "EVIDENCE" // node|evidence-hash
+)
+
+func (s *BadgerStore) WriteEvidence(e *common.Evidence) error {
+	txn := s.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	key := graphEvidenceKey(e.NodeId, e.Hash())
+	_, err := txn.Get(key)
+	if err == nil {
+		return nil
+	} else if err != badger.ErrKeyNotFound {
+		return err
+	}
+	err = txn.Set(key, common.MsgpackMarshalPanic(e))
+	if err != nil {
+		return err
+	}
+	return txn.Commit()
+}
+
+// all evidences are returned if the node id is empty
+func (s *BadgerStore) ReadEvidences(nodeId crypto.Hash) ([]*common.Evidence, error) {
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte(graphPrefixEvidence)
+	if nodeId.HasValue() {
+		prefix = append(prefix, nodeId[:]...)
+	}
+	evidences := make([]*common.Evidence, 0)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return evidences, err
+		}
+		var e common.Evidence
+		err = common.MsgpackUnmarshal(v, &e)
+		if err != nil {
+			return evidences, err
+		}
+		for _, es := range e.Snapshots {
+			es.Snapshot.Hash = es.Snapshot.PayloadHash()
+		}
+		evidences = append(evidences, &e)
+	}
+	return evidences, nil
+}
+
+func graphEvidenceKey(nodeId, hash crypto.Hash) []byte {
+	key := append([]byte(graphPrefixEvidence), nodeId[:]...)
+	return append(key, hash[:]...)
+}
@@ -123,7 +123,27 @@ func (s *BadgerStore) WriteSnapshot(snap *common.SnapshotWithTopologicalOrder) e
 	if err != nil {
 		return err
 	}
-	return txn.Commit()
+	err = txn.Commit()
+	if err != nil {
+		return err
+	}
+	s.notifySnapshots()
+	return nil
+}
+
+// SnapshotsNotify returns a channel which is closed when the next snapshot
+// is committed, so the readers wait for new snapshots without polling
+func (s *BadgerStore) SnapshotsNotify() <-chan struct{} {
+	s.notifyMutex.Lock()
+	defer s.notifyMutex.Unlock()
+	return s.snapshotsNotify
+}
+
+func (s *BadgerStore) notifySnapshots() {
+	s.notifyMutex.Lock()
+	defer s.notifyMutex.Unlock()
+	close(s.snapshotsNotify)
+	s.snapshotsNotify = make(chan struct{})
 }
 
 func writeSnapshot(txn *badger.Txn, snap *common.SnapshotWithTopologicalOrder, ver *common.VersionedTransaction) error {
@@ -54,6 +54,10 @@ func (s *BadgerStore) AddNodeOperation(tx *common.VersionedTransaction, timestam
 		op = "PLEDGE"
 	case common.TransactionTypeNodeCancel:
 		op = "CANCEL"
+	case common.TransactionTypeNodeDepart:
+		op = "DEPART"
+	case common.TransactionTypeNodeRemove:
+		op = "REMOVE"
 	}
 	if op == "" {
 		return fmt.Errorf("invalid operation %d %s", tx.TransactionType(), op)
@@ -185,6 +189,50 @@ func writeNodeAccept(txn *badger.Txn, signer, payee crypto.Key, tx crypto.Hash,
 	return txn.Set(key, val)
 }
 
+func writeNodeDepart(txn *badger.Txn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64) error {
+	// TODO these checks are only assert kind checks, not needed at all
+	key := nodeAcceptKey(signer)
+	_, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return fmt.Errorf("node not accepted yet %s", signer.String())
+	} else if err != nil {
+		return err
+	}
+
+	err = txn.Delete(key)
+	if err != nil {
+		return err
+	}
+	key = nodeDepartKey(signer)
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, timestamp)
+	val := append(payee[:], tx[:]...)
+	val = append(val, buf...)
+	return txn.Set(key, val)
+}
+
+func writeNodeRemove(txn *badger.Txn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64) error {
+	// TODO these checks are only assert kind checks, not needed at all
+	key := nodeDepartKey(signer)
+	_, err := txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return fmt.Errorf("node not departing yet %s", signer.String())
+	} else if err != nil {
+		return err
+	}
+
+	err = txn.Delete(key)
+	if err != nil {
+		return err
+	}
+	key = nodeRemoveKey(signer)
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, timestamp)
+	val := append(payee[:], tx[:]...)
+	val = append(val, buf...)
+	return txn.Set(key, val)
+}
+
 func writeNodePledge(txn *badger.Txn, signer, payee crypto.Key, tx crypto.Hash, timestamp uint64) error {
 	// TODO these checks are only assert kind checks, not needed at all
 	key := nodeAcceptKey(signer)
@@ -267,6 +315,10 @@ func nodeDepartKey(publicSpend crypto.Key) []byte {
 	return append([]byte(graphPrefixNodeDepart), publicSpend[:]...)
 }
 
+func nodeRemoveKey(publicSpend crypto.Key) []byte {
+	return append([]byte(graphPrefixNodeRemove), publicSpend[:]...)
+}
+
 func nodeOperationKey(timestamp uint64) []byte {
 	buf := make([]byte, 8)
 	binary.BigEndian.PutUint64(buf, timestamp)
@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	stateKeyPrunedTopology = "pruned"
+	pruneBatchSize         = 100
+)
+
+// ReadPrunedTopology returns the topology before which the spent outputs and
+// transaction bodies may have been pruned, zero if the node never pruned
+func (s *BadgerStore) ReadPrunedTopology() (uint64, error) {
+	var topology uint64
+	_, err := s.StateGet(stateKeyPrunedTopology, &topology)
+	return topology, err
+}
+
+// PruneGraphEntries drops the spent script outputs and the transaction bodies
+// of all the snapshots before the topology, the snapshots, rounds and all the
+// node and domain transactions are always kept, and so are the transactions
+// with any output unspent or spent by a node pledge
+func (s *BadgerStore) PruneGraphEntries(topology uint64) error {
+	offset, err := s.ReadPrunedTopology()
+	if err != nil || offset >= topology {
+		return err
+	}
+
+	start, total := time.Now(), 0
+	for offset < topology {
+		count := topology - offset
+		if count > pruneBatchSize {
+			count = pruneBatchSize
+		}
+		snapshots, err := s.ReadSnapshotsSinceTopology(offset, count)
+		if err != nil || len(snapshots) == 0 {
+			return err
+		}
+		err = s.snapshotsDB.Update(func(txn *badger.Txn) error {
+			for _, snap := range snapshots {
+				err := pruneSnapshotTransaction(txn, snap.Transaction)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		offset = snapshots[len(snapshots)-1].TopologicalOrder + 1
+		err = s.StateSet(stateKeyPrunedTopology, offset)
+		if err != nil {
+			return err
+		}
+		total += len(snapshots)
+	}
+	logger.Printf("Prune %d snapshots before %d in %s\n", total, offset, time.Now().Sub(start).String())
+	return nil
+}
+
+func pruneSnapshotTransaction(txn *badger.Txn, hash crypto.Hash) error {
+	ver, err := readTransaction(txn, hash)
+	if err != nil || ver == nil {
+		return err
+	}
+
+	prunable := true
+	for _, in := range ver.Inputs {
+		if len(in.Genesis) > 0 || in.Mint != nil {
+			prunable = false
+			continue
+		}
+		if in.Deposit != nil {
+			continue
+		}
+		key := graphUtxoKey(in.Hash, in.Index)
+		item, err := txn.Get(key)
+		if err == badger.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var out common.UTXOWithLock
+		err = common.DecompressMsgpackUnmarshal(val, &out)
+		if err != nil {
+			return err
+		}
+		if out.Type != common.OutputTypeScript || out.LockHash != hash {
+			continue
+		}
+		err = txn.Delete(key)
+		if err != nil {
+			return err
+		}
+	}
+	for _, out := range ver.Outputs {
+		if out.Type != common.OutputTypeScript {
+			prunable = false
+		}
+	}
+	if !prunable {
+		return nil
+	}
+	for i := range ver.Outputs {
+		spent, err := checkPrunableOutput(txn, hash, i)
+		if err != nil || !spent {
+			return err
+		}
+	}
+	return txn.Delete(graphTransactionKey(hash))
+}
+
+// the node cancel validation reads the pledge input transaction, so the body
+// is kept if any output is not spent yet, or spent by a node pledge
+func checkPrunableOutput(txn *badger.Txn, hash crypto.Hash, index int) (bool, error) {
+	item, err := txn.Get(graphUtxoKey(hash, index))
+	if err == badger.ErrKeyNotFound {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return false, err
+	}
+	var out common.UTXOWithLock
+	err = common.DecompressMsgpackUnmarshal(val, &out)
+	if err != nil || !out.LockHash.HasValue() {
+		return false, err
+	}
+	_, err = txn.Get(graphFinalizationKey(out.LockHash))
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	spender, err := readTransaction(txn, out.LockHash)
+	if err != nil || spender == nil {
+		return false, err
+	}
+	return spender.TransactionType() != common.TransactionTypeNodePledge, nil
+}
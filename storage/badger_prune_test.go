@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPruneSnapshotTransaction(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-prune-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	txn := store.snapshotsDB.NewTransaction(true)
+	defer txn.Discard()
+
+	account := checkpointTestAccount("account")
+	write := func(name string, lock *common.VersionedTransaction, finalized bool) crypto.Hash {
+		tx := common.NewTransaction(common.XINAssetId)
+		tx.AddInput(crypto.NewHash([]byte(name)), 0)
+		tx.AddOutputWithType(common.OutputTypeScript, []common.Address{account}, common.NewThresholdScript(1), common.NewInteger(100), checkpointTestSeed(name))
+		source := tx.AsLatestVersion()
+		assert.Nil(writeTransaction(txn, source))
+		utxo := &common.UTXOWithLock{UTXO: *source.UnspentOutputs()[0]}
+		if lock != nil {
+			lock.Inputs[0].Hash = source.PayloadHash()
+			utxo.LockHash = lock.PayloadHash()
+			assert.Nil(writeTransaction(txn, lock))
+		}
+		if lock != nil && finalized {
+			assert.Nil(txn.Set(graphFinalizationKey(lock.PayloadHash()), []byte{1}))
+		}
+		assert.Nil(txn.Set(graphUtxoKey(source.PayloadHash(), 0), common.CompressMsgpackMarshalPanic(utxo)))
+		return source.PayloadHash()
+	}
+	spend := func(typ uint8, name string) *common.VersionedTransaction {
+		tx := common.NewTransaction(common.XINAssetId)
+		tx.AddInput(crypto.Hash{}, 0)
+		tx.AddOutputWithType(typ, nil, nil, common.NewInteger(100), checkpointTestSeed(name))
+		if typ == common.OutputTypeScript {
+			tx.Outputs[0].Keys = []crypto.Key{crypto.NewKeyFromSeed(checkpointTestSeed(name)).Public()}
+		}
+		return tx.AsLatestVersion()
+	}
+
+	cases := []struct {
+		name      string
+		lock      *common.VersionedTransaction
+		finalized bool
+		pruned    bool
+	}{
+		{"unspent", nil, false, false},
+		{"pending", spend(common.OutputTypeScript, "pending"), false, false},
+		{"pledge", spend(common.OutputTypeNodePledge, "pledge"), true, false},
+		{"script", spend(common.OutputTypeScript, "script"), true, true},
+	}
+	for _, c := range cases {
+		hash := write(c.name, c.lock, c.finalized)
+		assert.Nil(pruneSnapshotTransaction(txn, hash))
+		ver, err := readTransaction(txn, hash)
+		assert.Nil(err)
+		assert.Equal(c.pruned, ver == nil, fmt.Sprint(c.name))
+	}
+}
@@ -132,6 +132,11 @@ func startNewRound(txn *badger.Txn, node crypto.Hash, number uint64, references
 			return err
 		}
 		self.Timestamp = finalStart
+		// the root of all the transactions finalized by this node till now
+		self.UTXORoot, err = readUTXOTreeRoot(newUTXOTree(txn))
+		if err != nil {
+			return err
+		}
 		err = writeRound(txn, references.Self, self)
 		if err != nil {
 			return err
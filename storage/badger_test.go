@@ -4,7 +4,10 @@ import (
 	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -36,3 +39,83 @@ func TestBadger(t *testing.T) {
 	err = store.Close()
 	assert.Nil(err)
 }
+
+func TestSnapshotsNotify(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-badger-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	store, err := NewBadgerStore(root)
+	assert.Nil(err)
+	defer store.Close()
+
+	networkId := crypto.NewHash([]byte("network"))
+	epoch := uint64(1600000000000000000)
+	signer := checkpointTestAccount("signer")
+	payee := checkpointTestAccount("payee")
+	nodeId := signer.Hash().ForNetwork(networkId)
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.Inputs = []*common.Input{{Genesis: networkId[:]}}
+	tx.AddOutputWithType(common.OutputTypeNodeAccept, []common.Address{signer}, common.NewThresholdScript(1), common.NewInteger(10000), checkpointTestSeed("genesis"))
+	tx.Extra = append(signer.PublicSpendKey[:], payee.PublicSpendKey[:]...)
+	genesis := tx.AsLatestVersion()
+	snapshot := &common.SnapshotWithTopologicalOrder{
+		Snapshot: common.Snapshot{
+			Version:     common.SnapshotVersion,
+			NodeId:      nodeId,
+			Transaction: genesis.PayloadHash(),
+			Timestamp:   epoch,
+		},
+	}
+	snapshot.Hash = snapshot.PayloadHash()
+	_, _, hash := computeRoundHash(nodeId, 0, []*common.SnapshotWithTopologicalOrder{snapshot})
+	rounds := []*common.Round{
+		{Hash: hash, NodeId: nodeId, Timestamp: epoch},
+		{Hash: nodeId, NodeId: nodeId, Number: 1, References: &common.RoundLink{Self: hash, External: hash}},
+	}
+	err = store.LoadGenesis(rounds, []*common.SnapshotWithTopologicalOrder{snapshot}, []*common.VersionedTransaction{genesis})
+	assert.Nil(err)
+
+	notify := store.SnapshotsNotify()
+	assert.Equal(notify, store.SnapshotsNotify())
+	select {
+	case <-notify:
+		assert.Fail("snapshots notified without write")
+	default:
+	}
+
+	tx = common.NewTransaction(common.XINAssetId)
+	tx.Inputs = []*common.Input{{Genesis: networkId[:]}}
+	tx.AddOutputWithType(common.OutputTypeScript, []common.Address{payee}, common.NewThresholdScript(1), common.NewInteger(100), checkpointTestSeed("notify"))
+	ver := tx.AsLatestVersion()
+	assert.Nil(store.WriteTransaction(ver))
+	head, err := store.ReadRound(nodeId)
+	assert.Nil(err)
+	s := &common.SnapshotWithTopologicalOrder{
+		Snapshot: common.Snapshot{
+			Version:     common.SnapshotVersion,
+			NodeId:      nodeId,
+			Transaction: ver.PayloadHash(),
+			References:  head.References,
+			RoundNumber: head.Number,
+			Timestamp:   epoch + uint64(time.Minute),
+		},
+		TopologicalOrder: 1,
+	}
+	assert.Nil(store.WriteSnapshot(s))
+	select {
+	case <-notify:
+	default:
+		assert.Fail("snapshots not notified")
+	}
+
+	next := store.SnapshotsNotify()
+	assert.NotEqual(notify, next)
+	select {
+	case <-next:
+		assert.Fail("snapshots notified twice")
+	default:
+	}
+}
@@ -2,6 +2,7 @@ package storage
 
 import (
 	"encoding/binary"
+	"fmt"
 
 	"github.com/MixinNetwork/mixin/common"
 	"github.com/MixinNetwork/mixin/crypto"
@@ -52,7 +53,16 @@ func readSnapshotWithTopo(txn *badger.Txn, hash crypto.Hash) (*common.SnapshotWi
 	return &snap, nil
 }
 
+// the transactions before the pruned topology may be dropped, so the snapshots
+// with transactions are only read after it
 func (s *BadgerStore) ReadSnapshotWithTransactionsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error) {
+	pruned, err := s.ReadPrunedTopology()
+	if err != nil {
+		return nil, nil, err
+	}
+	if topologyOffset < pruned {
+		return nil, nil, fmt.Errorf("snapshots before the pruned topology %d %d", topologyOffset, pruned)
+	}
 	snapshots, err := s.ReadSnapshotsSinceTopology(topologyOffset, count)
 	if err != nil {
 		return nil, nil, err
@@ -179,11 +179,25 @@ func finalizeTransaction(txn *badger.Txn, ver *common.VersionedTransaction, snap
 		}
 	}
 
+	tree := newUTXOTree(txn)
+	for _, in := range ver.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || in.Mint != nil {
+			continue
+		}
+		err := removeUTXOTreeLeaf(tree, in.Hash, in.Index)
+		if err != nil {
+			return err
+		}
+	}
 	for _, utxo := range ver.UnspentOutputs() {
 		err := writeUTXO(txn, utxo, ver.Extra, snap.Timestamp, genesis)
 		if err != nil {
 			return err
 		}
+		err = writeUTXOTreeLeaf(tree, utxo)
+		if err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -227,6 +241,10 @@ func writeUTXO(txn *badger.Txn, utxo *common.UTXO, extra []byte, timestamp uint6
 		return writeNodeCancel(txn, signer, payee, utxo.Hash, timestamp)
 	case common.OutputTypeNodeAccept:
 		return writeNodeAccept(txn, signer, payee, utxo.Hash, timestamp, genesis)
+	case common.OutputTypeNodeDepart:
+		return writeNodeDepart(txn, signer, payee, utxo.Hash, timestamp)
+	case common.OutputTypeNodeRemove:
+		return writeNodeRemove(txn, signer, payee, utxo.Hash, timestamp)
 	case common.OutputTypeDomainAccept:
 		return writeDomainAccept(txn, signer, utxo.Hash, timestamp)
 	}
@@ -0,0 +1,470 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/MixinNetwork/mixin/logger"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	graphPrefixUTXOTree           = "MERKLETREE"
+	graphPrefixCheckpointUTXOTree = "MERKLECHECKPOINT"
+	stateKeyUTXOTreeLoaded        = "utxotree"
+	stateKeyUTXOCheckpoint        = "utxocheckpoint"
+)
+
+type utxoTreeTxn interface {
+	Get(key []byte) (*badger.Item, error)
+	Set(key, val []byte) error
+	Delete(key []byte) error
+}
+
+// the tree nodes are read from and written to the txn under the prefix, or
+// kept in the overlay if it's not nil, where a nil value is a deleted node
+type utxoTree struct {
+	txn     utxoTreeTxn
+	prefix  string
+	overlay map[string][]byte
+}
+
+type utxoTreeNode struct {
+	Hash  crypto.Hash
+	Leaf  bool
+	Key   crypto.Hash
+	Value crypto.Hash
+}
+
+// ReadUTXOProof returns the proof for the output against the UTXO root of the
+// last checkpoint with the tree copied, the checkpoint is nil if none is ready
+func (s *BadgerStore) ReadUTXOProof(hash crypto.Hash, index int) (*common.UTXOProof, *common.Checkpoint, error) {
+	var number uint64
+	found, err := s.StateGet(stateKeyUTXOCheckpoint, &number)
+	if err != nil || !found {
+		return nil, nil, err
+	}
+
+	txn := s.snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+
+	c, err := readCheckpoint(txn, number)
+	if err != nil || c == nil {
+		return nil, nil, err
+	}
+	tree := &utxoTree{txn: txn, prefix: graphPrefixCheckpointUTXOTree}
+	proof, root, err := readUTXOTreeProof(tree, common.UTXOTreeKey(hash, index))
+	if err != nil {
+		return nil, nil, err
+	}
+	if root != c.State.Root {
+		return nil, nil, fmt.Errorf("checkpoint %d utxo tree not ready %s %s", c.Number, root, c.State.Root)
+	}
+	return proof, c, nil
+}
+
+// the tree is built from all the unspent outputs once for the old data
+func (s *BadgerStore) loadUTXOTree() error {
+	loaded, err := s.StateGet(stateKeyUTXOTreeLoaded, nil)
+	if err != nil || loaded {
+		return err
+	}
+
+	logger.Println("Loading UTXO tree...")
+	start := time.Now()
+	err = s.RemoveGraphEntries(graphPrefixUTXOTree)
+	if err != nil {
+		return err
+	}
+	snap := s.snapshotsDB.NewTransaction(false)
+	defer snap.Discard()
+	w := &batchWriter{db: s.snapshotsDB, txn: s.snapshotsDB.NewTransaction(true)}
+	defer func() { w.txn.Discard() }()
+
+	it := snap.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	var total int
+	tree := newUTXOTree(w)
+	prefix := []byte(graphPrefixUTXO)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var out common.UTXOWithLock
+		err = common.DecompressMsgpackUnmarshal(val, &out)
+		if err != nil {
+			return err
+		}
+		if out.LockHash.HasValue() {
+			_, err := snap.Get(graphFinalizationKey(out.LockHash))
+			if err == nil {
+				continue
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+		}
+		err = writeUTXOTreeLeaf(tree, &out.UTXO)
+		if err != nil {
+			return err
+		}
+		total++
+	}
+	err = w.txn.Commit()
+	if err != nil {
+		return err
+	}
+	logger.Printf("Load UTXO tree with %d outputs in %s\n", total, time.Now().Sub(start).String())
+	return s.StateSet(stateKeyUTXOTreeLoaded, true)
+}
+
+// the checkpoint tree is copied from the current tree with all the changes
+// after the checkpoint rounds reverted, and it must match the signed root
+func (s *BadgerStore) writeCheckpointUTXOTree(c *common.Checkpoint) error {
+	var number uint64
+	found, err := s.StateGet(stateKeyUTXOCheckpoint, &number)
+	if err != nil || (found && number >= c.Number) {
+		return err
+	}
+
+	start := time.Now()
+	snap := s.snapshotsDB.NewTransaction(false)
+	defer snap.Discard()
+	tree, err := rewindUTXOTree(snap, c)
+	if err != nil {
+		return err
+	}
+	root, err := readUTXOTreeRoot(tree)
+	if err != nil {
+		return err
+	}
+	if root != c.State.Root {
+		return fmt.Errorf("checkpoint %d utxo root not match %s %s", c.Number, root, c.State.Root)
+	}
+
+	w := &batchWriter{db: s.snapshotsDB, txn: s.snapshotsDB.NewTransaction(true)}
+	defer func() { w.txn.Discard() }()
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := snap.NewIterator(opts)
+	defer it.Close()
+
+	prefix := []byte(graphPrefixCheckpointUTXOTree)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		err := w.Delete(it.Item().KeyCopy(nil))
+		if err != nil {
+			return err
+		}
+	}
+	prefix = []byte(graphPrefixUTXOTree)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		key := it.Item().KeyCopy(nil)
+		if _, found := tree.overlay[string(key)]; found {
+			continue
+		}
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		err = w.Set(checkpointUTXOTreeKey(key), val)
+		if err != nil {
+			return err
+		}
+	}
+	for key, val := range tree.overlay {
+		if val == nil {
+			continue
+		}
+		err := w.Set(checkpointUTXOTreeKey([]byte(key)), val)
+		if err != nil {
+			return err
+		}
+	}
+	err = w.txn.Commit()
+	if err != nil {
+		return err
+	}
+	logger.Printf("Copy checkpoint %d UTXO tree in %s\n", c.Number, time.Now().Sub(start).String())
+	return s.StateSet(stateKeyUTXOCheckpoint, c.Number)
+}
+
+// rewindUTXOTree reverts all the transactions finalized after the checkpoint
+// rounds in the overlay of the current tree, so the root is the same on all
+// nodes however far their graph is after the checkpoint
+func rewindUTXOTree(txn *badger.Txn, c *common.Checkpoint) (*utxoTree, error) {
+	tree := &utxoTree{txn: txn, prefix: graphPrefixUTXOTree, overlay: make(map[string][]byte)}
+	cut := &checkpointCut{txn: txn, rounds: c.RoundsMap()}
+	reverted := make(map[crypto.Hash]bool)
+	for _, r := range c.Rounds {
+		head, err := readRound(txn, r.NodeId)
+		if err != nil {
+			return nil, err
+		}
+		if head == nil || head.Number < r.Number {
+			return nil, fmt.Errorf("checkpoint round %s %d not final", r.NodeId, r.Number)
+		}
+		for n := r.Number + 1; n <= head.Number; n++ {
+			snapshots, err := readSnapshotsForNodeRound(txn, r.NodeId, n)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range snapshots {
+				if reverted[s.Transaction] {
+					continue
+				}
+				finalized, _, err := cut.finalized(s.Transaction)
+				if err != nil {
+					return nil, err
+				}
+				if finalized {
+					continue
+				}
+				reverted[s.Transaction] = true
+				err = revertUTXOTreeTransaction(tree, cut, s.Transaction)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return tree, nil
+}
+
+// the outputs of the transaction are removed, and the inputs are restored if
+// they are created in the checkpoint
+func revertUTXOTreeTransaction(tree *utxoTree, cut *checkpointCut, hash crypto.Hash) error {
+	ver, err := readTransaction(cut.txn, hash)
+	if err != nil {
+		return err
+	}
+	if ver == nil {
+		return fmt.Errorf("checkpoint transaction %s not found", hash)
+	}
+	for _, utxo := range ver.UnspentOutputs() {
+		err := removeUTXOTreeLeaf(tree, utxo.Hash, utxo.Index)
+		if err != nil {
+			return err
+		}
+	}
+	for _, in := range ver.Inputs {
+		if len(in.Genesis) > 0 || in.Deposit != nil || in.Mint != nil {
+			continue
+		}
+		created, _, err := cut.finalized(in.Hash)
+		if err != nil {
+			return err
+		}
+		if !created {
+			continue
+		}
+		item, err := cut.txn.Get(graphUtxoKey(in.Hash, in.Index))
+		if err == badger.ErrKeyNotFound {
+			return fmt.Errorf("checkpoint utxo %s:%d not found", in.Hash, in.Index)
+		} else if err != nil {
+			return err
+		}
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var out common.UTXOWithLock
+		err = common.DecompressMsgpackUnmarshal(val, &out)
+		if err != nil {
+			return err
+		}
+		err = writeUTXOTreeLeaf(tree, &out.UTXO)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func newUTXOTree(txn utxoTreeTxn) *utxoTree {
+	return &utxoTree{txn: txn, prefix: graphPrefixUTXOTree}
+}
+
+func writeUTXOTreeLeaf(tree *utxoTree, utxo *common.UTXO) error {
+	value := utxo.TreeValue()
+	_, err := updateUTXOTree(tree, 0, common.UTXOTreeKey(utxo.Hash, utxo.Index), &value)
+	return err
+}
+
+func removeUTXOTreeLeaf(tree *utxoTree, hash crypto.Hash, index int) error {
+	_, err := updateUTXOTree(tree, 0, common.UTXOTreeKey(hash, index), nil)
+	return err
+}
+
+func readUTXOTreeRoot(tree *utxoTree) (crypto.Hash, error) {
+	root, err := readUTXOTreeNode(tree, 0, crypto.Hash{})
+	if err != nil || root == nil {
+		return crypto.Hash{}, err
+	}
+	return root.Hash, nil
+}
+
+// the proof has the siblings until the leaf at the path of the key, which
+// is the output itself for inclusion, or another leaf or empty for exclusion
+func readUTXOTreeProof(tree *utxoTree, key crypto.Hash) (*common.UTXOProof, crypto.Hash, error) {
+	proof := &common.UTXOProof{Key: key, Siblings: []crypto.Hash{}}
+	root, err := readUTXOTreeNode(tree, 0, key)
+	if err != nil || root == nil {
+		return proof, crypto.Hash{}, err
+	}
+	node := root
+	for depth := 0; !node.Leaf; depth++ {
+		sibling, err := readUTXOTreeNode(tree, depth+1, utxoTreeSibling(key, depth))
+		if err != nil {
+			return nil, crypto.Hash{}, err
+		}
+		if sibling == nil {
+			proof.Siblings = append(proof.Siblings, crypto.Hash{})
+		} else {
+			proof.Siblings = append(proof.Siblings, sibling.Hash)
+		}
+		node, err = readUTXOTreeNode(tree, depth+1, key)
+		if err != nil {
+			return nil, crypto.Hash{}, err
+		}
+		if node == nil {
+			return proof, root.Hash, nil
+		}
+	}
+	proof.Leaf = &common.UTXOProofLeaf{Key: node.Key, Value: node.Value}
+	return proof, root.Hash, nil
+}
+
+// a leaf is always stored at the shortest unique path, so after each update
+// a subtree with a single leaf is collapsed to the leaf itself
+func updateUTXOTree(tree *utxoTree, depth int, key crypto.Hash, value *crypto.Hash) (*utxoTreeNode, error) {
+	node, err := readUTXOTreeNode(tree, depth, key)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case node == nil && value == nil:
+		return nil, nil
+	case node == nil || (node.Leaf && node.Key == key && value != nil):
+		leaf := &utxoTreeNode{Leaf: true, Key: key, Value: *value}
+		leaf.Hash = common.UTXOTreeLeafHash(key, *value)
+		return leaf, writeUTXOTreeNode(tree, depth, key, leaf)
+	case node.Leaf && node.Key == key:
+		return nil, tree.delete(tree.nodeKey(depth, key))
+	case node.Leaf && value == nil:
+		return node, nil
+	case node.Leaf:
+		err := writeUTXOTreeNode(tree, depth+1, node.Key, node)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	child, err := updateUTXOTree(tree, depth+1, key, value)
+	if err != nil {
+		return nil, err
+	}
+	sibling, err := readUTXOTreeNode(tree, depth+1, utxoTreeSibling(key, depth))
+	if err != nil {
+		return nil, err
+	}
+	if child == nil && sibling == nil {
+		return nil, tree.delete(tree.nodeKey(depth, key))
+	}
+	if single := child; sibling == nil || child == nil {
+		if single == nil {
+			single = sibling
+		}
+		if single.Leaf {
+			err := tree.delete(tree.nodeKey(depth+1, single.Key))
+			if err != nil {
+				return nil, err
+			}
+			return single, writeUTXOTreeNode(tree, depth, key, single)
+		}
+	}
+
+	var left, right crypto.Hash
+	if child != nil {
+		left = child.Hash
+	}
+	if sibling != nil {
+		right = sibling.Hash
+	}
+	if common.UTXOTreeBit(key, depth) == 1 {
+		left, right = right, left
+	}
+	node = &utxoTreeNode{Hash: common.UTXOTreeNodeHash(left, right)}
+	return node, writeUTXOTreeNode(tree, depth, key, node)
+}
+
+func readUTXOTreeNode(tree *utxoTree, depth int, key crypto.Hash) (*utxoTreeNode, error) {
+	val, err := tree.get(tree.nodeKey(depth, key))
+	if err != nil || val == nil {
+		return nil, err
+	}
+	var node utxoTreeNode
+	err = common.MsgpackUnmarshal(val, &node)
+	return &node, err
+}
+
+func writeUTXOTreeNode(tree *utxoTree, depth int, key crypto.Hash, node *utxoTreeNode) error {
+	return tree.set(tree.nodeKey(depth, key), common.MsgpackMarshalPanic(node))
+}
+
+func (tree *utxoTree) get(key []byte) ([]byte, error) {
+	if val, found := tree.overlay[string(key)]; found {
+		return val, nil
+	}
+	item, err := tree.txn.Get(key)
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return item.ValueCopy(nil)
+}
+
+func (tree *utxoTree) set(key, val []byte) error {
+	if tree.overlay != nil {
+		tree.overlay[string(key)] = val
+		return nil
+	}
+	return tree.txn.Set(key, val)
+}
+
+func (tree *utxoTree) delete(key []byte) error {
+	if tree.overlay != nil {
+		tree.overlay[string(key)] = nil
+		return nil
+	}
+	return tree.txn.Delete(key)
+}
+
+// the node key is the depth with the first depth bits of the path
+func (tree *utxoTree) nodeKey(depth int, key crypto.Hash) []byte {
+	var path crypto.Hash
+	copy(path[:], key[:depth/8])
+	if depth%8 > 0 && depth < common.UTXOTreeDepth {
+		path[depth/8] = key[depth/8] & ^byte(0xff>>uint(depth%8))
+	}
+	buf := make([]byte, 2)
+	binary.BigEndian.PutUint16(buf, uint16(depth))
+	buf = append([]byte(tree.prefix), buf...)
+	return append(buf, path[:]...)
+}
+
+func utxoTreeSibling(key crypto.Hash, depth int) crypto.Hash {
+	key[depth/8] ^= 1 << uint(7-depth%8)
+	return key
+}
+
+func checkpointUTXOTreeKey(key []byte) []byte {
+	key = bytes.TrimPrefix(key, []byte(graphPrefixUTXOTree))
+	return append([]byte(graphPrefixCheckpointUTXOTree), key...)
+}
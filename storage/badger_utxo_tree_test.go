@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUTXOTree(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-utxo-tree-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	var utxos []*common.UTXO
+	for i := 0; i < 128; i++ {
+		utxo := &common.UTXO{Asset: common.XINAssetId}
+		utxo.Hash = crypto.NewHash([]byte(fmt.Sprintf("utxo-tree-%d", i/4)))
+		utxo.Index = i % 4
+		utxo.Type = common.OutputTypeScript
+		utxo.Amount = common.NewInteger(uint64(i + 1))
+		utxos = append(utxos, utxo)
+	}
+
+	stores := make([]*BadgerStore, 3)
+	for i := range stores {
+		dir := fmt.Sprintf("%s/%d", root, i)
+		assert.Nil(os.Mkdir(dir, 0755))
+		store, err := NewBadgerStore(dir)
+		assert.Nil(err)
+		defer store.Close()
+		stores[i] = store
+	}
+	update := func(store *BadgerStore, utxos []*common.UTXO, remove bool) crypto.Hash {
+		txn := store.snapshotsDB.NewTransaction(true)
+		defer txn.Discard()
+		tree := newUTXOTree(txn)
+		for _, utxo := range utxos {
+			if remove {
+				assert.Nil(removeUTXOTreeLeaf(tree, utxo.Hash, utxo.Index))
+			} else {
+				assert.Nil(writeUTXOTreeLeaf(tree, utxo))
+			}
+		}
+		assert.Nil(txn.Commit())
+		txn = store.snapshotsDB.NewTransaction(false)
+		defer txn.Discard()
+		root, err := readUTXOTreeRoot(newUTXOTree(txn))
+		assert.Nil(err)
+		return root
+	}
+	prove := func(store *BadgerStore, utxo *common.UTXO) (*common.UTXOProof, crypto.Hash, error) {
+		txn := store.snapshotsDB.NewTransaction(false)
+		defer txn.Discard()
+		return readUTXOTreeProof(newUTXOTree(txn), common.UTXOTreeKey(utxo.Hash, utxo.Index))
+	}
+
+	proof, root0, err := prove(stores[0], utxos[0])
+	assert.Nil(err)
+	assert.Equal(crypto.Hash{}, root0)
+	assert.Nil(proof.VerifyExclusion(root0))
+
+	reversed := make([]*common.UTXO, len(utxos))
+	for i, utxo := range utxos {
+		reversed[len(utxos)-1-i] = utxo
+	}
+	root0 = update(stores[0], utxos, false)
+	root1 := update(stores[1], reversed, false)
+	assert.False(root0 == crypto.Hash{})
+	assert.Equal(root0, root1)
+	assert.Equal(root0, update(stores[0], utxos[:10], false))
+
+	txn := stores[1].snapshotsDB.NewTransaction(false)
+	overlay := &utxoTree{txn: txn, prefix: graphPrefixUTXOTree, overlay: make(map[string][]byte)}
+	for _, utxo := range utxos[64:] {
+		assert.Nil(removeUTXOTreeLeaf(overlay, utxo.Hash, utxo.Index))
+	}
+	rewound, err := readUTXOTreeRoot(overlay)
+	assert.Nil(err)
+	txn.Discard()
+
+	root0 = update(stores[0], utxos[64:], true)
+	root2 := update(stores[2], utxos[:64], false)
+	assert.Equal(root0, rewound)
+	assert.Equal(root2, root0)
+	assert.NotEqual(root1, root0)
+
+	for i, utxo := range utxos {
+		proof, root, err := prove(stores[0], utxo)
+		assert.Nil(err)
+		assert.Equal(root0, root)
+		if i < 64 {
+			assert.Nil(proof.VerifyInclusion(root, utxo))
+			assert.NotNil(proof.VerifyExclusion(root))
+			assert.NotNil(proof.VerifyInclusion(root1, utxo))
+		} else {
+			assert.Nil(proof.VerifyExclusion(root))
+			assert.NotNil(proof.VerifyInclusion(root, utxo))
+		}
+	}
+
+	root0 = update(stores[0], utxos[:64], true)
+	assert.Equal(crypto.Hash{}, root0)
+	txn = stores[1].snapshotsDB.NewTransaction(false)
+	rewound, err = readUTXOTreeRoot(newUTXOTree(txn))
+	assert.Nil(err)
+	assert.Equal(root1, rewound)
+	txn.Discard()
+	txn = stores[0].snapshotsDB.NewTransaction(false)
+	defer txn.Discard()
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	prefix := []byte(graphPrefixUTXOTree)
+	it.Seek(prefix)
+	assert.False(it.ValidForPrefix(prefix))
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/MixinNetwork/mixin/config"
 	"github.com/MixinNetwork/mixin/crypto"
 	"github.com/MixinNetwork/mixin/logger"
+	"github.com/dgraph-io/badger"
 )
 
 func (s *BadgerStore) ValidateGraphEntries(networkId crypto.Hash) (int, int, error) {
@@ -66,6 +67,10 @@ func (s *BadgerStore) validateSnapshotEntriesForNode(nodeId crypto.Hash) (int, i
 	if head.Number < 10 {
 		start = 0
 	}
+	pruned, err := s.ReadPrunedTopology()
+	if err != nil {
+		return 0, 0, err
+	}
 	invalid, total := 0, 0
 	for i := start; i < head.Number; i++ {
 		snapshots, err := readSnapshotsForNodeRound(txn, nodeId, i)
@@ -74,27 +79,18 @@ func (s *BadgerStore) validateSnapshotEntriesForNode(nodeId crypto.Hash) (int, i
 		}
 		for _, s := range snapshots {
 			total += 1
-			item, err := txn.Get(graphTransactionKey(s.Transaction))
-			if err != nil {
-				return total, invalid, err
-			}
-			val, err := item.ValueCopy(nil)
-			if err != nil {
-				return total, invalid, err
-			}
-			ver, err := common.DecompressUnmarshalVersionedTransaction(val)
+			valid, err := validateSnapshotTransaction(txn, s, pruned > 0)
 			if err != nil {
 				return total, invalid, err
 			}
-			if s.Transaction.String() != ver.PayloadHash().String() {
-				logger.Printf("MALFORMED TRANSACTION %s %s %#v\n", s.Transaction, ver.PayloadHash(), ver)
+			if !valid {
 				invalid += 1
 			}
-			item, err = txn.Get(graphFinalizationKey(s.Transaction))
+			item, err := txn.Get(graphFinalizationKey(s.Transaction))
 			if err != nil {
 				return total, invalid, err
 			}
-			val, err = item.ValueCopy(nil)
+			val, err := item.ValueCopy(nil)
 			if err != nil {
 				return total, invalid, err
 			}
@@ -165,3 +161,26 @@ func computeRoundHash(nodeId crypto.Hash, number uint64, snapshots []*common.Sna
 	}
 	return start, end, hash
 }
+
+// the transaction bodies before the pruned topology are dropped
+func validateSnapshotTransaction(txn *badger.Txn, s *common.SnapshotWithTopologicalOrder, pruned bool) (bool, error) {
+	item, err := txn.Get(graphTransactionKey(s.Transaction))
+	if err == badger.ErrKeyNotFound && pruned {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	val, err := item.ValueCopy(nil)
+	if err != nil {
+		return false, err
+	}
+	ver, err := common.DecompressUnmarshalVersionedTransaction(val)
+	if err != nil {
+		return false, err
+	}
+	if s.Transaction.String() != ver.PayloadHash().String() {
+		logger.Printf("MALFORMED TRANSACTION %s %s %#v\n", s.Transaction, ver.PayloadHash(), ver)
+		return false, nil
+	}
+	return true, nil
+}
@@ -24,6 +24,7 @@ type Store interface {
 	TopologySequence() uint64
 
 	ReadUTXO(hash crypto.Hash, index int) (*common.UTXOWithLock, error)
+	ReadUTXOProof(hash crypto.Hash, index int) (*common.UTXOProof, *common.Checkpoint, error)
 	LockUTXO(hash crypto.Hash, index int, tx crypto.Hash, fork bool) error
 	CheckDepositInput(deposit *common.DepositData, tx crypto.Hash) error
 	LockDepositInput(deposit *common.DepositData, tx crypto.Hash, fork bool) error
@@ -35,7 +36,15 @@ type Store interface {
 	ReadRound(hash crypto.Hash) (*common.Round, error)
 	ReadLink(from, to crypto.Hash) (uint64, error)
 	WriteSnapshot(*common.SnapshotWithTopologicalOrder) error
+	SnapshotsNotify() <-chan struct{}
 	ReadDomains() []common.Domain
+	WriteEvidence(e *common.Evidence) error
+	ReadEvidences(nodeId crypto.Hash) ([]*common.Evidence, error)
+	WriteCheckpoint(c *common.Checkpoint) error
+	ReadCheckpoint(number uint64) (*common.Checkpoint, error)
+	ReadLastCheckpoint() (*common.Checkpoint, error)
+	ReadCheckpoints(offset, count uint64) ([]*common.Checkpoint, error)
+	ComputeCheckpointState(networkId crypto.Hash, c *common.Checkpoint) (*common.CheckpointState, error)
 
 	QueueInfo() (uint64, uint64, error)
 	QueueAppendSnapshot(peerId crypto.Hash, snap *common.Snapshot, finalized bool) error
@@ -49,5 +58,7 @@ type Store interface {
 	ReadMintDistributions(group string, offset, count uint64) ([]*common.MintDistribution, []*common.VersionedTransaction, error)
 
 	RemoveGraphEntries(prefix string) error
+	PruneGraphEntries(topology uint64) error
+	ReadPrunedTopology() (uint64, error)
 	ValidateGraphEntries(networkId crypto.Hash) (int, int, error)
 }
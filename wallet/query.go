@@ -0,0 +1,71 @@
+package wallet
+
+import (
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+)
+
+func (w *Wallet) Balance(account crypto.Key) (map[crypto.Hash]common.Integer, error) {
+	outputs, err := w.ListUnspentOutputs(account)
+	if err != nil {
+		return nil, err
+	}
+	balance := make(map[crypto.Hash]common.Integer)
+	for _, o := range outputs {
+		balance[o.Asset] = addAmount(balance[o.Asset], o.Amount)
+	}
+	return balance, nil
+}
+
+func (w *Wallet) ListUnspentOutputs(account crypto.Key) ([]*Output, error) {
+	txn := w.db.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	outputs := make([]*Output, 0)
+	prefix := append([]byte(walletPrefixUTXO), account[:]...)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var out Output
+		err = common.MsgpackUnmarshal(v, &out)
+		if err != nil {
+			return nil, err
+		}
+		if out.SpentBy.HasValue() {
+			continue
+		}
+		outputs = append(outputs, &out)
+	}
+	return outputs, nil
+}
+
+func (w *Wallet) ListHistory(account crypto.Key, offset, count uint64) ([]*History, error) {
+	txn := w.db.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	history := make([]*History, 0)
+	prefix := append([]byte(walletPrefixHistory), account[:]...)
+	it.Seek(walletHistoryKey(account, offset, 0))
+	for ; it.ValidForPrefix(prefix) && uint64(len(history)) < count; it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return nil, err
+		}
+		var h History
+		err = common.MsgpackUnmarshal(v, &h)
+		if err != nil {
+			return nil, err
+		}
+		history = append(history, &h)
+	}
+	return history, nil
+}
@@ -0,0 +1,381 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	walletPrefixCursor  = "WALLETCURSOR"
+	walletPrefixAccount = "WALLETACCOUNT"
+	walletPrefixUTXO    = "WALLETUTXO"
+	walletPrefixLookup  = "WALLETLOOKUP"
+	walletPrefixHistory = "WALLETHISTORY"
+
+	HistoryTypeReceive = 0x01
+	HistoryTypeSpend   = 0x02
+)
+
+type SnapshotReader interface {
+	ReadSnapshotWithTransactionsSinceTopology(topologyOffset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error)
+}
+
+type Output struct {
+	common.UTXO
+	Account          crypto.Key  `json:"account"`
+	Snapshot         crypto.Hash `json:"snapshot"`
+	TopologicalOrder uint64      `json:"topology"`
+	Timestamp        uint64      `json:"timestamp"`
+	SpentBy          crypto.Hash `json:"spent_by"`
+}
+
+type History struct {
+	Type             uint8          `json:"type"`
+	Asset            crypto.Hash    `json:"asset"`
+	Amount           common.Integer `json:"amount"`
+	Transaction      crypto.Hash    `json:"transaction"`
+	Snapshot         crypto.Hash    `json:"snapshot"`
+	TopologicalOrder uint64         `json:"topology"`
+	Timestamp        uint64         `json:"timestamp"`
+}
+
+type Wallet struct {
+	db       *badger.DB
+	reader   SnapshotReader
+	accounts []common.Address
+}
+
+func NewWallet(dir string, reader SnapshotReader) (*Wallet, error) {
+	opts := badger.DefaultOptions(dir)
+	opts.SyncWrites = true
+	db, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+	w := &Wallet{db: db, reader: reader}
+	err = w.loadAccounts()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *Wallet) Close() error {
+	return w.db.Close()
+}
+
+func (w *Wallet) Accounts() []common.Address {
+	return w.accounts
+}
+
+func (w *Wallet) AddAccount(account common.Address) error {
+	if !account.PrivateViewKey.HasValue() || !account.PrivateViewKey.CheckScalar() || !account.PublicSpendKey.CheckKey() {
+		return fmt.Errorf("invalid wallet account %s", account.String())
+	}
+	for _, a := range w.accounts {
+		if a.PublicSpendKey == account.PublicSpendKey {
+			return nil
+		}
+	}
+
+	stored := common.Address{
+		PrivateViewKey: account.PrivateViewKey,
+		PublicViewKey:  account.PrivateViewKey.Public(),
+		PublicSpendKey: account.PublicSpendKey,
+	}
+	err := w.db.Update(func(txn *badger.Txn) error {
+		err := txn.Set(walletAccountKey(stored.PublicSpendKey), common.MsgpackMarshalPanic(stored))
+		if err != nil {
+			return err
+		}
+		// a new account must see all the outputs from the beginning
+		return txn.Set([]byte(walletPrefixCursor), walletUint64(0))
+	})
+	if err != nil {
+		return err
+	}
+	w.accounts = append(w.accounts, stored)
+	return nil
+}
+
+func (w *Wallet) Cursor() (uint64, error) {
+	txn := w.db.NewTransaction(false)
+	defer txn.Discard()
+
+	return readCursor(txn)
+}
+
+func (w *Wallet) Scan(count uint64) (int, error) {
+	cursor, err := w.Cursor()
+	if err != nil {
+		return 0, err
+	}
+	snapshots, transactions, err := w.reader.ReadSnapshotWithTransactionsSinceTopology(cursor, count)
+	if err != nil {
+		return 0, err
+	}
+	if len(snapshots) != len(transactions) {
+		return 0, fmt.Errorf("invalid snapshots and transactions count %d %d", len(snapshots), len(transactions))
+	}
+	if len(snapshots) == 0 {
+		return 0, nil
+	}
+
+	total := 0
+	for len(snapshots) > 0 {
+		n, err := w.writeSnapshots(snapshots, transactions)
+		if err != nil {
+			return total, err
+		}
+		snapshots, transactions = snapshots[n:], transactions[n:]
+		total += n
+	}
+	return total, nil
+}
+
+// writeSnapshots writes as many snapshots as fit in one badger transaction, a
+// snapshot never spans two transactions, so the cursor always points to the
+// next snapshot to process
+func (w *Wallet) writeSnapshots(snapshots []*common.SnapshotWithTopologicalOrder, transactions []*common.VersionedTransaction) (int, error) {
+	count := len(snapshots)
+	for {
+		fit := count
+		err := w.db.Update(func(txn *badger.Txn) error {
+			for i, s := range snapshots[:count] {
+				err := w.processSnapshot(txn, s, transactions[i])
+				if err == badger.ErrTxnTooBig {
+					fit = i
+				}
+				if err != nil {
+					return err
+				}
+			}
+			cursor := snapshots[count-1].TopologicalOrder + 1
+			err := txn.Set([]byte(walletPrefixCursor), walletUint64(cursor))
+			if err == badger.ErrTxnTooBig {
+				fit = count - 1
+			}
+			return err
+		})
+		if err != badger.ErrTxnTooBig || fit == 0 {
+			return count, err
+		}
+		count = fit
+	}
+}
+
+func (w *Wallet) processSnapshot(txn *badger.Txn, s *common.SnapshotWithTopologicalOrder, tx *common.VersionedTransaction) error {
+	if tx == nil {
+		return fmt.Errorf("transaction not found for snapshot %s", s.Hash.String())
+	}
+	hash := tx.PayloadHash()
+	spent := make(map[crypto.Key]common.Integer)
+	received := make(map[crypto.Key]common.Integer)
+
+	for _, in := range tx.Inputs {
+		if !in.Hash.HasValue() {
+			continue
+		}
+		outputs, err := readOutputsByInput(txn, in.Hash, in.Index)
+		if err != nil {
+			return err
+		}
+		for _, out := range outputs {
+			if out.SpentBy.HasValue() {
+				continue
+			}
+			out.SpentBy = hash
+			err = txn.Set(walletUTXOKey(out.Account, out.Hash, out.Index), common.MsgpackMarshalPanic(out))
+			if err != nil {
+				return err
+			}
+			spent[out.Account] = addAmount(spent[out.Account], out.Amount)
+		}
+	}
+
+	for i, o := range tx.Outputs {
+		if (o.Type != common.OutputTypeScript && o.Type != common.OutputTypeNodeRemove) || !o.Mask.CheckKey() {
+			continue
+		}
+		for _, a := range w.accounts {
+			if !outputBelongsTo(o, uint64(i), a) {
+				continue
+			}
+			key := walletUTXOKey(a.PublicSpendKey, hash, i)
+			_, err := txn.Get(key)
+			if err == nil {
+				continue
+			} else if err != badger.ErrKeyNotFound {
+				return err
+			}
+			out := &Output{
+				UTXO: common.UTXO{
+					Input:  common.Input{Hash: hash, Index: i},
+					Output: *o,
+					Asset:  tx.Asset,
+				},
+				Account:          a.PublicSpendKey,
+				Snapshot:         s.Hash,
+				TopologicalOrder: s.TopologicalOrder,
+				Timestamp:        s.Timestamp,
+			}
+			err = txn.Set(key, common.MsgpackMarshalPanic(out))
+			if err != nil {
+				return err
+			}
+			err = txn.Set(walletLookupKey(hash, i, a.PublicSpendKey), []byte{})
+			if err != nil {
+				return err
+			}
+			received[a.PublicSpendKey] = addAmount(received[a.PublicSpendKey], o.Amount)
+		}
+	}
+
+	for typ, amounts := range map[uint8]map[crypto.Key]common.Integer{HistoryTypeReceive: received, HistoryTypeSpend: spent} {
+		for account, amount := range amounts {
+			h := &History{
+				Type:             typ,
+				Asset:            tx.Asset,
+				Amount:           amount,
+				Transaction:      hash,
+				Snapshot:         s.Hash,
+				TopologicalOrder: s.TopologicalOrder,
+				Timestamp:        s.Timestamp,
+			}
+			err := txn.Set(walletHistoryKey(account, s.TopologicalOrder, typ), common.MsgpackMarshalPanic(h))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (w *Wallet) loadAccounts() error {
+	txn := w.db.NewTransaction(false)
+	defer txn.Discard()
+
+	it := txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	prefix := []byte(walletPrefixAccount)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		v, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		var a common.Address
+		err = common.MsgpackUnmarshal(v, &a)
+		if err != nil {
+			return err
+		}
+		w.accounts = append(w.accounts, a)
+	}
+	return nil
+}
+
+func outputBelongsTo(o *common.Output, index uint64, a common.Address) bool {
+	for _, k := range o.Keys {
+		if !k.CheckKey() {
+			continue
+		}
+		if *crypto.ViewGhostOutputKey(&k, &a.PrivateViewKey, &o.Mask, index) == a.PublicSpendKey {
+			return true
+		}
+	}
+	return false
+}
+
+func readCursor(txn *badger.Txn) (uint64, error) {
+	item, err := txn.Get([]byte(walletPrefixCursor))
+	if err == badger.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(v), nil
+}
+
+// an output with multiple keys may belong to multiple accounts of the wallet
+func readOutputsByInput(txn *badger.Txn, hash crypto.Hash, index int) ([]*Output, error) {
+	it := txn.NewIterator(badger.IteratorOptions{})
+	defer it.Close()
+
+	var outputs []*Output
+	prefix := walletLookupPrefix(hash, index)
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		var account crypto.Key
+		copy(account[:], it.Item().Key()[len(prefix):])
+		out, err := readOutput(txn, account, hash, index)
+		if err != nil {
+			return nil, err
+		}
+		if out != nil {
+			outputs = append(outputs, out)
+		}
+	}
+	return outputs, nil
+}
+
+func readOutput(txn *badger.Txn, account crypto.Key, hash crypto.Hash, index int) (*Output, error) {
+	item, err := txn.Get(walletUTXOKey(account, hash, index))
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	v, err := item.ValueCopy(nil)
+	if err != nil {
+		return nil, err
+	}
+	var out Output
+	err = common.MsgpackUnmarshal(v, &out)
+	return &out, err
+}
+
+func addAmount(sum, amount common.Integer) common.Integer {
+	if sum.Sign() == 0 {
+		return amount
+	}
+	return sum.Add(amount)
+}
+
+func walletAccountKey(account crypto.Key) []byte {
+	return append([]byte(walletPrefixAccount), account[:]...)
+}
+
+func walletUTXOKey(account crypto.Key, hash crypto.Hash, index int) []byte {
+	key := append([]byte(walletPrefixUTXO), account[:]...)
+	key = append(key, hash[:]...)
+	return append(key, walletUint64(uint64(index))...)
+}
+
+func walletLookupKey(hash crypto.Hash, index int, account crypto.Key) []byte {
+	return append(walletLookupPrefix(hash, index), account[:]...)
+}
+
+func walletLookupPrefix(hash crypto.Hash, index int) []byte {
+	key := append([]byte(walletPrefixLookup), hash[:]...)
+	return append(key, walletUint64(uint64(index))...)
+}
+
+func walletHistoryKey(account crypto.Key, topology uint64, typ uint8) []byte {
+	key := append([]byte(walletPrefixHistory), account[:]...)
+	key = append(key, walletUint64(topology)...)
+	return append(key, typ)
+}
+
+func walletUint64(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return buf
+}
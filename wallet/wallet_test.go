@@ -0,0 +1,166 @@
+package wallet
+
+import (
+	"crypto/rand"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/MixinNetwork/mixin/common"
+	"github.com/MixinNetwork/mixin/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWallet(t *testing.T) {
+	assert := assert.New(t)
+
+	root, err := ioutil.TempDir("", "mixin-wallet-test")
+	assert.Nil(err)
+	defer os.RemoveAll(root)
+
+	alice, bob := randomAccount(), randomAccount()
+	reader := &readerImpl{}
+
+	tx := common.NewTransaction(common.XINAssetId)
+	tx.AddInput(crypto.NewHash([]byte("genesis")), 0)
+	tx.AddRandomScriptOutput([]common.Address{alice}, common.NewThresholdScript(1), common.NewInteger(5))
+	tx.AddRandomScriptOutput([]common.Address{bob}, common.NewThresholdScript(1), common.NewInteger(3))
+	tx.AddRandomScriptOutput([]common.Address{alice, bob}, common.NewThresholdScript(2), common.NewInteger(1))
+	first := tx.AsLatestVersion()
+	reader.append(first)
+
+	tx = common.NewTransaction(common.XINAssetId)
+	tx.AddInput(first.PayloadHash(), 0)
+	tx.AddRandomScriptOutput([]common.Address{bob}, common.NewThresholdScript(1), common.NewInteger(2))
+	tx.AddRandomScriptOutput([]common.Address{alice}, common.NewThresholdScript(1), common.NewInteger(3))
+	second := tx.AsLatestVersion()
+	reader.append(second)
+
+	w, err := NewWallet(root, reader)
+	assert.Nil(err)
+	err = w.AddAccount(alice)
+	assert.Nil(err)
+	err = w.AddAccount(common.Address{})
+	assert.NotNil(err)
+	assert.Len(w.Accounts(), 1)
+
+	n, err := w.Scan(1)
+	assert.Nil(err)
+	assert.Equal(1, n)
+	balance, err := w.Balance(alice.PublicSpendKey)
+	assert.Nil(err)
+	assert.Equal("6.00000000", balance[common.XINAssetId].String())
+
+	n, err = w.Scan(100)
+	assert.Nil(err)
+	assert.Equal(1, n)
+	n, err = w.Scan(100)
+	assert.Nil(err)
+	assert.Equal(0, n)
+	cursor, err := w.Cursor()
+	assert.Nil(err)
+	assert.Equal(uint64(2), cursor)
+
+	balance, err = w.Balance(alice.PublicSpendKey)
+	assert.Nil(err)
+	assert.Equal("4.00000000", balance[common.XINAssetId].String())
+	outputs, err := w.ListUnspentOutputs(alice.PublicSpendKey)
+	assert.Nil(err)
+	assert.Len(outputs, 2)
+	for _, o := range outputs {
+		if o.Hash == first.PayloadHash() {
+			assert.Equal(2, o.Index)
+			assert.Equal("1.00000000", o.Amount.String())
+		} else {
+			assert.Equal(second.PayloadHash(), o.Hash)
+			assert.Equal(1, o.Index)
+			assert.Equal(uint64(1), o.TopologicalOrder)
+		}
+	}
+
+	history, err := w.ListHistory(alice.PublicSpendKey, 0, 10)
+	assert.Nil(err)
+	assert.Len(history, 3)
+	assert.Equal(uint8(HistoryTypeReceive), history[0].Type)
+	assert.Equal("6.00000000", history[0].Amount.String())
+	assert.Equal(uint8(HistoryTypeReceive), history[1].Type)
+	assert.Equal("3.00000000", history[1].Amount.String())
+	assert.Equal(second.PayloadHash(), history[1].Transaction)
+	assert.Equal(uint8(HistoryTypeSpend), history[2].Type)
+	assert.Equal("5.00000000", history[2].Amount.String())
+	history, err = w.ListHistory(alice.PublicSpendKey, 1, 1)
+	assert.Nil(err)
+	assert.Len(history, 1)
+	assert.Equal(uint64(1), history[0].TopologicalOrder)
+
+	err = w.Close()
+	assert.Nil(err)
+
+	w, err = NewWallet(root, reader)
+	assert.Nil(err)
+	assert.Len(w.Accounts(), 1)
+	err = w.AddAccount(bob)
+	assert.Nil(err)
+	cursor, err = w.Cursor()
+	assert.Nil(err)
+	assert.Equal(uint64(0), cursor)
+
+	tx = common.NewTransaction(common.XINAssetId)
+	tx.AddInput(first.PayloadHash(), 2)
+	tx.AddRandomScriptOutput([]common.Address{bob}, common.NewThresholdScript(1), common.NewInteger(1))
+	third := tx.AsLatestVersion()
+	reader.append(third)
+	n, err = w.Scan(100)
+	assert.Nil(err)
+	assert.Equal(3, n)
+
+	balance, err = w.Balance(alice.PublicSpendKey)
+	assert.Nil(err)
+	assert.Equal("3.00000000", balance[common.XINAssetId].String())
+	history, err = w.ListHistory(alice.PublicSpendKey, 0, 10)
+	assert.Nil(err)
+	assert.Len(history, 4)
+	assert.Equal(uint8(HistoryTypeSpend), history[3].Type)
+	assert.Equal(third.PayloadHash(), history[3].Transaction)
+	balance, err = w.Balance(bob.PublicSpendKey)
+	assert.Nil(err)
+	assert.Equal("6.00000000", balance[common.XINAssetId].String())
+	err = w.Close()
+	assert.Nil(err)
+}
+
+type readerImpl struct {
+	snapshots    []*common.SnapshotWithTopologicalOrder
+	transactions []*common.VersionedTransaction
+}
+
+func (r *readerImpl) append(tx *common.VersionedTransaction) {
+	s := &common.SnapshotWithTopologicalOrder{
+		Snapshot: common.Snapshot{
+			Version:     common.SnapshotVersion,
+			Transaction: tx.PayloadHash(),
+			Timestamp:   uint64(len(r.snapshots) + 1),
+		},
+		TopologicalOrder: uint64(len(r.snapshots)),
+	}
+	s.Hash = s.PayloadHash()
+	r.snapshots = append(r.snapshots, s)
+	r.transactions = append(r.transactions, tx)
+}
+
+func (r *readerImpl) ReadSnapshotWithTransactionsSinceTopology(offset, count uint64) ([]*common.SnapshotWithTopologicalOrder, []*common.VersionedTransaction, error) {
+	if offset >= uint64(len(r.snapshots)) {
+		return nil, nil, nil
+	}
+	end := offset + count
+	if end > uint64(len(r.snapshots)) {
+		end = uint64(len(r.snapshots))
+	}
+	return r.snapshots[offset:end], r.transactions[offset:end], nil
+}
+
+func randomAccount() common.Address {
+	seed := make([]byte, 64)
+	rand.Read(seed)
+	return common.NewAddressFromSeed(seed)
+}